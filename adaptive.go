@@ -0,0 +1,191 @@
+package concurrent
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+const (
+	/**
+	 * The number of writes between two checks of contention of AdaptiveMap.
+	 */
+	ADAPTIVE_WINDOW int32 = 1024
+
+	/**
+	 * AdaptiveMap upgrades to segmented map if the segment lock is contended
+	 * at least this many times in a window.
+	 */
+	ADAPTIVE_CONTENTION_THRESHOLD int32 = 64
+)
+
+/**
+ * AdaptiveMap starts as a simple locked map, i.e. a ConcurrentMap with only one segment,
+ * and transparently upgrades to a ConcurrentMap with DEFAULT_CONCURRENCY_LEVEL segments
+ * when the contention of segment lock crosses ADAPTIVE_CONTENTION_THRESHOLD.
+ * So the maps that are rarely written concurrently don't pay for the segments.
+ *
+ * The upgrade blocks the writers while copying the mappings, but never blocks the readers.
+ * The upgrade happens once at most.
+ */
+type AdaptiveMap struct {
+	current  unsafe.Pointer //*ConcurrentMap
+	upgraded int32
+	writes   int32
+	//writers hold read lock before upgrading, and upgrade holds write lock
+	lock sync.RWMutex
+}
+
+var _ Map = (*AdaptiveMap)(nil)
+
+/**
+ * Creates a new, empty AdaptiveMap.
+ */
+func NewAdaptive() *AdaptiveMap {
+	return &AdaptiveMap{
+		current: unsafe.Pointer(newConcurrentMap3(DEFAULT_INITIAL_CAPACITY, DEFAULT_LOAD_FACTOR, 1)),
+	}
+}
+
+func (this *AdaptiveMap) load() *ConcurrentMap {
+	return (*ConcurrentMap)(atomic.LoadPointer(&this.current))
+}
+
+/**
+ * Returns true if the map has been upgraded to the segmented map.
+ */
+func (this *AdaptiveMap) IsUpgraded() bool {
+	return atomic.LoadInt32(&this.upgraded) == 1
+}
+
+//write calls f with current map, and upgrades the map if the contention crosses threshold
+func (this *AdaptiveMap) write(f func(m *ConcurrentMap)) {
+	if this.IsUpgraded() {
+		f(this.load())
+		return
+	}
+
+	this.lock.RLock()
+	m := this.load()
+	f(m)
+	this.lock.RUnlock()
+
+	if !this.IsUpgraded() && atomic.AddInt32(&this.writes, 1)%ADAPTIVE_WINDOW == 0 {
+		if atomic.SwapInt32(&m.segments[0].contended, 0) >= ADAPTIVE_CONTENTION_THRESHOLD {
+			this.upgrade()
+		}
+	}
+}
+
+//upgrade copies all mappings to a segmented map and replaces the current map
+func (this *AdaptiveMap) upgrade() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.IsUpgraded() {
+		return
+	}
+
+	old := this.load()
+	m := newConcurrentMap3(capacityFor(int(old.Size())), DEFAULT_LOAD_FACTOR, DEFAULT_CONCURRENCY_LEVEL)
+	old.ForEachSegmentLocked(func(entries []Entry) {
+		for _, e := range entries {
+			m.segmentFor(e.hash).putWithExpiration(e.key, e.hash, e.fastValue(), false, nil, e.expireAt)
+			if e.expireAt != 0 {
+				m.scheduleExpiration(e.key, e.hash, e.expireAt)
+			}
+		}
+	})
+
+	atomic.StorePointer(&this.current, unsafe.Pointer(m))
+	atomic.StoreInt32(&this.upgraded, 1)
+	old.Close()
+}
+
+func (this *AdaptiveMap) Get(key interface{}) (value interface{}, err error) {
+	return this.load().Get(key)
+}
+
+func (this *AdaptiveMap) ContainsKey(key interface{}) (found bool, err error) {
+	return this.load().ContainsKey(key)
+}
+
+func (this *AdaptiveMap) Put(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	this.write(func(m *ConcurrentMap) {
+		oldVal, err = m.Put(key, value)
+	})
+	return
+}
+
+func (this *AdaptiveMap) PutIfAbsent(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	this.write(func(m *ConcurrentMap) {
+		oldVal, err = m.PutIfAbsent(key, value)
+	})
+	return
+}
+
+func (this *AdaptiveMap) PutAll(kvs map[interface{}]interface{}) (err error) {
+	this.write(func(m *ConcurrentMap) {
+		err = m.PutAll(kvs)
+	})
+	return
+}
+
+func (this *AdaptiveMap) Update(key interface{}, action func(oldVal interface{}) (newVal interface{})) (oldVal interface{}, err error) {
+	this.write(func(m *ConcurrentMap) {
+		oldVal, err = m.Update(key, action)
+	})
+	return
+}
+
+func (this *AdaptiveMap) Remove(key interface{}) (oldVal interface{}, err error) {
+	this.write(func(m *ConcurrentMap) {
+		oldVal, err = m.Remove(key)
+	})
+	return
+}
+
+func (this *AdaptiveMap) RemoveEntry(key interface{}, value interface{}) (ok bool, err error) {
+	this.write(func(m *ConcurrentMap) {
+		ok, err = m.RemoveEntry(key, value)
+	})
+	return
+}
+
+func (this *AdaptiveMap) Replace(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	this.write(func(m *ConcurrentMap) {
+		oldVal, err = m.Replace(key, value)
+	})
+	return
+}
+
+func (this *AdaptiveMap) CompareAndReplace(key interface{}, oldVal interface{}, newVal interface{}) (ok bool, err error) {
+	this.write(func(m *ConcurrentMap) {
+		ok, err = m.CompareAndReplace(key, oldVal, newVal)
+	})
+	return
+}
+
+func (this *AdaptiveMap) Size() int32 {
+	return this.load().Size()
+}
+
+func (this *AdaptiveMap) IsEmpty() bool {
+	return this.load().IsEmpty()
+}
+
+func (this *AdaptiveMap) Clear() {
+	this.write(func(m *ConcurrentMap) {
+		m.Clear()
+	})
+}
+
+func (this *AdaptiveMap) ToSlice() (kvs []*Entry) {
+	return this.load().ToSlice()
+}
+
+/**
+ * Stops the background goroutines of current map, see ConcurrentMap.Close.
+ */
+func (this *AdaptiveMap) Close() error {
+	return this.load().Close()
+}
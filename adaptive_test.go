@@ -0,0 +1,46 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestAdaptiveMap(t *testing.T) {
+	am := NewAdaptive()
+	if am.IsUpgraded() || len(am.load().segments) != 1 {
+		t.Errorf("New AdaptiveMap has %v segments, want 1", len(am.load().segments))
+	}
+
+	n := 1000
+	for i := 0; i < n; i++ {
+		am.Put(i, i)
+	}
+
+	//simulate the contention
+	contended := &am.load().segments[0].contended
+	*contended = ADAPTIVE_CONTENTION_THRESHOLD
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < int(ADAPTIVE_WINDOW); i++ {
+				am.Put(n+g*int(ADAPTIVE_WINDOW)+i, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if !am.IsUpgraded() || len(am.load().segments) != DEFAULT_CONCURRENCY_LEVEL {
+		t.Errorf("AdaptiveMap has %v segments after contention, want %v", len(am.load().segments), DEFAULT_CONCURRENCY_LEVEL)
+	}
+	want := int32(n + 4*int(ADAPTIVE_WINDOW))
+	if s := am.Size(); s != want {
+		t.Errorf("Get size of AdaptiveMap after upgrade, return %v, want %v", s, want)
+	}
+	for i := 0; i < n; i++ {
+		if v, err := am.Get(i); v != i || err != nil {
+			t.Errorf("Get %v after upgrade, return %v, %v, want %v, nil", i, v, err, i)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package concurrent
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+//entryPad pads Entry on 32-bit platforms, so the 64-bit fields of the entries
+//in []Entry are still 8-byte aligned, it is 0 on 64-bit platforms
+const entryPad = 4 * (8/ptrSize - 1)
+
+func init() {
+	if err := checkAlignment(); err != nil {
+		panic(err)
+	}
+}
+
+/**
+ * Verifies that the 64-bit fields accessed by atomic are 8-byte aligned.
+ * The atomic operations on misaligned 64-bit words panic on 386 and arm,
+ * Go only guarantees that the first word of an allocated struct, array or slice is aligned,
+ * so the 64-bit fields must be placed at the offsets that are multiples of 8,
+ * and the size of the struct must be a multiple of 8 if it is an element of array or slice.
+ */
+func checkAlignment() error {
+	var e Entry
+	var h latencyHistogram
+	var r latencyRecorder
+	offsets := []struct {
+		name   string
+		offset uintptr
+	}{
+		{"Entry.expireAt", unsafe.Offsetof(e.expireAt)},
+		{"Entry.version", unsafe.Offsetof(e.version)},
+		{"Entry.bits", unsafe.Offsetof(e.bits)},
+		{"Entry.tag", unsafe.Offsetof(e.tag)},
+		{"Entry.accessed", unsafe.Offsetof(e.accessed)},
+		{"Entry.staleAt", unsafe.Offsetof(e.staleAt)},
+		{"Entry.written", unsafe.Offsetof(e.written)},
+		{"size of Entry", unsafe.Sizeof(e)},
+		{"latencyHistogram.count", unsafe.Offsetof(h.count)},
+		{"latencyHistogram.sum", unsafe.Offsetof(h.sum)},
+		{"latencyHistogram.errors", unsafe.Offsetof(h.errors)},
+		{"latencyHistogram.counts", unsafe.Offsetof(h.counts)},
+		{"latencyRecorder.put", unsafe.Offsetof(r.put)},
+		{"latencyRecorder.remove", unsafe.Offsetof(r.remove)},
+		{"latencyRecorder.storeLoad", unsafe.Offsetof(r.storeLoad)},
+		{"latencyRecorder.storeSave", unsafe.Offsetof(r.storeSave)},
+	}
+	for _, o := range offsets {
+		if o.offset%8 != 0 {
+			return fmt.Errorf("%v is %v, it is not 8-byte aligned for 64-bit atomic operations", o.name, o.offset)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package concurrent
+
+import (
+	"testing"
+)
+
+//run with GOARCH=386 or GOARCH=arm to test the alignment on 32-bit platforms
+func TestAlignment(t *testing.T) {
+	if err := checkAlignment(); err != nil {
+		t.Errorf("checkAlignment, return %v, want nil", err)
+	}
+
+	//the atomic operations on the entries in slice
+	cm := NewConcurrentMap(WithInlineValues())
+	for i := 0; i < 100; i++ {
+		cm.PutWithTTL(i, 1<<20+i, 0)
+	}
+	cm.ForEachSegmentLocked(func(entries []Entry) {
+		for i := range entries {
+			if v := entries[i].Value(); v != 1<<20+entries[i].Key().(int) || entries[i].expired() {
+				t.Errorf("Get value of entry in slice, return %v, want %v", v, 1<<20+entries[i].Key().(int))
+			}
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package concurrent
+
+import (
+	"unsafe"
+)
+
+/**
+ * Returns an Option that allocates the tables of all segments from one contiguous arena,
+ * the tables are sized to hold expectedSize mappings without rehash.
+ * It improves the locality and reduces the allocator overhead for the maps
+ * whose final size is known approximately at construction.
+ *
+ * The option replaces the initial tables if they are smaller.
+ * A segment allocates its own table if it is rehashed or cleared by ClearAsync or ClearAndReturn,
+ * the fresh tables keep the arena capacity. The arena is released only after all segments
+ * have replaced their tables, so don't underestimate expectedSize.
+ */
+func WithTableArena(expectedSize int) Option {
+	if expectedSize < 0 {
+		panic(IllegalArgError)
+	}
+	return func(m *ConcurrentMap) {
+		n := len(m.segments)
+		c := capacityFor(expectedSize) / n
+		if c*n < capacityFor(expectedSize) {
+			c++
+		}
+		cap := 1
+		for cap < c {
+			cap <<= 1
+		}
+		if cap > MAXIMUM_CAPACITY {
+			cap = MAXIMUM_CAPACITY
+		}
+
+		if cap <= len(m.segments[0].table()) {
+			return
+		}
+		arena := make([]unsafe.Pointer, cap*n)
+		for i, seg := range m.segments {
+			seg.setTable(arena[i*cap : (i+1)*cap : (i+1)*cap])
+			//the fresh tables created by ClearAsync and ClearAndReturn keep the arena capacity
+			seg.initialCapacity = cap
+		}
+	}
+}
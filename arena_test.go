@@ -0,0 +1,36 @@
+package concurrent
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestTableArena(t *testing.T) {
+	n := 10000
+	cm := NewConcurrentMap(WithTableArena(n))
+	tab0, tab1 := cm.segments[0].table(), cm.segments[1].table()
+	if uintptr(unsafe.Pointer(&tab0[0]))+uintptr(len(tab0))*ptrSize != uintptr(unsafe.Pointer(&tab1[0])) {
+		t.Errorf("The tables of segments are not contiguous")
+	}
+
+	for i := 0; i < n; i++ {
+		cm.Put(i, i)
+	}
+	for _, seg := range cm.segments {
+		if len(seg.table()) != len(tab0) {
+			t.Errorf("The segment is rehashed, table size %v, want %v", len(seg.table()), len(tab0))
+		}
+	}
+	for i := 0; i < n; i++ {
+		if v, _ := cm.Get(i); v != i {
+			t.Errorf("Get %v, return %v, want %v", i, v, i)
+		}
+	}
+
+	cm.ClearAndReturn()
+	for _, seg := range cm.segments {
+		if len(seg.table()) != len(tab0) {
+			t.Errorf("The table is shrunk by ClearAndReturn, table size %v, want %v", len(seg.table()), len(tab0))
+		}
+	}
+}
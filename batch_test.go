@@ -0,0 +1,54 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestGetAll(t *testing.T) {
+	cm := NewConcurrentMap(WithValueDecoder(func(stored interface{}) interface{} {
+		return stored.(int) * 10
+	}))
+	keys := make([]interface{}, 0, 200)
+	for i := 0; i < 200; i++ {
+		if i%2 == 0 {
+			cm.Put(i, i)
+		}
+		keys = append(keys, i)
+	}
+
+	values, err := cm.GetAll(keys)
+	if err != nil || len(values) != 200 {
+		t.Errorf("GetAll, return %v values, %v, want 200 values", len(values), err)
+	}
+	for i, v := range values {
+		if (i%2 == 0 && v != i*10) || (i%2 != 0 && v != nil) {
+			t.Errorf("GetAll, return %v for key %v", v, i)
+		}
+	}
+
+	if _, err := cm.GetAll([]interface{}{1, nil}); err != NilKeyError {
+		t.Errorf("GetAll with nil key, return %v, want NilKeyError", err)
+	}
+	if values, _ := NewConcurrentMap().GetAll(keys); values[0] != nil {
+		t.Errorf("GetAll from empty map, return %v, want nil", values[0])
+	}
+
+	//the present keys are always found while the map is rehashing
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1000; i < 20000; i++ {
+			cm.Put(i, i)
+		}
+	}()
+	for n := 0; n < 100; n++ {
+		values, _ := cm.GetAll(keys)
+		if values[0] != 0 || values[198] != 1980 {
+			t.Errorf("GetAll while rehashing, return %v, %v, want 0, 1980", values[0], values[198])
+			break
+		}
+	}
+	wg.Wait()
+}
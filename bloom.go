@@ -0,0 +1,110 @@
+package concurrent
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+const (
+	//every counter of countingBloom has 4 bits, so a word has 8 counters
+	bloomCounterBits  = 4
+	bloomCounterMax   = 1<<bloomCounterBits - 1
+	bloomWordCounters = 32 / bloomCounterBits
+)
+
+/**
+ * countingBloom is a counting Bloom filter of the keys, so the removed keys can be deleted.
+ * A counter sticks at the max value once it overflows, it only causes false positives.
+ */
+type countingBloom struct {
+	words  []uint32
+	size   uint32
+	hashes uint32
+}
+
+/**
+ * Returns an Option that maintains a counting Bloom filter of the present keys on every mutation,
+ * so MightContain can reject most misses without touching segments. It is useful when
+ * the map fronts expensive negative lookups.
+ *
+ * @param expectedKeys the expected number of keys
+ * @param fpRate the false positive rate when the map includes expectedKeys keys, in (0, 1)
+ */
+func WithBloomFilter(expectedKeys int, fpRate float64) Option {
+	if expectedKeys <= 0 || !(fpRate > 0 && fpRate < 1) {
+		panic(IllegalArgError)
+	}
+	size := math.Ceil(-float64(expectedKeys) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	hashes := math.Max(1, math.Round(size/float64(expectedKeys)*math.Ln2))
+	return func(m *ConcurrentMap) {
+		m.bloom = &countingBloom{
+			words:  make([]uint32, (uint32(size)+bloomWordCounters-1)/bloomWordCounters),
+			size:   uint32(size),
+			hashes: uint32(hashes),
+		}
+		m.listeners = append(m.listeners, m.bloom)
+	}
+}
+
+//probe calls f with the index of every counter for hash, the indexes are derived by double hashing
+func (this *countingBloom) probe(hash uint32, f func(i uint32) bool) bool {
+	h1, h2 := hash, hash*0x85ebca6b^hash>>13|1
+	for i := uint32(0); i < this.hashes; i++ {
+		if !f((h1 + i*h2) % this.size) {
+			return false
+		}
+	}
+	return true
+}
+
+func (this *countingBloom) add(hash uint32, delta int) {
+	this.probe(hash, func(i uint32) bool {
+		word, shift := &this.words[i/bloomWordCounters], i%bloomWordCounters*bloomCounterBits
+		for {
+			old := atomic.LoadUint32(word)
+			c := int(old >> shift & bloomCounterMax)
+			if c == bloomCounterMax || (c == 0 && delta < 0) {
+				//the overflowed counter sticks
+				return true
+			}
+			updated := old&^(bloomCounterMax<<shift) | uint32(c+delta)<<shift
+			if atomic.CompareAndSwapUint32(word, old, updated) {
+				return true
+			}
+		}
+	})
+}
+
+func (this *countingBloom) mightContain(hash uint32) bool {
+	return this.probe(hash, func(i uint32) bool {
+		return atomic.LoadUint32(&this.words[i/bloomWordCounters])>>(i%bloomWordCounters*bloomCounterBits)&bloomCounterMax != 0
+	})
+}
+
+func (this *countingBloom) onMutation(key interface{}, hash uint32, oldVal interface{}, newVal interface{}) {
+	if oldVal == nil && newVal != nil {
+		this.add(hash, 1)
+	} else if oldVal != nil && newVal == nil {
+		this.add(hash, -1)
+	}
+}
+
+/**
+ * Tests if the specified key might be in this map by the Bloom filter, without touching segments.
+ * False means the key is definitely not in this map, true means it probably is.
+ *
+ * @return IllegalStateError if WithBloomFilter isn't enabled
+ */
+func (this *ConcurrentMap) MightContain(key interface{}) (ok bool, err error) {
+	if isNil(key) {
+		return false, NilKeyError
+	}
+	if this.bloom == nil {
+		return false, IllegalStateError
+	}
+	hash, err := hashKey(key, this, false)
+	if err != nil {
+		return
+	}
+	return this.bloom.mightContain(hash), nil
+}
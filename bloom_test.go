@@ -0,0 +1,53 @@
+package concurrent
+
+import (
+	"testing"
+)
+
+func TestMightContain(t *testing.T) {
+	if _, err := NewConcurrentMap().MightContain(1); err != IllegalStateError {
+		t.Errorf("MightContain without filter, return %v, want IllegalStateError", err)
+	}
+
+	cm := NewConcurrentMap(WithBloomFilter(10000, 0.01))
+	for i := 0; i < 10000; i++ {
+		cm.Put(i, i)
+	}
+	for i := 0; i < 10000; i++ {
+		if ok, _ := cm.MightContain(i); !ok {
+			t.Errorf("MightContain present key %v, return false, want true", i)
+			break
+		}
+	}
+
+	falsePositives := 0
+	for i := 10000; i < 20000; i++ {
+		if ok, _ := cm.MightContain(i); ok {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("MightContain absent keys, return %v false positives, want about 100", falsePositives)
+	}
+
+	//the removed keys are deleted from the filter
+	for i := 0; i < 10000; i++ {
+		cm.Remove(i)
+	}
+	falsePositives = 0
+	for i := 0; i < 10000; i++ {
+		if ok, _ := cm.MightContain(i); ok {
+			falsePositives++
+		}
+	}
+	if falsePositives > 10 {
+		t.Errorf("MightContain removed keys, return %v false positives, want about 0", falsePositives)
+	}
+
+	cm.Put("k", 1)
+	cm.Put("k", 2)
+	cm.Remove("k")
+	if ok, _ := cm.MightContain("k"); ok {
+		t.Errorf("MightContain after overwrite and Remove, return true, want false")
+	}
+}
@@ -0,0 +1,208 @@
+package concurrent
+
+/**
+ * Builder accepts the mappings of a map that is built once, e.g. a large static map that
+ * is loaded at startup. The mappings are only appended to a slice, there is neither
+ * lock nor rehash until Build or BuildFrozen, so the construction is much faster than Put.
+ *
+ * Builder is not safe for concurrent use, it must be filled by a single goroutine.
+ */
+type Builder struct {
+	opts []Option
+	kvs  []*kvPair
+}
+
+/**
+ * Creates a Builder, the options are applied to the map created by Build.
+ */
+func NewBuilder(opts ...Option) *Builder {
+	return &Builder{opts: opts}
+}
+
+/**
+ * Adds a mapping, the later mapping wins if the keys are same.
+ */
+func (this *Builder) Put(key interface{}, value interface{}) error {
+	if isNil(key) {
+		return NilKeyError
+	}
+	if isNil(value) {
+		return NilValueError
+	}
+	this.kvs = append(this.kvs, &kvPair{key: key, value: value})
+	return nil
+}
+
+/**
+ * Returns the number of mappings that were added, includes the duplicate keys.
+ */
+func (this *Builder) Len() int {
+	return len(this.kvs)
+}
+
+/**
+ * Creates a ConcurrentMap that includes all mappings added to the builder.
+ * The map is sized for the mappings, so no rehash occurs while filling it.
+ * The segments are filled under lock although the map isn't published yet,
+ * because the eviction goroutine of WithEvictionPacing may run while filling.
+ *
+ * @return the map, or error if any key is not supported or any mapping is rejected by WithValidator
+ */
+func (this *Builder) Build() (cm *ConcurrentMap, err error) {
+	cm = newConcurrentMap3(capacityFor(len(this.kvs)),
+		DEFAULT_LOAD_FACTOR, DEFAULT_CONCURRENCY_LEVEL)
+	for _, opt := range this.opts {
+		opt(cm)
+	}
+
+	for _, kv := range this.kvs {
+		if kv.hash, err = hashKey(kv.key, cm, false); err != nil {
+			return nil, err
+		}
+		if err = cm.validate(kv.key, kv.value); err != nil {
+			return nil, err
+		}
+		cm.segmentFor(kv.hash).store(kv.key, kv.hash, kv.value)
+	}
+	return
+}
+
+/**
+ * Creates a FrozenMap that includes all mappings added to the builder.
+ * The options of builder are ignored.
+ *
+ * @return the map, or error if any key is not supported
+ */
+func (this *Builder) BuildFrozen() (fm *FrozenMap, err error) {
+	n := len(this.kvs)
+	capacity := 1
+	for capacity < n {
+		capacity <<= 1
+	}
+	//the map is only used to hash the keys
+	fm = &FrozenMap{
+		hasher: newConcurrentMap3(0, DEFAULT_LOAD_FACTOR, 1),
+		mask:   uint32(capacity - 1),
+		starts: make([]int32, capacity+1),
+	}
+
+	//counts the mappings of every bucket, iterates backwards so only the last mapping of a key is kept
+	last := make(map[*kvPair]bool, n)
+	seen := make([][]*kvPair, capacity)
+	for i := n - 1; i >= 0; i-- {
+		kv := this.kvs[i]
+		if kv.hash, err = hashKey(kv.key, fm.hasher, false); err != nil {
+			return nil, err
+		}
+		index := kv.hash & fm.mask
+		if !containsKey(seen[index], kv) {
+			seen[index] = append(seen[index], kv)
+			last[kv] = true
+			fm.starts[index+1]++
+		}
+	}
+	for i := 0; i < capacity; i++ {
+		fm.starts[i+1] += fm.starts[i]
+	}
+
+	fm.entries = make([]frozenEntry, fm.starts[capacity])
+	next := make([]int32, capacity)
+	copy(next, fm.starts)
+	for _, kv := range this.kvs {
+		if last[kv] {
+			index := kv.hash & fm.mask
+			fm.entries[next[index]] = frozenEntry{kv.key, kv.value, kv.hash}
+			next[index]++
+		}
+	}
+	return
+}
+
+func containsKey(kvs []*kvPair, kv *kvPair) bool {
+	for _, other := range kvs {
+		if other.hash == kv.hash && equals(other.key, kv.key) {
+			return true
+		}
+	}
+	return false
+}
+
+type frozenEntry struct {
+	key   interface{}
+	value interface{}
+	hash  uint32
+}
+
+/**
+ * FrozenMap is an immutable map created by Builder.BuildFrozen.
+ * The entries of a bucket are stored contiguously in a single slice, and the reads
+ * need neither lock nor atomic operation, so it is safe for concurrent use.
+ */
+type FrozenMap struct {
+	hasher *ConcurrentMap
+	mask   uint32
+	//the entries of bucket i are entries[starts[i]:starts[i+1]]
+	starts  []int32
+	entries []frozenEntry
+}
+
+func (this *FrozenMap) find(key interface{}) (e *frozenEntry, err error) {
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+	hash, err := hashKey(key, this.hasher, true)
+	if err != nil {
+		return
+	}
+	index := hash & this.mask
+	for i := this.starts[index]; i < this.starts[index+1]; i++ {
+		if e = &this.entries[i]; e.hash == hash && equals(e.key, key) {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+/**
+ * Returns the value to which the specified key is mapped, or nil if no mapping for the key.
+ */
+func (this *FrozenMap) Get(key interface{}) (value interface{}, err error) {
+	e, err := this.find(key)
+	if e != nil {
+		value = e.value
+	}
+	return
+}
+
+/**
+ * Tests if the specified object is a key in this map.
+ */
+func (this *FrozenMap) ContainsKey(key interface{}) (found bool, err error) {
+	e, err := this.find(key)
+	return e != nil, err
+}
+
+/**
+ * Returns the number of key-value mappings in this map.
+ */
+func (this *FrozenMap) Size() int32 {
+	return int32(len(this.entries))
+}
+
+/**
+ * Returns true if this map contains no key-value mappings.
+ */
+func (this *FrozenMap) IsEmpty() bool {
+	return len(this.entries) == 0
+}
+
+/**
+ * Calls f for every mapping until f returns false.
+ */
+func (this *FrozenMap) Range(f func(key interface{}, value interface{}) bool) {
+	for i := range this.entries {
+		if !f(this.entries[i].key, this.entries[i].value) {
+			return
+		}
+	}
+}
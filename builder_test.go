@@ -0,0 +1,104 @@
+package concurrent
+
+import (
+	"testing"
+	"time"
+)
+
+type builderKey struct {
+	id   int
+	name string
+}
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder(WithMaxEntries(1000))
+	for i := 0; i < 10000; i++ {
+		b.Put(i, i)
+	}
+	b.Put(1, "later")
+	if err := b.Put(nil, 1); err != NilKeyError {
+		t.Errorf("Put nil key, return %v, want NilKeyError", err)
+	}
+	if b.Len() != 10001 {
+		t.Errorf("Len, return %v, want 10001", b.Len())
+	}
+
+	cm, err := b.Build()
+	if err != nil {
+		t.Errorf("Build, return error %v", err)
+	}
+	if v, _ := cm.Get(1); v != "later" {
+		t.Errorf("Get duplicate key from built map, return %v, want later", v)
+	}
+	if size := cm.Size(); size > 1000+int32(len(cm.segments))*evictionSlack {
+		t.Errorf("Size of built map with WithMaxEntries, return %v, want about 1000", size)
+	}
+
+	//the pacing goroutine evicts while the segments are filled
+	b = NewBuilder(WithMaxEntries(64), WithEvictionPacing(1, time.Microsecond))
+	for i := 0; i < 1000; i++ {
+		b.Put(i, i)
+	}
+	cm, _ = b.Build()
+	waitPacingDone(t, cm)
+	if size := cm.Size(); size > 64+int32(len(cm.segments))*evictionSlack {
+		t.Errorf("Size of built map with WithEvictionPacing, return %v, want about 64", size)
+	}
+
+	cm, _ = NewBuilder().Build()
+	if !cm.IsEmpty() {
+		t.Errorf("IsEmpty of empty built map, return false, want true")
+	}
+	cm.Put(1, 1)
+	if v, _ := cm.Get(1); v != 1 {
+		t.Errorf("Get after Put to built map, return %v, want 1", v)
+	}
+}
+
+func TestFrozenMap(t *testing.T) {
+	b := NewBuilder()
+	for i := 0; i < 1000; i++ {
+		b.Put(builderKey{i, "k"}, i)
+	}
+	b.Put(builderKey{1, "k"}, "later")
+
+	fm, err := b.BuildFrozen()
+	if err != nil {
+		t.Errorf("BuildFrozen, return error %v", err)
+	}
+	if fm.Size() != 1000 || fm.IsEmpty() {
+		t.Errorf("Size of frozen map, return %v, want 1000", fm.Size())
+	}
+	for i := 0; i < 1000; i++ {
+		want := interface{}(i)
+		if i == 1 {
+			want = "later"
+		}
+		if v, err := fm.Get(builderKey{i, "k"}); v != want || err != nil {
+			t.Errorf("Get %v from frozen map, return %v, %v, want %v", i, v, err, want)
+		}
+	}
+	if found, _ := fm.ContainsKey(builderKey{1000, "k"}); found {
+		t.Errorf("ContainsKey absent key, return true, want false")
+	}
+	if found, _ := fm.ContainsKey(builderKey{999, "k"}); !found {
+		t.Errorf("ContainsKey present key, return false, want true")
+	}
+	if _, err := fm.Get(nil); err != NilKeyError {
+		t.Errorf("Get nil key, return %v, want NilKeyError", err)
+	}
+
+	n := 0
+	fm.Range(func(key interface{}, value interface{}) bool {
+		n++
+		return n < 10
+	})
+	if n != 10 {
+		t.Errorf("Range with early termination, called %v times, want 10", n)
+	}
+
+	fm, _ = NewBuilder().BuildFrozen()
+	if v, _ := fm.Get(1); v != nil || !fm.IsEmpty() {
+		t.Errorf("Get from empty frozen map, return %v, want nil", v)
+	}
+}
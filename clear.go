@@ -0,0 +1,151 @@
+package concurrent
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+/**
+ * ClearedEntries holds the old tables of segments that were swapped out by ClearAsync.
+ */
+type ClearedEntries struct {
+	tables [][]unsafe.Pointer
+	size   int32
+	done   chan struct{}
+}
+
+/**
+ * Returns the number of mappings that were cleared, including the expired mappings
+ * that have not been removed yet.
+ */
+func (this *ClearedEntries) Size() int32 {
+	return this.size
+}
+
+/**
+ * Returns a channel that is closed after the background disposal is finished.
+ */
+func (this *ClearedEntries) Done() <-chan struct{} {
+	return this.done
+}
+
+/**
+ * Calls f for every mapping that was cleared, the expired mappings are skipped.
+ */
+func (this *ClearedEntries) ForEach(f func(key interface{}, value interface{})) {
+	for _, e := range this.entries(false) {
+		f(e.key, e.fastValue())
+	}
+}
+
+//entries returns all entries in the old tables, the expired entries are included if withExpired is true
+func (this *ClearedEntries) entries(withExpired bool) (entries []*Entry) {
+	entries = make([]*Entry, 0, this.size)
+	for _, tab := range this.tables {
+		for i := 0; i < len(tab); i++ {
+			for e := (*Entry)(atomic.LoadPointer(&tab[i])); e != nil; e = e.next {
+				if withExpired || !e.expired() {
+					entries = append(entries, e)
+				}
+			}
+		}
+	}
+	return
+}
+
+/**
+ * Removes all of the mappings from this map by swapping in fresh tables for all segments,
+ * so the writers are blocked only while swapping, not while the buckets are set to nil one by one.
+ *
+ * The old contents are returned for background disposal. If onRemoved isn't nil,
+ * it is called for every removed mapping in a background goroutine,
+ * the mapping listeners (e.g. distinct value counting) are notified in the same goroutine.
+ *
+ * @param onRemoved the callback for removed mappings, can be nil
+ */
+func (this *ConcurrentMap) ClearAsync(onRemoved func(key interface{}, value interface{})) (cleared *ClearedEntries) {
+	cleared = this.swapTables()
+	if onRemoved == nil && len(this.listeners) == 0 {
+		close(cleared.done)
+		return
+	}
+
+	go func() {
+		defer close(cleared.done)
+		for _, e := range cleared.entries(true) {
+			v := e.fastValue()
+			for _, l := range this.listeners {
+				l.onMutation(e.key, e.hash, v, nil)
+			}
+			if onRemoved != nil && !e.expired() {
+				onRemoved(e.key, v)
+			}
+		}
+	}()
+	return
+}
+
+/**
+ * Removes all of the mappings from this map and returns the removed mappings,
+ * the expired mappings are not returned. It is needed when the map holds
+ * the resources that must be explicitly released on flush.
+ *
+ * Like ClearAsync, the tables of segments are swapped, so the mappings put concurrently
+ * are either returned or kept in map, none of them is lost.
+ */
+func (this *ConcurrentMap) ClearAndReturn() (entries []Entry) {
+	cleared := this.swapTables()
+	close(cleared.done)
+
+	all := cleared.entries(true)
+	entries = make([]Entry, 0, len(all))
+	for _, e := range all {
+		for _, l := range this.listeners {
+			l.onMutation(e.key, e.hash, e.fastValue(), nil)
+		}
+		if !e.expired() {
+			entries = append(entries, e.unlinked())
+		}
+	}
+	return
+}
+
+//swapTables swaps in fresh tables for all segments and returns the old tables
+func (this *ConcurrentMap) swapTables() *ClearedEntries {
+	cleared := &ClearedEntries{
+		tables: make([][]unsafe.Pointer, 0, len(this.segments)),
+		done:   make(chan struct{}),
+	}
+	for _, seg := range this.segments {
+		if tab, count := seg.swapTable(); count > 0 {
+			cleared.tables = append(cleared.tables, tab)
+			cleared.size += count
+		}
+	}
+	if h := this.hotKeys; h != nil {
+		h.invalidateAll()
+	}
+	return cleared
+}
+
+/**
+ * Replaces the table by a fresh table with initial capacity, returns the old table
+ * and the number of entries in old table.
+ */
+func (this *Segment) swapTable() (old []unsafe.Pointer, count int32) {
+	if atomic.LoadInt32(&this.count) == 0 {
+		return nil, 0
+	}
+	this.acquire()
+	defer this.lock.Unlock()
+
+	old, count = this.table(), this.count
+	newTable := make([]unsafe.Pointer, this.initialCapacity)
+	this.threshold = (int32)(float32(len(newTable)) * this.loadFactor)
+	atomic.StorePointer(&this.pTable, unsafe.Pointer(&newTable))
+	atomic.AddInt32(&this.layout, 1)
+	atomic.AddInt32(&this.modCount, 1)
+	atomic.StoreInt32(&this.count, 0)
+	this.m.sizeChanged()
+	return
+}
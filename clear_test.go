@@ -0,0 +1,67 @@
+package concurrent
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClearAsync(t *testing.T) {
+	cm := NewConcurrentMap(WithDistinctValueCounting())
+	n := 1000
+	for i := 0; i < n; i++ {
+		cm.Put(i, i)
+	}
+
+	var removed int32
+	cleared := cm.ClearAsync(func(k interface{}, v interface{}) {
+		atomic.AddInt32(&removed, 1)
+	})
+	if s := cm.Size(); s != 0 || cleared.Size() != int32(n) {
+		t.Errorf("Get size after ClearAsync, return %v, %v, want 0, %v", s, cleared.Size(), n)
+	}
+	cm.Put(1, 1)
+	<-cleared.Done()
+	if removed != int32(n) {
+		t.Errorf("The number of removed mappings, return %v, want %v", removed, n)
+	}
+	if d, _ := cm.ApproxDistinctValues(); d != 1 {
+		t.Errorf("ApproxDistinctValues after ClearAsync, return %v, want 1", d)
+	}
+
+	count := 0
+	cleared.ForEach(func(k interface{}, v interface{}) {
+		count++
+	})
+	if count != n {
+		t.Errorf("ForEach cleared mappings, return %v mappings, want %v", count, n)
+	}
+
+	cleared = NewConcurrentMap().ClearAsync(nil)
+	<-cleared.Done()
+}
+
+func TestClearAndReturn(t *testing.T) {
+	cm := NewConcurrentMap()
+	n := 100
+	for i := 0; i < n; i++ {
+		cm.Put(i, i)
+	}
+	cm.PutWithTTL(n, n, -1)
+	cm.ExpireAt(n, time.Now().Add(-time.Second))
+
+	entries := cm.ClearAndReturn()
+	if len(entries) != n || cm.Size() != 0 {
+		t.Errorf("ClearAndReturn, return %v entries and size %v, want %v and 0", len(entries), cm.Size(), n)
+	}
+	sum := 0
+	for _, e := range entries {
+		if e.Key() != e.Value() {
+			t.Errorf("The returned entry, return %v=%v", e.Key(), e.Value())
+		}
+		sum += e.Value().(int)
+	}
+	if sum != n*(n-1)/2 {
+		t.Errorf("Sum of returned values, return %v, want %v", sum, n*(n-1)/2)
+	}
+}
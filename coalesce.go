@@ -0,0 +1,92 @@
+package concurrent
+
+import (
+	"sync"
+)
+
+/**
+ * coalescer runs a single load for the keys that have same derived key, see WithCoalesceKeyFunc.
+ */
+type coalescer struct {
+	keyFunc  func(key interface{}) interface{}
+	lock     sync.Mutex
+	inflight map[interface{}]*flight
+}
+
+/**
+ * Returns an Option that coalesces the loads of ComputeIfAbsent on the keys derived by keyFunc
+ * rather than the storage keys, e.g. the normalized queries. The concurrent ComputeIfAbsent
+ * of the keys that have same derived key call loader once, and the loaded value is put
+ * for every key of them, so the equivalent requests don't load the same value repeatedly.
+ *
+ * The derived keys must be comparable, and keyFunc is called without lock.
+ */
+func WithCoalesceKeyFunc(keyFunc func(key interface{}) interface{}) Option {
+	if keyFunc == nil {
+		panic(IllegalArgError)
+	}
+	return func(m *ConcurrentMap) {
+		m.coalescer = &coalescer{keyFunc: keyFunc, inflight: make(map[interface{}]*flight)}
+	}
+}
+
+//loading returns true if a load is running for the derived key of key
+func (this *coalescer) loading(key interface{}) bool {
+	ck := this.keyFunc(key)
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	_, ok := this.inflight[ck]
+	return ok
+}
+
+//load waits for the running load of the derived key, or loads key if no load is running
+func (this *coalescer) load(seg *Segment, key interface{}, hash uint32, loader func(key interface{}) (value interface{}, err error)) (value interface{}, err error) {
+	ck := this.keyFunc(key)
+	this.lock.Lock()
+	if f, ok := this.inflight[ck]; ok {
+		this.lock.Unlock()
+		<-f.done
+		if f.err != nil || f.value == nil {
+			return f.value, f.err
+		}
+		//the value loaded for an equivalent key is put for this key too
+		if err = seg.m.validate(key, f.value); err != nil {
+			return nil, err
+		}
+		return seg.storeLoaded(key, hash, f.value), nil
+	}
+	//the error is kept if loader panics
+	f := &flight{done: make(chan struct{}), err: IllegalStateError}
+	this.inflight[ck] = f
+	this.lock.Unlock()
+
+	defer func() {
+		if f.err == nil && !isNil(f.value) {
+			if f.err = seg.m.validate(key, f.value); f.err != nil {
+				f.value = nil
+			}
+		}
+		if f.err == nil && !isNil(f.value) {
+			f.value = seg.storeLoaded(key, hash, f.value)
+		} else if f.err == nil {
+			f.value = nil
+		}
+		this.lock.Lock()
+		delete(this.inflight, ck)
+		this.lock.Unlock()
+		close(f.done)
+		value, err = f.value, f.err
+	}()
+	f.value, f.err = loader(key)
+	return
+}
+
+//storeLoaded puts the loaded value if key is absent, and returns the value of key
+func (this *Segment) storeLoaded(key interface{}, hash uint32, value interface{}) interface{} {
+	this.acquire()
+	defer this.lock.Unlock()
+	if old := this.putUnderLock(key, hash, value, true, nil, 0); old != nil {
+		return old
+	}
+	return value
+}
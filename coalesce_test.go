@@ -0,0 +1,56 @@
+package concurrent
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalesceKeyFunc(t *testing.T) {
+	cm := NewConcurrentMap(WithCoalesceKeyFunc(func(key interface{}) interface{} {
+		return strings.ToLower(key.(string))
+	}))
+	var calls int32
+	started, release := make(chan struct{}), make(chan struct{})
+	loader := func(key interface{}) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return strings.ToLower(key.(string)) + "!", nil
+	}
+
+	wg := new(sync.WaitGroup)
+	keys := []string{"Query", "QUERY", "query", "qUeRy"}
+	for _, k := range keys {
+		wg.Add(1)
+		go func(k string) {
+			defer wg.Done()
+			if v, err := cm.ComputeIfAbsent(k, loader); v != "query!" || err != nil {
+				t.Errorf("ComputeIfAbsent %v, return %v, %v, want query!, nil", k, v, err)
+			}
+		}(k)
+	}
+	<-started
+	if s, _ := cm.State("QuErY"); s != ENTRY_LOADING {
+		t.Errorf("State of equivalent key, return %v, want loading", s)
+	}
+	//the other callers are waiting for the load
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("ComputeIfAbsent of equivalent keys, loader is called %v times, want 1", calls)
+	}
+	for _, k := range keys {
+		if v, _ := cm.Get(k); v != "query!" {
+			t.Errorf("Get %v, return %v, want query!", k, v)
+		}
+	}
+	if s, _ := cm.State("other"); s != ENTRY_ABSENT {
+		t.Errorf("State of absent key, return %v, want absent", s)
+	}
+}
@@ -0,0 +1,169 @@
+package concurrent
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//coarseBuckets is the time buckets of a segment, the bucket of epoch n includes
+//the keys that expire in epoch n-1, it is dropped when epoch n is reached
+type coarseBuckets struct {
+	lock    sync.Mutex
+	buckets map[int64][]wheelTimer
+}
+
+/**
+ * coarseExpiry schedules the expiration of entries in time buckets instead of timing wheel,
+ * see WithCoarseTTL. The scheduling only appends the key to the bucket of its segment,
+ * there is neither global lock nor allocation per entry.
+ */
+type coarseExpiry struct {
+	pending     int64 //atomic, the number of keys in buckets
+	running     int32 //atomic, 1 if the rotating goroutine is running
+	granularity int64
+	segments    []coarseBuckets
+}
+
+/**
+ * Returns an Option that schedules the expirations in coarse time buckets instead of timing wheel,
+ * it is used for the very high write rates of mappings with TTL.
+ *
+ * The mappings that expire in the same interval of granularity are grouped into a bucket,
+ * and the whole bucket is dropped at the end of interval, so the expired mappings are removed
+ * and delivered by Expired at most granularity later than their expiration time.
+ * The expired mappings are still invisible to all read and write operations in time.
+ */
+func WithCoarseTTL(granularity time.Duration) Option {
+	if granularity <= 0 {
+		panic(IllegalArgError)
+	}
+	return func(m *ConcurrentMap) {
+		m.coarseTTL = &coarseExpiry{
+			granularity: int64(granularity),
+			segments:    make([]coarseBuckets, len(m.segments)),
+		}
+	}
+}
+
+/**
+ * Schedules the expiration of key by the coarse buckets if WithCoarseTTL is used,
+ * otherwise by the timing wheel.
+ */
+func (this *ConcurrentMap) scheduleExpiration(key interface{}, hash uint32, expireAt int64) {
+	c := this.coarseTTL
+	if c == nil {
+		this.timingWheel().schedule(key, hash, expireAt)
+		return
+	}
+
+	select {
+	case <-this.closed:
+		//the map has been closed, no goroutine will drop the buckets, like timingWheel.schedule
+		return
+	default:
+	}
+
+	epoch := expireAt/c.granularity + 1
+	b := &c.segments[this.segmentIndex(hash)]
+	b.lock.Lock()
+	if b.buckets == nil {
+		b.buckets = make(map[int64][]wheelTimer)
+	}
+	b.buckets[epoch] = append(b.buckets[epoch], wheelTimer{key, hash, epoch})
+	b.lock.Unlock()
+
+	atomic.AddInt64(&c.pending, 1)
+	if atomic.CompareAndSwapInt32(&c.running, 0, 1) {
+		go this.rotateCoarseBuckets(c)
+	}
+}
+
+//rotateCoarseBuckets drops the buckets at every epoch, it exits if the map is closed
+//or there is no key in buckets
+func (this *ConcurrentMap) rotateCoarseBuckets(c *coarseExpiry) {
+	ticker := time.NewTicker(time.Duration(c.granularity))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-this.closed:
+			atomic.StoreInt32(&c.running, 0)
+			return
+		}
+
+		now := time.Now().UnixNano()
+		epoch := now / c.granularity
+		for i, seg := range this.segments {
+			b := &c.segments[i]
+			var due []wheelTimer
+			b.lock.Lock()
+			for ep, refs := range b.buckets {
+				if ep <= epoch {
+					due = append(due, refs...)
+					delete(b.buckets, ep)
+				}
+			}
+			b.lock.Unlock()
+
+			if len(due) > 0 {
+				seg.expireAll(due, now)
+				atomic.AddInt64(&c.pending, -int64(len(due)))
+			}
+		}
+
+		if atomic.LoadInt64(&c.pending) == 0 {
+			atomic.StoreInt32(&c.running, 0)
+			//a key may be scheduled after checking pending
+			if atomic.LoadInt64(&c.pending) == 0 || !atomic.CompareAndSwapInt32(&c.running, 0, 1) {
+				return
+			}
+		}
+	}
+}
+
+/**
+ * Calls f with the keys of every epoch in the order of epoch until f returns false,
+ * like timingWheel.walk.
+ */
+func (this *coarseExpiry) walk(f func(timers []*wheelTimer) bool) {
+	merged := make(map[int64][]*wheelTimer)
+	for i := range this.segments {
+		b := &this.segments[i]
+		b.lock.Lock()
+		for ep, refs := range b.buckets {
+			for j := range refs {
+				t := refs[j]
+				merged[ep] = append(merged[ep], &t)
+			}
+		}
+		b.lock.Unlock()
+	}
+
+	epochs := make([]int64, 0, len(merged))
+	for ep := range merged {
+		epochs = append(epochs, ep)
+	}
+	sort.Slice(epochs, func(i, j int) bool {
+		return epochs[i] < epochs[j]
+	})
+	for _, ep := range epochs {
+		if !f(merged[ep]) {
+			return
+		}
+	}
+}
+
+/**
+ * Removes the entries of keys that have expired at the specified time under a single lock acquisition.
+ */
+func (this *Segment) expireAll(timers []wheelTimer, now int64) {
+	this.acquire()
+	defer this.lock.Unlock()
+
+	for _, t := range timers {
+		this.expireUnderLock(t.key, t.hash, now)
+	}
+}
@@ -0,0 +1,55 @@
+package concurrent
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithCoarseTTL(t *testing.T) {
+	cm := NewConcurrentMap(WithCoarseTTL(20 * time.Millisecond))
+	defer cm.Close()
+	for i := 0; i < 100; i++ {
+		cm.PutWithTTL(i, i, 10*time.Millisecond)
+	}
+	cm.PutWithTTL(100, 100, time.Hour)
+	//the expiration time is changed, so the key in first bucket is stale
+	cm.PutWithTTL(0, 0, time.Hour)
+
+	if exps := cm.NextExpirations(3); len(exps) != 3 || exps[0].ExpireAt.After(exps[1].ExpireAt) {
+		t.Errorf("NextExpirations, return %v, want 3 expirations in order", exps)
+	}
+
+	time.Sleep(15 * time.Millisecond)
+	//the expired mappings are invisible before their bucket is dropped
+	if v, _ := cm.Get(1); v != nil {
+		t.Errorf("Get 1 after expiration, return %v, want nil", v)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if s := cm.Size(); s != 2 {
+		t.Errorf("Get size after buckets are dropped, return %v, want 2", s)
+	}
+	if v, _ := cm.Get(0); v != 0 {
+		t.Errorf("Get 0 with new TTL, return %v, want 0", v)
+	}
+	if exps := cm.NextExpirations(10); len(exps) != 2 {
+		t.Errorf("NextExpirations, return %v, want 2 expirations", exps)
+	}
+}
+
+func TestCoarseTTLAfterClose(t *testing.T) {
+	cm := NewConcurrentMap(WithCoarseTTL(20 * time.Millisecond))
+	cm.Close()
+	cm.PutWithTTL(1, 1, time.Hour)
+
+	if n := atomic.LoadInt64(&cm.coarseTTL.pending); n != 0 {
+		t.Errorf("Get pending keys after Close, return %v, want 0", n)
+	}
+	if exps := cm.NextExpirations(1); len(exps) != 0 {
+		t.Errorf("NextExpirations after Close, return %v, want no expiration", exps)
+	}
+	if v, _ := cm.Get(1); v != 1 {
+		t.Errorf("Get 1 after Close, return %v, want 1", v)
+	}
+}
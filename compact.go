@@ -0,0 +1,62 @@
+package concurrent
+
+import (
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+/**
+ * Rebuilds the table of every segment at the smallest power-of-two capacity
+ * that fits the current mappings, and removes the expired mappings.
+ * It reclaims the memory after a large delete wave, because the tables never shrink.
+ *
+ * Every segment is locked while rebuilding its table, so the writers of that segment are blocked.
+ */
+func (this *ConcurrentMap) Compact() {
+	for _, seg := range this.segments {
+		seg.compact()
+	}
+}
+
+func (this *Segment) compact() {
+	this.acquire()
+	defer this.lock.Unlock()
+
+	now := time.Now().UnixNano()
+	tab := this.table()
+	live := make([]*Entry, 0, this.count)
+	for i := 0; i < len(tab); i++ {
+		for e := (*Entry)(tab[i]); e != nil; e = e.next {
+			if e.isExpired(now) {
+				this.m.expiredNotifier.notify(e)
+				this.mutated(e.key, e.hash, e.fastValue(), nil)
+			} else {
+				live = append(live, e)
+			}
+		}
+	}
+
+	cap := 1
+	for float32(cap)*this.loadFactor < float32(len(live)) && cap < MAXIMUM_CAPACITY {
+		cap <<= 1
+	}
+	if cap == len(tab) && len(live) == int(this.count) {
+		return
+	}
+
+	//the entries are cloned, because the readers may be traversing the old table
+	newTable := make([]unsafe.Pointer, cap)
+	for _, e := range live {
+		idx := e.hash & uint32(cap-1)
+		newTable[idx] = unsafe.Pointer(e.clone((*Entry)(newTable[idx])))
+	}
+	this.threshold = (int32)(float32(cap) * this.loadFactor)
+	atomic.StorePointer(&this.pTable, unsafe.Pointer(&newTable))
+	atomic.AddInt32(&this.layout, 1)
+	atomic.AddInt32(&this.modCount, 1)
+	if len(live) != int(this.count) {
+		atomic.StoreInt32(&this.count, int32(len(live)))
+		this.m.sizeChanged()
+	}
+}
@@ -0,0 +1,47 @@
+package concurrent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompact(t *testing.T) {
+	cm := NewConcurrentMap()
+	n := 10000
+	for i := 0; i < n; i++ {
+		cm.Put(i, i)
+	}
+	for i := 10; i < n; i++ {
+		cm.Remove(i)
+	}
+	cm.PutWithTTL("expired", 1, time.Hour)
+	cm.ExpireAt("expired", time.Now().Add(-time.Second))
+
+	before := 0
+	for _, seg := range cm.segments {
+		before += len(seg.table())
+	}
+	cm.Compact()
+	after := 0
+	for _, seg := range cm.segments {
+		after += len(seg.table())
+	}
+
+	if after >= before || after > 2*len(cm.segments) {
+		t.Errorf("Total capacity after Compact, return %v, want less than %v", after, before)
+	}
+	if s := cm.Size(); s != 10 {
+		t.Errorf("Get size after Compact, return %v, want 10", s)
+	}
+	for i := 0; i < 10; i++ {
+		if v, _ := cm.Get(i); v != i {
+			t.Errorf("Get %v after Compact, return %v, want %v", i, v, i)
+		}
+	}
+	for i := 10; i < 1000; i++ {
+		cm.Put(i, i)
+	}
+	if s := cm.Size(); s != 1000 {
+		t.Errorf("Get size after Put, return %v, want 1000", s)
+	}
+}
@@ -0,0 +1,134 @@
+package concurrent
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"unsafe"
+)
+
+/**
+ * Codec compresses and decompresses the values, see WithCompression.
+ * It must be safe for concurrent use.
+ */
+type Codec interface {
+	Encode(src []byte) ([]byte, error)
+	Decode(src []byte) ([]byte, error)
+}
+
+/**
+ * FlateCodec is a Codec that uses the DEFLATE format of compress/flate.
+ */
+type FlateCodec struct {
+	//the compression level of flate, 0 means flate.DefaultCompression
+	Level int
+}
+
+func (this FlateCodec) Encode(src []byte) ([]byte, error) {
+	level := this.Level
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(src); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (this FlateCodec) Decode(src []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+//sameValue compares the values by ==, but the []byte values, e.g. the decompressed values,
+//are compared by content because []byte is not comparable
+func sameValue(v1, v2 interface{}) bool {
+	b1, ok1 := v1.([]byte)
+	b2, ok2 := v2.([]byte)
+	if ok1 || ok2 {
+		return ok1 && ok2 && bytes.Equal(b1, b2)
+	}
+	return v1 == v2
+}
+
+//compressedValue is a string or []byte value that is stored in compressed form
+type compressedValue struct {
+	codec    Codec
+	data     []byte
+	isString bool
+}
+
+//decompress rebuilds the value, it panics if the codec cannot decode the data that it encoded
+func (this *compressedValue) decompress() interface{} {
+	b, err := this.codec.Decode(this.data)
+	if err != nil {
+		panic(err)
+	}
+	if this.isString {
+		return string(b)
+	}
+	return b
+}
+
+/**
+ * Returns an Option that compresses the string and []byte values whose length >= threshold by codec,
+ * and decompresses them transparently when they are read, e.g. Get, the iterators and
+ * the previous values returned by Put. It shrinks the memory for the caches of large
+ * JSON or HTML blobs, but every read of a compressed value costs a decompression.
+ *
+ * A value is stored uncompressed if codec fails or the compressed form isn't smaller.
+ * The []byte returned by a read of compressed value is a new slice every time,
+ * so CompareAndReplace and RemoveEntry compare the []byte values by content.
+ */
+func WithCompression(codec Codec, threshold int) Option {
+	if codec == nil || threshold < 0 {
+		panic(IllegalArgError)
+	}
+	return func(m *ConcurrentMap) {
+		m.codec, m.compressThreshold = codec, threshold
+	}
+}
+
+//compress returns the compressed form of v, or nil if v isn't compressed
+func (this *ConcurrentMap) compress(v interface{}) *compressedValue {
+	var src []byte
+	isString := false
+	switch x := v.(type) {
+	case []byte:
+		src = x
+	case string:
+		src, isString = []byte(x), true
+	default:
+		return nil
+	}
+	if len(src) < this.compressThreshold {
+		return nil
+	}
+
+	data, err := this.codec.Encode(src)
+	if err != nil || len(data) >= len(src) {
+		return nil
+	}
+	return &compressedValue{codec: this.codec, data: data, isString: isString}
+}
+
+//compressedEntryValue returns the compressed representation of v, ok is false if v isn't compressed
+func (this *ConcurrentMap) compressedEntryValue(v interface{}) (ev entryValue, ok bool) {
+	if this.codec == nil {
+		return
+	}
+	if c := this.compress(v); c != nil {
+		ev.kind, ev.ptr = valueCompressed, unsafe.Pointer(c)
+		return ev, true
+	}
+	return
+}
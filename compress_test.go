@@ -0,0 +1,91 @@
+package concurrent
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithCompression(t *testing.T) {
+	cm := NewConcurrentMap(WithCompression(FlateCodec{}, 64))
+	large := strings.Repeat("<html></html>", 100)
+	cm.Put(1, large)
+	cm.Put(2, []byte(large))
+	cm.Put(3, "small")
+
+	if v, _ := cm.Get(1); v != large {
+		t.Errorf("Get compressed string, return %v, want %v", v, large)
+	}
+	if v, _ := cm.Get(2); !bytes.Equal(v.([]byte), []byte(large)) {
+		t.Errorf("Get compressed []byte, return %v, want %v", v, large)
+	}
+	if v, _ := cm.Get(3); v != "small" {
+		t.Errorf("Get small string, return %v, want small", v)
+	}
+	if ok, _ := cm.CompareAndReplace(1, large, "small"); !ok {
+		t.Errorf("CompareAndReplace compressed string, return false, want true")
+	}
+	if old, _ := cm.Put(1, large); old != "small" {
+		t.Errorf("Put 1, return %v, want small", old)
+	}
+	if old, _ := cm.Put(1, 1); old != large {
+		t.Errorf("Put 1, return %v, want %v", old, large)
+	}
+
+	compressed := 0
+	for _, seg := range cm.segments {
+		seg.walk(func(e *Entry) {
+			if e.vkind == valueCompressed {
+				compressed++
+			}
+		})
+	}
+	if compressed != 1 {
+		t.Errorf("Count compressed entries, return %v, want 1", compressed)
+	}
+	for itr := cm.Iterator(); itr.HasNext(); {
+		if k, v, _ := itr.Next(); k == 2 && !bytes.Equal(v.([]byte), []byte(large)) {
+			t.Errorf("Iterate compressed []byte, return %v, want %v", v, large)
+		}
+	}
+}
+
+//countingCodec counts the decodes of FlateCodec
+type countingCodec struct {
+	FlateCodec
+	decodes int32
+}
+
+func (this *countingCodec) Decode(src []byte) ([]byte, error) {
+	atomic.AddInt32(&this.decodes, 1)
+	return this.FlateCodec.Decode(src)
+}
+
+func TestCompressionDecodes(t *testing.T) {
+	codec := &countingCodec{}
+	cm := NewConcurrentMap(WithCompression(codec, 16))
+	large := strings.Repeat("compressible ", 100)
+	cm.Put(1, []byte(large))
+	cm.Put(2, large)
+
+	//the overwrites that don't return the previous value don't decompress it
+	cm.PutAll(map[interface{}]interface{}{1: []byte(large), 2: large})
+	if n := atomic.LoadInt32(&codec.decodes); n != 0 {
+		t.Errorf("decodes after PutAll, is %v, want 0", n)
+	}
+
+	//the []byte values are compared by content
+	if ok, err := cm.CompareAndReplace(1, []byte(large), []byte("small")); !ok || err != nil {
+		t.Errorf("CompareAndReplace compressed []byte, return %v, %v, want true, nil", ok, err)
+	}
+	if ok, _ := cm.RemoveEntry(1, []byte("other")); ok {
+		t.Errorf("RemoveEntry with different []byte, return true, want false")
+	}
+	if ok, _ := cm.RemoveEntry(1, []byte("small")); !ok {
+		t.Errorf("RemoveEntry with same []byte, return false, want true")
+	}
+	if ok, _ := cm.CompareAndReplace(2, []byte(large), "small"); ok {
+		t.Errorf("CompareAndReplace string by []byte, return true, want false")
+	}
+}
@@ -0,0 +1,190 @@
+package concurrent
+
+/**
+ * flight is a load of ComputeIfAbsent that is running, the callers of same key wait for it.
+ */
+type flight struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+	//the key that is being loaded, it is nil for the loads of coalescer
+	key interface{}
+}
+
+/**
+ * Returns the value of key, if the key is absent, calls loader to compute the value and puts it.
+ * The loader runs at most once per key at a time, the concurrent callers of same key
+ * wait for the running loader and receive its result, so the value is never computed
+ * multiple times like racing PutIfAbsent.
+ *
+ * The loader is called without lock, so it may be slow, but it must not call ComputeIfAbsent
+ * with same key, otherwise it deadlocks. If the loader returns an error or nil value,
+ * nothing is put and the result is returned to all waiting callers, the later callers
+ * will call loader again. If the key is put by others while loading, the loaded value is dropped
+ * and the existing value is returned.
+ *
+ * @return the current or loaded value, or the error of loader, or ValidationError if the loaded value
+ *         is rejected by WithValidator, the waiting callers receive IllegalStateError if the loader panics
+ */
+func (this *ConcurrentMap) ComputeIfAbsent(key interface{}, loader func(key interface{}) (value interface{}, err error)) (value interface{}, err error) {
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+	if loader == nil {
+		return nil, NilActionError
+	}
+	defer this.recoverCallback(&err)
+
+	hash, err := hashKey(key, this, false)
+	if err != nil {
+		return
+	}
+	Printf("ComputeIfAbsent, %v, %v\n", key, hash)
+	seg := this.segmentFor(hash)
+	if v := seg.get(key, hash); v != nil {
+		return this.decode(v), nil
+	}
+	if c := this.coalescer; c != nil {
+		value, err = c.load(seg, key, hash, loader)
+	} else {
+		value, err = seg.computeIfAbsent(key, hash, loader)
+	}
+	return this.decode(value), err
+}
+
+//computeIfAbsent waits for the running load of key, or loads it if no load is running
+func (this *Segment) computeIfAbsent(key interface{}, hash uint32, loader func(key interface{}) (value interface{}, err error)) (value interface{}, err error) {
+	this.acquire()
+	if e := this.findUnderLock(key, hash); e != nil {
+		value = e.fastValue()
+		this.lock.Unlock()
+		return
+	}
+	if f := this.findFlightUnderLock(key, hash); f != nil {
+		this.lock.Unlock()
+		<-f.done
+		return f.value, f.err
+	}
+	//the error is kept if loader panics
+	f := &flight{done: make(chan struct{}), err: IllegalStateError, key: key}
+	this.addFlightUnderLock(hash, f)
+	this.lock.Unlock()
+
+	defer func() {
+		if f.err == nil && !isNil(f.value) {
+			if f.err = this.m.validate(key, f.value); f.err != nil {
+				f.value = nil
+			}
+		}
+		this.acquire()
+		if f.err == nil && !isNil(f.value) {
+			if old := this.putUnderLock(key, hash, f.value, true, nil, 0); old != nil {
+				f.value = old
+			}
+		} else if f.err == nil {
+			f.value = nil
+		}
+		this.removeFlightUnderLock(hash, f)
+		this.lock.Unlock()
+		close(f.done)
+		value, err = f.value, f.err
+	}()
+	f.value, f.err = loader(key)
+	return
+}
+
+//findFlightUnderLock returns the running load of key, the loads are found by hash and equals
+//like the entries, so the keys need not be comparable, e.g. the slices that implement Hashable.
+//Call only while holding lock.
+func (this *Segment) findFlightUnderLock(key interface{}, hash uint32) *flight {
+	for _, f := range this.inflight[hash] {
+		if equals(f.key, key) {
+			return f
+		}
+	}
+	return nil
+}
+
+//addFlightUnderLock records the running load f, call only while holding lock
+func (this *Segment) addFlightUnderLock(hash uint32, f *flight) {
+	if this.inflight == nil {
+		this.inflight = make(map[uint32][]*flight)
+	}
+	this.inflight[hash] = append(this.inflight[hash], f)
+}
+
+//removeFlightUnderLock removes the finished load f, call only while holding lock
+func (this *Segment) removeFlightUnderLock(hash uint32, f *flight) {
+	flights := this.inflight[hash]
+	for i := range flights {
+		if flights[i] == f {
+			flights = append(flights[:i], flights[i+1:]...)
+			break
+		}
+	}
+	if len(flights) == 0 {
+		delete(this.inflight, hash)
+	} else {
+		this.inflight[hash] = flights
+	}
+}
+
+/**
+ * Computes the new value of key from its current value atomically, like Compute of
+ * Java ConcurrentHashMap. The remapping function receives nil if no mapping for key,
+ * and the mapping is removed if it returns nil. It is called while holding the segment lock,
+ * so it should be short and must not access this map.
+ *
+ * Unlike Update, it returns the new value rather than the previous value.
+ *
+ * @return the new value, or nil if the mapping was removed or not created
+ */
+func (this *ConcurrentMap) Compute(key interface{}, remapping func(key interface{}, oldVal interface{}) (newVal interface{})) (value interface{}, err error) {
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+	if remapping == nil {
+		return nil, NilActionError
+	}
+	defer this.recoverCallback(&err)
+	defer recoverRejection(&err)
+
+	hash, err := hashKey(key, this, false)
+	if err != nil {
+		return
+	}
+	Printf("Compute, %v, %v\n", key, hash)
+	this.segmentFor(hash).put(key, hash, nil, false, func(oldVal interface{}) interface{} {
+		newVal := remapping(key, oldVal)
+		if isNil(newVal) {
+			newVal = nil
+		}
+		this.checkComputed(key, newVal)
+		value = newVal
+		return value
+	})
+	return this.decode(value), nil
+}
+
+/**
+ * Stores value if the key is absent, otherwise combines the current value and value by mergeFunc
+ * atomically, like Merge of Java ConcurrentHashMap. The mapping is removed if mergeFunc returns nil.
+ * It is the building block of concurrent aggregations, e.g. counters and set unions.
+ * mergeFunc is called while holding the segment lock, so it should be short and must not access this map.
+ *
+ * @return the new value, or nil if the mapping was removed
+ */
+func (this *ConcurrentMap) Merge(key interface{}, value interface{}, mergeFunc func(oldVal interface{}, value interface{}) (newVal interface{})) (newVal interface{}, err error) {
+	if isNil(value) {
+		return nil, NilValueError
+	}
+	if mergeFunc == nil {
+		return nil, NilActionError
+	}
+	return this.Compute(key, func(key interface{}, oldVal interface{}) interface{} {
+		if oldVal == nil {
+			return value
+		}
+		return mergeFunc(oldVal, value)
+	})
+}
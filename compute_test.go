@@ -0,0 +1,217 @@
+package concurrent
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestComputeIfAbsent(t *testing.T) {
+	cm := NewConcurrentMap()
+	var calls int32
+	loader := func(key interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return key.(int) * 10, nil
+	}
+
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 20; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := cm.ComputeIfAbsent(1, loader); v != 10 || err != nil {
+				t.Errorf("ComputeIfAbsent, return %v, %v, want 10, nil", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("ComputeIfAbsent, loader is called %v times, want 1", calls)
+	}
+	if v, _ := cm.ComputeIfAbsent(1, loader); v != 10 || calls != 1 {
+		t.Errorf("ComputeIfAbsent of present key, return %v, calls %v, want 10, 1", v, calls)
+	}
+
+	//the error is not cached
+	loadErr := errors.New("load failed")
+	if v, err := cm.ComputeIfAbsent(2, func(key interface{}) (interface{}, error) {
+		return nil, loadErr
+	}); v != nil || err != loadErr {
+		t.Errorf("ComputeIfAbsent with error, return %v, %v, want nil, %v", v, err, loadErr)
+	}
+	if v, _ := cm.ComputeIfAbsent(2, loader); v != 20 {
+		t.Errorf("ComputeIfAbsent after error, return %v, want 20", v)
+	}
+
+	if _, err := cm.ComputeIfAbsent(nil, loader); err != NilKeyError {
+		t.Errorf("ComputeIfAbsent nil key, return %v, want NilKeyError", err)
+	}
+	if _, err := cm.ComputeIfAbsent(3, nil); err != NilActionError {
+		t.Errorf("ComputeIfAbsent nil loader, return %v, want NilActionError", err)
+	}
+}
+
+func TestComputeIfAbsentPanic(t *testing.T) {
+	cm := NewConcurrentMap(WithSoftFail())
+	started, release := make(chan struct{}), make(chan struct{})
+	go func() {
+		cm.ComputeIfAbsent(1, func(key interface{}) (interface{}, error) {
+			close(started)
+			<-release
+			panic("bad loader")
+		})
+	}()
+	<-started
+
+	result := make(chan error)
+	go func() {
+		_, err := cm.ComputeIfAbsent(1, func(key interface{}) (interface{}, error) {
+			return 1, nil
+		})
+		result <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if err := <-result; err != IllegalStateError {
+		t.Errorf("ComputeIfAbsent while loader panics, return %v, want IllegalStateError", err)
+	}
+
+	if _, err := cm.ComputeIfAbsent(1, func(key interface{}) (interface{}, error) {
+		panic("bad loader")
+	}); err == nil {
+		t.Errorf("ComputeIfAbsent with panicking loader, return nil, want PanicError")
+	} else if _, ok := err.(*PanicError); !ok {
+		t.Errorf("ComputeIfAbsent with panicking loader, return %v, want PanicError", err)
+	}
+	if v, _ := cm.Get(1); v != nil {
+		t.Errorf("Get after panicking loader, return %v, want nil", v)
+	}
+}
+
+//sliceKey is a Hashable key that isn't comparable by ==
+type sliceKey []byte
+
+func (this sliceKey) HashBytes() []byte {
+	return this
+}
+
+func (this sliceKey) Equals(v2 interface{}) bool {
+	k2, ok := v2.(sliceKey)
+	return ok && bytes.Equal(this, k2)
+}
+
+func TestComputeIfAbsentHashableSlice(t *testing.T) {
+	cm := NewConcurrentMap()
+	started, release := make(chan struct{}), make(chan struct{})
+	result := make(chan interface{})
+	go func() {
+		v, _ := cm.ComputeIfAbsent(sliceKey("a"), func(key interface{}) (interface{}, error) {
+			close(started)
+			<-release
+			return 1, nil
+		})
+		result <- v
+	}()
+	<-started
+	if s, _ := cm.State(sliceKey("a")); s != ENTRY_LOADING {
+		t.Errorf("State of slice key while loading, return %v, want ENTRY_LOADING", s)
+	}
+	close(release)
+	if v := <-result; v != 1 {
+		t.Errorf("ComputeIfAbsent slice key, return %v, want 1", v)
+	}
+
+	//the segment is still usable
+	if v, err := cm.ComputeIfAbsent(sliceKey("a"), func(key interface{}) (interface{}, error) { return 2, nil }); v != 1 || err != nil {
+		t.Errorf("ComputeIfAbsent present slice key, return %v, %v, want 1, nil", v, err)
+	}
+	if _, err := cm.PutWithTimeout(sliceKey("b"), 2, time.Second); err != nil {
+		t.Errorf("PutWithTimeout after ComputeIfAbsent, return %v, want nil", err)
+	}
+	if v, err := cm.GetWithTimeout(sliceKey("b"), time.Second); v != 2 || err != nil {
+		t.Errorf("GetWithTimeout slice key, return %v, %v, want 2, nil", v, err)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	cm := NewConcurrentMap()
+	add := func(key interface{}, oldVal interface{}) interface{} {
+		if oldVal == nil {
+			return 1
+		}
+		return oldVal.(int) + 1
+	}
+
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				cm.Compute("n", add)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := cm.Get("n"); v != 1000 {
+		t.Errorf("Compute concurrently, Get return %v, want 1000", v)
+	}
+	if v, err := cm.Compute("n", add); v != 1001 || err != nil {
+		t.Errorf("Compute, return %v, %v, want 1001, nil", v, err)
+	}
+
+	//returning nil removes the mapping
+	if v, _ := cm.Compute("n", func(key interface{}, oldVal interface{}) interface{} {
+		return nil
+	}); v != nil || cm.Size() != 0 {
+		t.Errorf("Compute returning nil, return %v, Size is %v, want nil, 0", v, cm.Size())
+	}
+	if _, err := cm.Compute(nil, add); err != NilKeyError {
+		t.Errorf("Compute nil key, return %v, want NilKeyError", err)
+	}
+	if _, err := cm.Compute(1, nil); err != NilActionError {
+		t.Errorf("Compute nil remapping, return %v, want NilActionError", err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	cm := NewConcurrentMap()
+	sum := func(oldVal interface{}, value interface{}) interface{} {
+		return oldVal.(int) + value.(int)
+	}
+
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				cm.Merge("n", 2, sum)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := cm.Get("n"); v != 2000 {
+		t.Errorf("Merge concurrently, Get return %v, want 2000", v)
+	}
+
+	//the value is stored as is if the key is absent
+	if v, err := cm.Merge("m", 5, sum); v != 5 || err != nil {
+		t.Errorf("Merge absent key, return %v, %v, want 5, nil", v, err)
+	}
+	if v, _ := cm.Merge("m", 5, func(oldVal interface{}, value interface{}) interface{} {
+		return nil
+	}); v != nil || cm.Size() != 1 {
+		t.Errorf("Merge returning nil, return %v, Size is %v, want nil, 1", v, cm.Size())
+	}
+	if _, err := cm.Merge("m", nil, sum); err != NilValueError {
+		t.Errorf("Merge nil value, return %v, want NilValueError", err)
+	}
+	if _, err := cm.Merge(nil, 1, sum); err != NilKeyError {
+		t.Errorf("Merge nil key, return %v, want NilKeyError", err)
+	}
+}
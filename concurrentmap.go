@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -51,15 +52,25 @@ const (
 	 * which would make it impossible to obtain an accurate result.
 	 */
 	RETRIES_BEFORE_LOCK int = 2
+
+	/**
+	 * The duration of a tick of the timing wheel that schedules the
+	 * expiration of entries. Expired entries are removed at the first
+	 * tick after their expiration time, but they are never visible to
+	 * readers after the expiration time.
+	 */
+	EXPIRATION_TICK time.Duration = 10 * time.Millisecond
 )
 
 var (
-	Debug           = false
-	NilKeyError     = errors.New("Do not support nil as key")
-	NilValueError   = errors.New("Do not support nil as value")
-	NilActionError  = errors.New("Do not support nil as action")
-	NonSupportKey   = errors.New("Non support for pointer, interface, channel, slice, map and function ")
-	IllegalArgError = errors.New("IllegalArgumentException")
+	Debug             = false
+	NilKeyError       = errors.New("Do not support nil as key")
+	NilValueError     = errors.New("Do not support nil as value")
+	NilActionError    = errors.New("Do not support nil as action")
+	NonSupportKey     = errors.New("Non support for pointer, interface, channel, slice, map and function ")
+	IllegalArgError   = errors.New("IllegalArgumentException")
+	IllegalStateError = errors.New("IllegalStateException")
+	TimeoutError      = errors.New("TimeoutException")
 )
 
 type Hashable interface {
@@ -71,6 +82,20 @@ type hashEnginer struct {
 	putFunc func(w io.Writer, v interface{})
 }
 
+/**
+ * Option configures an optional feature of ConcurrentMap,
+ * it can be passed into NewConcurrentMap.
+ */
+type Option func(m *ConcurrentMap)
+
+//mutationListener is notified of every change of the value that mapping a key,
+//oldVal is nil if a mapping is added, and newVal is nil if a mapping is removed.
+//It is called while holding segment lock, so must be fast and must not call the map,
+//except that the removals of ClearAsync are notified in background without lock.
+type mutationListener interface {
+	onMutation(key interface{}, hash uint32, oldVal interface{}, newVal interface{})
+}
+
 //segments is read-only, don't need synchronized
 type ConcurrentMap struct {
 	engChecker *Once
@@ -95,6 +120,131 @@ type ConcurrentMap struct {
 	 * The segments, each of which is a specialized hash table
 	 */
 	segments []*Segment
+
+	/**
+	 * The timing wheel that schedules the expiration of entries,
+	 * it is created when the first entry with TTL is put.
+	 */
+	wheelChecker *Once
+	wheel        *timingWheel
+
+	/**
+	 * The coarse time buckets that replace the timing wheel, it is nil if WithCoarseTTL isn't used.
+	 */
+	coarseTTL *coarseExpiry
+
+	/**
+	 * The fraction that TTL is randomized by, see WithTTLJitter.
+	 */
+	ttlJitter float64
+
+	/**
+	 * Transforms the stored values on Get, see WithValueDecoder.
+	 */
+	decoder func(stored interface{}) interface{}
+
+	/**
+	 * True if the panics of user callbacks are returned as PanicError, see WithSoftFail.
+	 */
+	softFail bool
+
+	/**
+	 * Compresses the large values if it isn't nil, see WithCompression.
+	 */
+	codec             Codec
+	compressThreshold int
+
+	/**
+	 * Delivers the expired entries to the channels returned by Expired.
+	 */
+	expiredNotifier expiredNotifier
+
+	/**
+	 * The callbacks registered by OnSizeAbove and OnSizeBelow.
+	 */
+	sizeWatchers sizeWatchers
+
+	/**
+	 * The listeners of mutations, they are added by options while constructing,
+	 * and are read-only after constructing.
+	 */
+	listeners []mutationListener
+
+	/**
+	 * The HyperLogLog of values, it is nil if distinct value counting isn't enabled.
+	 */
+	distinctValues *hyperLogLog
+
+	/**
+	 * The latency histograms of operations, it is nil if latency stats isn't enabled.
+	 */
+	latency *latencyRecorder
+
+	/**
+	 * The detector of hot keys, it is nil if hot key detection isn't enabled.
+	 */
+	hotKeys *hotKeyDetector
+
+	/**
+	 * The counting Bloom filter of keys, it is nil if WithBloomFilter isn't used.
+	 */
+	bloom *countingBloom
+
+	/**
+	 * Converts the keys to and from JSON, see WithJSONKeys.
+	 */
+	jsonKeys jsonKeys
+
+	/**
+	 * The pointer type of values that are stored in entries directly, see boxValue.
+	 */
+	directType unsafe.Pointer
+
+	/**
+	 * True if the small scalar values are stored inline in entries, see WithInlineValues.
+	 */
+	inlineValues bool
+
+	/**
+	 * The policy that the bounded map evicts the mappings, see WithEvictionPolicy.
+	 */
+	evictionPolicy EvictionPolicy
+
+	/**
+	 * Compares the values for PutCoalesced, == is used if it is nil, see WithValueEquals.
+	 */
+	valueEquals func(v1, v2 interface{}) bool
+
+	/**
+	 * Validates the mappings before they are stored, see WithValidator.
+	 */
+	validator func(key interface{}, value interface{}) error
+
+	/**
+	 * Evicts the mappings in background if it isn't nil, see WithEvictionPacing.
+	 */
+	evictionPacer *evictionPacer
+
+	/**
+	 * Coalesces the loads of ComputeIfAbsent by derived keys, it is nil if WithCoalesceKeyFunc isn't used.
+	 */
+	coalescer *coalescer
+
+	/**
+	 * The name of map that tells the maps apart in stats and debug output, see WithName.
+	 */
+	name string
+
+	/**
+	 * The order that Iterator visits the segments and buckets, see WithIterationOrder.
+	 */
+	iterationOrder IterationOrder
+
+	/**
+	 * closed is closed by Close, all background goroutines must exit when it is closed.
+	 */
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
 /**
@@ -103,10 +253,17 @@ type ConcurrentMap struct {
  * @return the segment
  */
 func (this *ConcurrentMap) segmentFor(hash uint32) *Segment {
+	return this.segments[this.segmentIndex(hash)]
+}
+
+/**
+ * Returns the index of segment that should be used for key with given hash
+ */
+func (this *ConcurrentMap) segmentIndex(hash uint32) int {
 	//默认segmentShift是28，segmentMask是（0xFFFFFFF）,hash>>this.segmentShift就是取前面4位
 	//&segmentMask似乎没有必要
 	//get first four bytes
-	return this.segments[(hash>>this.segmentShift)&uint32(this.segmentMask)]
+	return int((hash >> this.segmentShift) & uint32(this.segmentMask))
 }
 
 /**
@@ -203,8 +360,12 @@ func (this *ConcurrentMap) Size() int32 {
 /**
  * Returns the value to which the specified key is mapped,
  * or nil if this map contains no mapping for the key.
+ * The value is transformed by the decoder if WithValueDecoder is used.
  */
 func (this *ConcurrentMap) Get(key interface{}) (value interface{}, err error) {
+	if l := this.latency; l != nil {
+		defer l.get.since(time.Now())
+	}
 	if isNil(key) {
 		return nil, NilKeyError
 	}
@@ -215,7 +376,24 @@ func (this *ConcurrentMap) Get(key interface{}) (value interface{}, err error) {
 		err = e
 	} else {
 		Printf("Get, %v, %v\n", key, hash)
-		value = this.segmentFor(hash).get(key, hash)
+		seg := this.segmentFor(hash)
+		if h := this.hotKeys; h != nil {
+			if v, ok := h.get(seg, key, hash); ok {
+				return this.decode(v), nil
+			}
+		}
+		value = this.decode(seg.get(key, hash))
+	}
+	return
+}
+
+/**
+ * Returns the value to which the specified key is mapped, or defaultValue if this map
+ * contains no mapping for the key. The defaultValue is not put into this map.
+ */
+func (this *ConcurrentMap) GetOrDefault(key interface{}, defaultValue interface{}) (value interface{}, err error) {
+	if value, err = this.Get(key); err == nil && value == nil {
+		value = defaultValue
 	}
 	return
 }
@@ -247,6 +425,67 @@ func (this *ConcurrentMap) ContainsKey(key interface{}) (found bool, err error)
 	return
 }
 
+/**
+ * Returns true if this map maps one or more keys to the specified value.
+ * Note: This method requires a full internal traversal of the hash table,
+ * so it is much slower than ContainsKey, it is useful for checking invariants
+ * and the reverse lookups in small maps.
+ * The values are compared by WithValueEquals, or == by default.
+ *
+ * The segments are traversed without lock a few times, if a value is not found
+ * and the segments were modified while traversing, all segments are locked and traversed again.
+ *
+ * @param value value whose presence in this map is to be tested
+ */
+func (this *ConcurrentMap) ContainsValue(value interface{}) (found bool, err error) {
+	if isNil(value) {
+		return false, NilValueError
+	}
+	defer this.recoverCallback(&err)
+
+	segments := this.segments
+	mc := make([]int32, len(segments))
+
+	// Try a few times without locking
+	for k := 0; k < RETRIES_BEFORE_LOCK; k++ {
+		var mcsum int32 = 0
+		for i := 0; i < len(segments); i++ {
+			mc[i] = atomic.LoadInt32(&segments[i].modCount)
+			mcsum += mc[i]
+			if segments[i].containsValue(value) {
+				return true, nil
+			}
+		}
+		cleanSweep := true
+		if mcsum != 0 {
+			for i := 0; i < len(segments); i++ {
+				if mc[i] != atomic.LoadInt32(&segments[i].modCount) {
+					cleanSweep = false
+					break
+				}
+			}
+		}
+		if cleanSweep {
+			return false, nil
+		}
+	}
+	// Resort to locking all segments
+	for i := 0; i < len(segments); i++ {
+		segments[i].lock.Lock()
+	}
+	defer func() {
+		for i := 0; i < len(segments); i++ {
+			segments[i].lock.Unlock()
+		}
+	}()
+	for i := 0; i < len(segments); i++ {
+		if segments[i].containsValue(value) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 /**
  * Maps the specified key to the specified value in this table.
  * Neither the key nor the value can be nil.
@@ -261,12 +500,18 @@ func (this *ConcurrentMap) ContainsKey(key interface{}) (found bool, err error)
  *         nil if there was no mapping for key
  */
 func (this *ConcurrentMap) Put(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	if l := this.latency; l != nil {
+		defer l.put.since(time.Now())
+	}
 	if isNil(key) {
 		return nil, NilKeyError
 	}
 	if isNil(value) {
 		return nil, NilValueError
 	}
+	if e := this.validate(key, value); e != nil {
+		return nil, e
+	}
 
 	if hash, e := hashKey(key, this, false); e != nil {
 		err = e
@@ -280,6 +525,20 @@ func (this *ConcurrentMap) Put(key interface{}, value interface{}) (oldVal inter
 	return
 }
 
+/**
+ * Stores the value for key unconditionally like Put, and reports if the key existed,
+ * like Swap of sync.Map. Unlike Replace, the value is stored even if the key is absent.
+ *
+ * @return the previous value, loaded is false if there was no mapping for key
+ */
+func (this *ConcurrentMap) Swap(key interface{}, value interface{}) (previous interface{}, loaded bool, err error) {
+	oldVal, err := this.Put(key, value)
+	if oldVal != nil {
+		previous, loaded = oldVal, true
+	}
+	return
+}
+
 /**
  * If mapping exists for the key, then maps the specified key to the specified value in this table.
  * else will ignore.
@@ -292,12 +551,18 @@ func (this *ConcurrentMap) Put(key interface{}, value interface{}) (oldVal inter
  *         or nil if there was no mapping for the key
  */
 func (this *ConcurrentMap) PutIfAbsent(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	if l := this.latency; l != nil {
+		defer l.put.since(time.Now())
+	}
 	if isNil(key) {
 		return nil, NilKeyError
 	}
 	if isNil(value) {
 		return nil, NilValueError
 	}
+	if e := this.validate(key, value); e != nil {
+		return nil, e
+	}
 
 	if hash, e := hashKey(key, this, false); e != nil {
 		err = e
@@ -311,6 +576,111 @@ func (this *ConcurrentMap) PutIfAbsent(key interface{}, value interface{}) (oldV
 	return
 }
 
+/**
+ * Maps the specified key to the specified value in this table,
+ * and the mapping will expire after the specified duration.
+ * Neither the key nor the value can be nil.
+ *
+ * Expired mappings are invisible to all read and write operations,
+ * and will be removed by a timing wheel at the first tick after the expiration time,
+ * so Size may include the expired mappings that have not been removed yet.
+ * If ttl <= 0, the mapping will never expire, the same as Put.
+ * The ttl is randomized if WithTTLJitter is used.
+ *
+ * Put always clears the expiration time of the key,
+ * but Replace, CompareAndReplace and Update keep it.
+ *
+ * @return the previous value associated with key, or
+ *         nil if there was no mapping for key
+ */
+func (this *ConcurrentMap) PutWithTTL(key interface{}, value interface{}, ttl time.Duration) (oldVal interface{}, err error) {
+	if l := this.latency; l != nil {
+		defer l.put.since(time.Now())
+	}
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+	if isNil(value) {
+		return nil, NilValueError
+	}
+	if e := this.validate(key, value); e != nil {
+		return nil, e
+	}
+
+	var expireAt int64
+	if ttl > 0 {
+		expireAt = time.Now().Add(this.jitter(ttl)).UnixNano()
+	}
+
+	if hash, e := hashKey(key, this, false); e != nil {
+		err = e
+	} else {
+		Printf("PutWithTTL, %v, %v, %v\n", key, hash, ttl)
+		oldVal = this.segmentFor(hash).putWithExpiration(key, hash, value, false, nil, expireAt)
+		if expireAt != 0 {
+			this.scheduleExpiration(key, hash, expireAt)
+		}
+	}
+	return
+}
+
+/**
+ * Returns the remaining time to live of the mapping for the specified key,
+ * like TTL command of Redis.
+ *
+ * @return the remaining time to live, or a negative duration if the mapping never expires,
+ *         and ok is false if there was no mapping for key
+ */
+func (this *ConcurrentMap) TTL(key interface{}) (remaining time.Duration, ok bool, err error) {
+	if isNil(key) {
+		return 0, false, NilKeyError
+	}
+
+	if hash, e := hashKey(key, this, false); e != nil {
+		err = e
+	} else {
+		Printf("TTL, %v, %v\n", key, hash)
+		var expireAt int64
+		if expireAt, ok = this.segmentFor(hash).getExpiration(key, hash); ok {
+			if expireAt == 0 {
+				remaining = -1
+			} else {
+				remaining = time.Duration(expireAt - time.Now().UnixNano())
+			}
+		}
+	}
+	return
+}
+
+/**
+ * Sets the expiration time of the mapping for the specified key,
+ * like EXPIREAT command of Redis.
+ * If t is zero time, the mapping will never expire, like PERSIST command of Redis.
+ * If t is in the past, the mapping expires at once.
+ *
+ * @return true if the expiration time be set, false if there was no mapping for key
+ */
+func (this *ConcurrentMap) ExpireAt(key interface{}, t time.Time) (ok bool, err error) {
+	if isNil(key) {
+		return false, NilKeyError
+	}
+
+	var expireAt int64
+	if !t.IsZero() {
+		expireAt = t.UnixNano()
+	}
+
+	if hash, e := hashKey(key, this, false); e != nil {
+		err = e
+	} else {
+		Printf("ExpireAt, %v, %v, %v\n", key, hash, t)
+		if ok = this.segmentFor(hash).setExpiration(key, hash, expireAt); ok && expireAt != 0 {
+			this.scheduleExpiration(key, hash, expireAt)
+		}
+	}
+	return
+}
+
 /**
  * Maps the specified key to the value that be returned by specified function in this table.
  * The key can not be nil.
@@ -332,12 +702,14 @@ func (this *ConcurrentMap) Update(key interface{}, action func(oldVal interface{
 	if action == nil {
 		return nil, NilActionError
 	}
+	defer this.recoverCallback(&err)
+	defer recoverRejection(&err)
 
 	if hash, e := hashKey(key, this, false); e != nil {
 		err = e
 	} else {
 		Printf("Put, %v, %v\n", key, hash)
-		oldVal = this.segmentFor(hash).put(key, hash, nil, false, action)
+		oldVal = this.segmentFor(hash).put(key, hash, nil, false, this.validatedAction(key, action))
 	}
 	//hash := hash2(hashKey(key, this, true))
 	//Printf("Put, %v, %v\n", key, hash)
@@ -345,6 +717,38 @@ func (this *ConcurrentMap) Update(key interface{}, action func(oldVal interface{
 	return
 }
 
+/**
+ * Same as Update, but only if a mapping exists for the key, so no mapping is created.
+ * The action receives the current value and its result replaces the value atomically
+ * under the segment lock, the mapping is removed if the action returns nil.
+ *
+ * @return the previous value associated with key, or nil if there was no mapping for key
+ *         and the action was not called
+ */
+func (this *ConcurrentMap) UpdateIfPresent(key interface{}, action func(oldVal interface{}) (newVal interface{})) (oldVal interface{}, err error) {
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+	if action == nil {
+		return nil, NilActionError
+	}
+	defer this.recoverCallback(&err)
+	defer recoverRejection(&err)
+
+	if hash, e := hashKey(key, this, false); e != nil {
+		err = e
+	} else {
+		Printf("UpdateIfPresent, %v, %v\n", key, hash)
+		oldVal = this.segmentFor(hash).put(key, hash, nil, false, this.validatedAction(key, func(oldVal interface{}) interface{} {
+			if oldVal == nil {
+				return nil
+			}
+			return action(oldVal)
+		}))
+	}
+	return
+}
+
 /**
  * Copies all of the mappings from the specified map to this one.
  * These mappings replace any mappings that this map had for any of the
@@ -356,8 +760,22 @@ func (this *ConcurrentMap) PutAll(m map[interface{}]interface{}) (err error) {
 	if isNil(m) {
 		err = errors.New("Cannot copy nil map")
 	}
+	if this.validator != nil {
+		for k, v := range m {
+			if err = this.validate(k, v); err != nil {
+				return
+			}
+		}
+	}
+	//the previous values are not needed, so they are not decompressed, see storeUnderLock
 	for k, v := range m {
-		this.Put(k, v)
+		if isNil(k) || isNil(v) {
+			continue
+		}
+		if hash, e := hashKey(k, this, false); e == nil {
+			Printf("PutAll, %v, %v\n", k, hash)
+			this.segmentFor(hash).store(k, hash, v)
+		}
 	}
 	return
 }
@@ -370,6 +788,9 @@ func (this *ConcurrentMap) PutAll(m map[interface{}]interface{}) (err error) {
  * @return the previous value associated with key, or nil if there was no mapping for key
  */
 func (this *ConcurrentMap) Remove(key interface{}) (oldVal interface{}, err error) {
+	if l := this.latency; l != nil {
+		defer l.remove.since(time.Now())
+	}
 	if isNil(key) {
 		return nil, NilKeyError
 	}
@@ -386,6 +807,21 @@ func (this *ConcurrentMap) Remove(key interface{}) (oldVal interface{}, err erro
 	return
 }
 
+/**
+ * Removes the mapping for the key and returns its value in a single segment operation,
+ * like LoadAndDelete of sync.Map. So the work-stealing and deduplication don't race
+ * like a pair of Get and Remove, only one caller gets the value of a mapping.
+ *
+ * @return the value that was removed, loaded is false if there was no mapping for key
+ */
+func (this *ConcurrentMap) GetAndDelete(key interface{}) (value interface{}, loaded bool, err error) {
+	oldVal, err := this.Remove(key)
+	if oldVal != nil {
+		value, loaded = oldVal, true
+	}
+	return
+}
+
 /**
  * Removes the mapping for the key and value from this map.
  * This method does nothing if no mapping for the key and value.
@@ -393,6 +829,9 @@ func (this *ConcurrentMap) Remove(key interface{}) (oldVal interface{}, err erro
  * @return true if mapping be removed, false otherwise
  */
 func (this *ConcurrentMap) RemoveEntry(key interface{}, value interface{}) (ok bool, err error) {
+	if l := this.latency; l != nil {
+		defer l.remove.since(time.Now())
+	}
 	if isNil(key) {
 		return false, NilKeyError
 	}
@@ -426,6 +865,9 @@ func (this *ConcurrentMap) CompareAndReplace(key interface{}, oldVal interface{}
 	if isNil(oldVal) || isNil(newVal) {
 		return false, NilValueError
 	}
+	if e := this.validate(key, newVal); e != nil {
+		return false, e
+	}
 
 	if hash, e := hashKey(key, this, false); e != nil {
 		err = e
@@ -453,6 +895,9 @@ func (this *ConcurrentMap) Replace(key interface{}, value interface{}) (oldVal i
 	if isNil(value) {
 		return nil, NilValueError
 	}
+	if e := this.validate(key, value); e != nil {
+		return nil, e
+	}
 
 	if hash, e := hashKey(key, this, false); e != nil {
 		err = e
@@ -475,9 +920,25 @@ func (this *ConcurrentMap) Clear() {
 	}
 }
 
-//Iterator returns a iterator for ConcurrentMap
+/**
+ * Stops all background goroutines of this map and releases their resources,
+ * e.g. the timing wheel that removes expired entries, and closes the channels returned by Expired.
+ *
+ * Close does not remove the mappings, the map can still be used after closing,
+ * but the expired entries will not be removed in background anymore,
+ * they are only invisible and are removed when they are overwritten or removed.
+ * Close can be called multiple times, it always returns nil.
+ */
+func (this *ConcurrentMap) Close() error {
+	this.closeOnce.Do(func() {
+		close(this.closed)
+	})
+	return nil
+}
+
+//Iterator returns a iterator for ConcurrentMap in the order of WithIterationOrder
 func (this *ConcurrentMap) Iterator() *MapIterator {
-	return newMapIterator(this)
+	return newMapIterator(this, this.iterationOrder)
 }
 
 //ToSlice returns a slice that includes all key-value Entry in ConcurrentMap
@@ -490,16 +951,206 @@ func (this *ConcurrentMap) ToSlice() (kvs []*Entry) {
 	return
 }
 
-func (this *ConcurrentMap) parseKey(key interface{}) (err error) {
-	this.engChecker.Do(func() {
-		var eng *hashEnginer
+//Keys returns a slice that includes all keys in ConcurrentMap,
+//the segments are visited in turn, so the result is weakly consistent like MapIterator
+func (this *ConcurrentMap) Keys() (keys []interface{}) {
+	keys = make([]interface{}, 0, this.Size())
+	for itr := this.Iterator(); itr.HasNext(); {
+		keys = append(keys, itr.nextEntry().key)
+	}
+	return
+}
 
-		val := key
+//Values returns a slice that includes all values in ConcurrentMap,
+//the segments are visited in turn, so the result is weakly consistent like MapIterator
+func (this *ConcurrentMap) Values() (values []interface{}) {
+	values = make([]interface{}, 0, this.Size())
+	for itr := this.Iterator(); itr.HasNext(); {
+		values = append(values, itr.nextEntry().Value())
+	}
+	return
+}
 
-		if _, ok := val.(Hashable); ok {
-			eng = hasherEng
-		} else {
-			switch v := val.(type) {
+//ToMap copies all mappings in ConcurrentMap into a new builtin map,
+//the segments are visited in turn, so the result is weakly consistent like MapIterator
+func (this *ConcurrentMap) ToMap() (m map[interface{}]interface{}) {
+	m = make(map[interface{}]interface{}, this.Size())
+	for itr := this.Iterator(); itr.HasNext(); {
+		e := itr.nextEntry()
+		m[e.key] = e.Value()
+	}
+	return
+}
+
+/**
+ * Calls fn for every mapping in this map, it walks the tables of segments without lock
+ * and without allocating an iterator, so it is weakly consistent like MapIterator.
+ * The expired mappings are skipped.
+ */
+func (this *ConcurrentMap) ForEach(fn func(key interface{}, value interface{})) {
+	this.Range(func(key interface{}, value interface{}) bool {
+		fn(key, value)
+		return true
+	})
+}
+
+/**
+ * Calls fn for every mapping in this map until fn returns false, like Range of sync.Map.
+ * As ForEach, it walks the tables of segments without lock and without allocating an iterator,
+ * so it is weakly consistent like MapIterator, and the expired mappings are skipped.
+ */
+func (this *ConcurrentMap) Range(fn func(key interface{}, value interface{}) bool) {
+	for i := len(this.segments) - 1; i >= 0; i-- {
+		if !this.segments[i].rangeEntries(fn) {
+			return
+		}
+	}
+}
+
+/**
+ * Calls fn for every mapping in this map like ForEach, but the segments are walked in parallel
+ * by at most workers goroutines, one segment at a time per goroutine, and it returns after all
+ * segments are walked. The segments are independent, so no lock is added.
+ * fn must be safe for concurrent use, and if fn panics, the first panic is raised again
+ * to the caller after the other goroutines finish.
+ *
+ * panic IllegalArgError if workers is not positive
+ */
+func (this *ConcurrentMap) ParallelForEach(fn func(key interface{}, value interface{}), workers int) {
+	if workers <= 0 {
+		panic(IllegalArgError)
+	}
+	if workers > len(this.segments) {
+		workers = len(this.segments)
+	}
+
+	var next int32 = -1
+	var panicked atomic.Value
+	wg := new(sync.WaitGroup)
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					panicked.CompareAndSwap(nil, &r)
+				}
+			}()
+			for i := int(atomic.AddInt32(&next, 1)); i < len(this.segments); i = int(atomic.AddInt32(&next, 1)) {
+				this.segments[i].rangeEntries(func(key interface{}, value interface{}) bool {
+					fn(key, value)
+					return true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	if r := panicked.Load(); r != nil {
+		panic(*r.(*interface{}))
+	}
+}
+
+/**
+ * Calls f for every segment with a consistent view of that segment.
+ * Only one segment is locked at a time, the entries of segment are copied
+ * while holding its lock, and f is called after the lock be released,
+ * so the writers of other segments are never blocked, and the writers of
+ * current segment are blocked only while copying.
+ *
+ * Note the views of different segments are taken at different moments.
+ */
+func (this *ConcurrentMap) ForEachSegmentLocked(f func(entries []Entry)) {
+	for i := 0; i < len(this.segments); i++ {
+		f(this.segments[i].lockedEntries())
+	}
+}
+
+/**
+ * Maps every specified key to the value that be returned by specified function in this table.
+ * The keys are grouped by segment, and the function is applied for all keys
+ * in a segment under a single lock acquisition.
+ *
+ * As Update, the value mapping the key will be passed into function as parameter,
+ * nil will be passed if mapping does not exists for the key,
+ * and the key will be removed from map if the function returns nil.
+ *
+ * @param keys with which the values are to be associated
+ * @param f that be called to generate new value mapping every key
+ * @return NilKeyError if any key is nil, NilActionError if f is nil,
+ * nothing will be changed if returns error
+ */
+func (this *ConcurrentMap) ComputeAll(keys []interface{}, f func(key interface{}, oldVal interface{}) (newVal interface{})) (err error) {
+	if f == nil {
+		return NilActionError
+	}
+	defer this.recoverCallback(&err)
+	defer recoverRejection(&err)
+
+	hashes, groups, err := this.groupBySegment(keys)
+	if err != nil {
+		return
+	}
+
+	for i, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		this.segments[i].computeAll(keys, hashes, group, f)
+	}
+	return
+}
+
+/**
+ * Hashes the keys and groups the indexes of keys by segment.
+ *
+ * @return the hash codes of keys, and groups[i] includes the indexes of keys that belong to segment i
+ */
+func (this *ConcurrentMap) groupBySegment(keys []interface{}) (hashes []uint32, groups [][]int, err error) {
+	hashes = make([]uint32, len(keys))
+	groups = make([][]int, len(this.segments))
+	for i, key := range keys {
+		if isNil(key) {
+			return nil, nil, NilKeyError
+		}
+		if hashes[i], err = hashKey(key, this, false); err != nil {
+			return nil, nil, err
+		}
+		idx := this.segmentIndex(hashes[i])
+		groups[idx] = append(groups[idx], i)
+	}
+	return
+}
+
+/**
+ * Returns the timing wheel, creates it if it is not created yet.
+ */
+func (this *ConcurrentMap) timingWheel() *timingWheel {
+	this.wheelChecker.Do(func() {
+		this.wheel = newTimingWheel(EXPIRATION_TICK, this.expire, this.closed)
+	})
+	return this.wheel
+}
+
+/**
+ * Removes the entries that have expired for the fired timers.
+ */
+func (this *ConcurrentMap) expire(timers []*wheelTimer) {
+	now := time.Now().UnixNano()
+	for _, t := range timers {
+		this.segmentFor(t.hash).expire(t.key, t.hash, now)
+	}
+}
+
+func (this *ConcurrentMap) parseKey(key interface{}) (err error) {
+	this.engChecker.Do(func() {
+		var eng *hashEnginer
+
+		val := key
+
+		if _, ok := val.(Hashable); ok {
+			eng = hasherEng
+		} else {
+			switch v := val.(type) {
 			case bool:
 				_ = v
 				eng = boolEng
@@ -564,6 +1215,7 @@ func (this *ConcurrentMap) newSegment(initialCapacity int, lf float32) (s *Segme
 	s.loadFactor = lf
 	table := make([]unsafe.Pointer, initialCapacity)
 	s.setTable(table)
+	s.initialCapacity = initialCapacity
 	s.lock = new(sync.Mutex)
 	s.m = this
 	return
@@ -611,6 +1263,8 @@ func newConcurrentMap3(initialCapacity int,
 		m.segments[i] = m.newSegment(cap, loadFactor)
 	}
 	m.engChecker = new(Once)
+	m.wheelChecker = new(Once)
+	m.closed = make(chan struct{})
 	return
 }
 
@@ -635,8 +1289,22 @@ func newConcurrentMap3(initialCapacity int,
  *
  * Creates a new, empty map with a default initial capacity (16),
  * load factor (0.75) and concurrencyLevel (16).
+ *
+ * The Option values in paras are used to enable the optional features,
+ * they are applied in order and can be placed after the above parameters.
  */
 func NewConcurrentMap(paras ...interface{}) (m *ConcurrentMap) {
+	opts := make([]Option, 0)
+	positional := make([]interface{}, 0, len(paras))
+	for _, para := range paras {
+		if opt, ok := para.(Option); ok {
+			opts = append(opts, opt)
+		} else {
+			positional = append(positional, para)
+		}
+	}
+	paras = positional
+
 	ok := false
 	cap := DEFAULT_INITIAL_CAPACITY
 	factor := DEFAULT_LOAD_FACTOR
@@ -661,6 +1329,9 @@ func NewConcurrentMap(paras ...interface{}) (m *ConcurrentMap) {
 	}
 
 	m = newConcurrentMap3(cap, factor, concurrent_lvl)
+	for _, opt := range opts {
+		opt(m)
+	}
 	return
 }
 
@@ -673,25 +1344,86 @@ func NewConcurrentMap(paras ...interface{}) (m *ConcurrentMap) {
  * @param m the map
  */
 func NewConcurrentMapFromMap(m map[interface{}]interface{}) *ConcurrentMap {
-	cm := newConcurrentMap3(int(math.Max(float64(float32(len(m))/DEFAULT_LOAD_FACTOR+1),
-		float64(DEFAULT_INITIAL_CAPACITY))),
+	cm := newConcurrentMap3(capacityFor(len(m)),
 		DEFAULT_LOAD_FACTOR, DEFAULT_CONCURRENCY_LEVEL)
 	cm.PutAll(m)
 	return cm
 }
 
+/**
+ * Returns the initial capacity for a map that will include n mappings,
+ * it is 1.5 times of n or 16 (whichever is greater)
+ */
+func capacityFor(n int) int {
+	return int(math.Max(float64(float32(n)/DEFAULT_LOAD_FACTOR+1),
+		float64(DEFAULT_INITIAL_CAPACITY)))
+}
+
 /**
  * ConcurrentHashMap list entry.
- * Note only value field is variable and must use atomic to read/write it, other three fields are read-only after initializing.
- * so can use unsynchronized reader, the Segment.readValueUnderLock method is used as a
- * backup in case a nil (pre-initialized) value is ever seen in
- * an unsynchronized access method.
+ * Note only value and expireAt fields are variable and must use atomic to read/write them,
+ * other three fields are read-only after initializing.
+ * so can use unsynchronized reader.
+ *
+ * An entry is always initialized before it is published by atomic.StorePointer to a bin
+ * (or to pTable for a rehashed table), and the readers load the bin by atomic.LoadPointer.
+ * Go memory model guarantees the store is synchronized before the load that observes it,
+ * so the reader that sees an entry also sees its initialized value, a nil value is never
+ * seen and the reader never needs to take the lock.
  */
 type Entry struct {
-	key   interface{}
-	hash  uint32
-	value unsafe.Pointer
-	next  *Entry
+	/**
+	 * The unix time in nanoseconds that entry expires, 0 means never expire.
+	 * It is the first field to guarantee 64-bit alignment for atomic operations on 32-bit platforms.
+	 */
+	expireAt int64
+	/**
+	 * The version of value, it is increased by segment when value is stored,
+	 * so it can be used to check if value has been changed.
+	 * Must use atomic to read it while no lock.
+	 */
+	version int64
+	/**
+	 * The bits of value if the value is stored inline, see boxValue.
+	 * Must use atomic to read it while no lock.
+	 */
+	bits uint64
+	/**
+	 * The user-defined tag, see PutWithTag.
+	 * Must use atomic to read/write it.
+	 */
+	tag uint64
+	/**
+	 * The unix time in nanoseconds that entry was last read or written,
+	 * it is only recorded if the map is bounded, see WithMaxEntries.
+	 * Must use atomic to read/write it.
+	 */
+	accessed int64
+	/**
+	 * The unix time in nanoseconds that entry becomes stale, 0 means never stale, see PutWithSoftTTL.
+	 * Must use atomic to read it while no lock.
+	 */
+	staleAt int64
+	/**
+	 * The unix time in nanoseconds of the last write by PutCoalesced, see PutCoalesced.
+	 * Must use atomic to read it while no lock.
+	 */
+	written int64
+	key     interface{}
+	hash    uint32
+	/**
+	 * The representation and type of value, see boxValue.
+	 * They are immutable after the entry is published, the entry is replaced
+	 * if the value is changed to another representation.
+	 */
+	vkind uint8
+	//the eviction priority, see PutWithPriority
+	priority int8
+	vtype    unsafe.Pointer
+	value    unsafe.Pointer //points to interface{} if value is boxed, otherwise is the pointer of value
+	next     *Entry
+	//pads the size to a multiple of 8 on 32-bit platforms, see checkAlignment
+	_ [entryPad]byte
 }
 
 func (this *Entry) Key() interface{} {
@@ -699,15 +1431,50 @@ func (this *Entry) Key() interface{} {
 }
 
 func (this *Entry) Value() interface{} {
-	return *((*interface{})(atomic.LoadPointer(&this.value)))
+	if this.vkind == valueInline {
+		return this.unboxInline(atomic.LoadUint64(&this.bits))
+	}
+	return this.unboxValue(atomic.LoadPointer(&this.value))
 }
 
 func (this *Entry) fastValue() interface{} {
-	return *((*interface{})(this.value))
+	if this.vkind == valueInline {
+		return this.unboxInline(this.bits)
+	}
+	return this.unboxValue(this.value)
+}
+
+/**
+ * Returns true if the entry has expired at the specified time.
+ */
+func (this *Entry) isExpired(now int64) bool {
+	expireAt := atomic.LoadInt64(&this.expireAt)
+	return expireAt != 0 && expireAt <= now
+}
+
+/**
+ * Returns true if the entry has expired.
+ * Only reads the clock if the entry has an expiration time.
+ */
+func (this *Entry) expired() bool {
+	expireAt := atomic.LoadInt64(&this.expireAt)
+	return expireAt != 0 && expireAt <= time.Now().UnixNano()
+}
+
+/**
+ * Returns a copy of the entry that doesn't point to next entry.
+ * Call only while holding lock.
+ */
+func (this *Entry) unlinked() Entry {
+	return Entry{expireAt: this.expireAt, version: this.version, bits: this.bits, tag: this.tag, accessed: atomic.LoadInt64(&this.accessed), staleAt: this.staleAt, written: this.written, key: this.key, hash: this.hash, vkind: this.vkind, priority: this.priority, vtype: this.vtype, value: this.value}
 }
 
-func (this *Entry) storeValue(v *interface{}) {
-	atomic.StorePointer(&this.value, unsafe.Pointer(v))
+/**
+ * Returns a copy of the entry that points to the specified next entry.
+ */
+func (this *Entry) clone(next *Entry) *Entry {
+	return &Entry{expireAt: atomic.LoadInt64(&this.expireAt), version: this.version, bits: this.bits, tag: this.tag, accessed: atomic.LoadInt64(&this.accessed), staleAt: this.staleAt, written: this.written,
+		key: this.key, hash: this.hash, vkind: this.vkind, priority: this.priority, vtype: this.vtype, value: this.value, next: next}
 }
 
 type Segment struct {
@@ -726,6 +1493,7 @@ type Segment struct {
 	 * of segments computing size or checking containsValue, then
 	 * we might have an inconsistent view of state so (usually)
 	 * must retry.
+	 * Must use atomic to read/write it, because the bulk-read methods read it without lock.
 	 */
 	modCount int32
 
@@ -750,6 +1518,114 @@ type Segment struct {
 	loadFactor float32
 
 	lock *sync.Mutex
+
+	/**
+	 * The last version of value stored in this segment.
+	 * Call only while holding lock.
+	 */
+	version int64
+
+	/**
+	 * The capacity of table when segment was created, it is used to create a fresh table.
+	 */
+	initialCapacity int
+
+	/**
+	 * The number of times that lock was held by others when acquiring it.
+	 * Must use atomic to read/write it.
+	 */
+	contended int32
+
+	/**
+	 * The max number of spins before blocking on lock, 0 means never spin, see WithLockSpin.
+	 * It is set while constructing.
+	 */
+	maxSpins int32
+
+	/**
+	 * The current number of spins before blocking on lock, it adapts to the contention.
+	 * Must use atomic to read/write it.
+	 */
+	spinBudget int32
+
+	/**
+	 * Evicts the entries if segment exceeds its bound, it is nil if the map is unbounded.
+	 * It is set while constructing, and its state is accessed only while holding lock.
+	 */
+	evictor *evictor
+
+	/**
+	 * The number of ForEachPinned that are visiting this segment.
+	 * Must use atomic to read/write it.
+	 */
+	pins int32
+
+	/**
+	 * It is increased when the entries are cloned or unlinked from table, e.g. by remove and rehash,
+	 * so the EntryHandles created before are invalidated.
+	 * Must use atomic to read it while no lock.
+	 */
+	layout int32
+
+	/**
+	 * The entries that are preallocated for the inserts, see NewPresized.
+	 * slabSize is 0 if the entries are allocated one by one.
+	 * They are accessed only while holding lock.
+	 */
+	slab     []Entry
+	slabSize int
+
+	/**
+	 * The loads of ComputeIfAbsent that are running by hash, it is created lazily
+	 * and is accessed only while holding lock.
+	 */
+	inflight map[uint32][]*flight
+}
+
+/**
+ * Acquires the lock of segment, and counts the contention if lock is held by others.
+ * If WithLockSpin is used, it spins before blocking on the lock.
+ */
+func (this *Segment) acquire() {
+	if !this.lock.TryLock() {
+		atomic.AddInt32(&this.contended, 1)
+		if this.maxSpins > 0 && this.spin() {
+			return
+		}
+		this.lock.Lock()
+	}
+}
+
+/**
+ * Stores the value of entry with a new version.
+ * If the representation of value is changed, e is replaced by a new entry.
+ * Call only while holding lock.
+ *
+ * @return the entry that holds the value
+ */
+func (this *Segment) setValue(e *Entry, v interface{}) *Entry {
+	this.version++
+	ev := this.m.boxValue(v)
+	if ev.kind != e.vkind || ev.typ != e.vtype {
+		return this.replaceEntryUnderLock(e, ev, e.staleAt)
+	}
+	atomic.StoreInt64(&e.version, this.version)
+	if ev.kind == valueInline {
+		atomic.StoreUint64(&e.bits, ev.bits)
+	} else {
+		atomic.StorePointer(&e.value, ev.ptr)
+	}
+	return e
+}
+
+/**
+ * Notifies the mutation listeners of map.
+ * Call only while holding lock.
+ */
+func (this *Segment) mutated(key interface{}, hash uint32, oldVal interface{}, newVal interface{}) {
+	for _, l := range this.m.listeners {
+		l.onMutation(key, hash, oldVal, newVal)
+	}
 }
 
 func (this *Segment) enginer() *hashEnginer {
@@ -821,12 +1697,13 @@ func (this *Segment) rehash() {
 				for p := e; p != lastRun; p = p.next {
 					k := p.hash & sizeMask
 					n := newTable[k]
-					newTable[k] = unsafe.Pointer(&Entry{p.key, p.hash, p.value, (*Entry)(n)})
+					newTable[k] = unsafe.Pointer(p.clone((*Entry)(n)))
 				}
 			}
 		}
 	}
 	atomic.StorePointer(&this.pTable, unsafe.Pointer(&newTable))
+	atomic.AddInt32(&this.layout, 1)
 }
 
 /**
@@ -855,44 +1732,71 @@ func (this *Segment) table() []unsafe.Pointer {
 }
 
 /**
- * Returns properly casted first entry of bin for given hash.
+ * Calls fn for every mapping of segment until fn returns false, see ConcurrentMap.Range.
+ *
+ * @return false if fn returned false
  */
-func (this *Segment) getFirst(hash uint32) *Entry {
+func (this *Segment) rangeEntries(fn func(key interface{}, value interface{}) bool) bool {
+	if atomic.LoadInt32(&this.count) == 0 {
+		return true
+	}
 	tab := this.loadTable()
-	return (*Entry)(atomic.LoadPointer(&tab[hash&uint32(len(tab)-1)]))
+	for j := len(tab) - 1; j >= 0; j-- {
+		for e := (*Entry)(atomic.LoadPointer(&tab[j])); e != nil; e = e.next {
+			if !e.expired() && !fn(e.key, e.Value()) {
+				return false
+			}
+		}
+	}
+	return true
 }
 
 /**
- * Reads value field of an entry under lock. Called if value
- * field ever appears to be nil. see below code:
- * 		tab[index] = unsafe.Pointer(&Entry{key, hash, unsafe.Pointer(&value), first})
- * go memory model don't explain Entry initialization must be executed before
- * table assignment. So value is nil is possible only if a
- * compiler happens to reorder a HashEntry initialization with
- * its table assignment, which is legal under memory model
- * but is not known to ever occur.
+ * Returns properly casted first entry of bin for given hash.
  */
-func (this *Segment) readValueUnderLock(e *Entry) interface{} {
-	this.lock.Lock()
-	defer this.lock.Unlock()
-	return e.fastValue()
+func (this *Segment) getFirst(hash uint32) *Entry {
+	tab := this.loadTable()
+	return (*Entry)(atomic.LoadPointer(&tab[hash&uint32(len(tab)-1)]))
 }
 
 /* Specialized implementations of map methods */
 
 func (this *Segment) get(key interface{}, hash uint32) interface{} {
 	if atomic.LoadInt32(&this.count) != 0 { // atomic-read
-		e := this.getFirst(hash)
-		for e != nil {
+		return this.getFrom(this.loadTable(), key, hash)
+	}
+	return nil
+}
+
+/**
+ * Returns the value for key in the specified table that was loaded by loadTable,
+ * so the batched reads can share a table load.
+ */
+func (this *Segment) getFrom(tab []unsafe.Pointer, key interface{}, hash uint32) interface{} {
+	e := (*Entry)(atomic.LoadPointer(&tab[hash&uint32(len(tab)-1)]))
+	for e != nil {
+		if e.hash == hash && equals(e.key, key) {
+			if e.expired() {
+				return nil
+			}
+			this.touch(e)
+			return e.Value()
+		}
+		e = e.next
+	}
+	return nil
+}
+
+/**
+ * Returns the entry for key without lock, or nil if no entry for key.
+ * The entry may have expired.
+ */
+func (this *Segment) find(key interface{}, hash uint32) *Entry {
+	if atomic.LoadInt32(&this.count) != 0 { // read-volatile
+		for e := this.getFirst(hash); e != nil; e = e.next {
 			if e.hash == hash && equals(e.key, key) {
-				v := e.Value()
-				if v != nil {
-					//return
-					return v
-				}
-				return this.readValueUnderLock(e) // recheck
+				return e
 			}
-			e = e.next
 		}
 	}
 	return nil
@@ -903,7 +1807,7 @@ func (this *Segment) containsKey(key interface{}, hash uint32) bool {
 		e := this.getFirst(hash)
 		for e != nil {
 			if e.hash == hash && equals(e.key, key) {
-				return true
+				return !e.expired()
 			}
 			e = e.next
 		}
@@ -911,34 +1815,104 @@ func (this *Segment) containsKey(key interface{}, hash uint32) bool {
 	return false
 }
 
+/**
+ * Returns true if an entry of this segment has the value and has not expired.
+ * It can be called with or without lock.
+ */
+func (this *Segment) containsValue(value interface{}) bool {
+	if atomic.LoadInt32(&this.count) != 0 { // read-volatile
+		tab := this.loadTable()
+		for i := 0; i < len(tab); i++ {
+			for e := (*Entry)(atomic.LoadPointer(&tab[i])); e != nil; e = e.next {
+				if e.expired() {
+					continue
+				}
+				if v := e.Value(); v != nil && this.m.valuesEqual(v, value) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+/**
+ * Returns the expiration time of the mapping for key, ok is false if no mapping.
+ */
+func (this *Segment) getExpiration(key interface{}, hash uint32) (expireAt int64, ok bool) {
+	if atomic.LoadInt32(&this.count) != 0 {
+		now := time.Now().UnixNano()
+		for e := this.getFirst(hash); e != nil; e = e.next {
+			if e.hash == hash && equals(e.key, key) {
+				if expireAt = atomic.LoadInt64(&e.expireAt); expireAt != 0 && expireAt <= now {
+					return 0, false
+				}
+				return expireAt, true
+			}
+		}
+	}
+	return 0, false
+}
+
+/**
+ * Sets the expiration time of the mapping for key, returns false if no mapping.
+ */
+func (this *Segment) setExpiration(key interface{}, hash uint32, expireAt int64) bool {
+	this.acquire()
+	defer this.lock.Unlock()
+
+	e := this.getFirst(hash)
+	for e != nil && (e.hash != hash || !equals(e.key, key)) {
+		e = e.next
+	}
+
+	if e == nil || e.expired() {
+		return false
+	}
+	atomic.StoreInt64(&e.expireAt, expireAt)
+	if h := this.m.hotKeys; h != nil {
+		h.invalidate(key, hash)
+	}
+	return true
+}
+
 func (this *Segment) compareAndReplace(key interface{}, hash uint32, oldVal interface{}, newVal interface{}) bool {
-	this.lock.Lock()
+	this.acquire()
 	defer this.lock.Unlock()
+	return this.compareAndReplaceUnderLock(key, hash, oldVal, newVal)
+}
 
+/**
+ * The implementation of compareAndReplace.
+ * Call only while holding lock.
+ */
+func (this *Segment) compareAndReplaceUnderLock(key interface{}, hash uint32, oldVal interface{}, newVal interface{}) bool {
 	e := this.getFirst(hash)
 	for e != nil && (e.hash != hash || !equals(e.key, key)) {
 		e = e.next
 	}
 
 	replaced := false
-	if e != nil && oldVal == e.fastValue() {
+	if e != nil && !e.expired() && sameValue(oldVal, e.fastValue()) {
 		replaced = true
-		e.storeValue(&newVal)
+		this.setValue(e, newVal)
+		this.mutated(key, hash, oldVal, newVal)
 	}
 	return replaced
 }
 
 func (this *Segment) replace(key interface{}, hash uint32, newVal interface{}) (oldVal interface{}) {
-	this.lock.Lock()
+	this.acquire()
 	defer this.lock.Unlock()
 	e := this.getFirst(hash)
 	for e != nil && (e.hash != hash || !equals(e.key, key)) {
 		e = e.next
 	}
 
-	if e != nil {
+	if e != nil && !e.expired() {
 		oldVal = e.fastValue()
-		e.storeValue(&newVal)
+		this.setValue(e, newVal)
+		this.mutated(key, hash, oldVal, newVal)
 	}
 	return
 }
@@ -953,9 +1927,53 @@ func (this *Segment) replace(key interface{}, hash uint32, newVal interface{}) (
  * 在Golang中，StorePointer内部使用了xchgl指令，具有内存屏障，但是Load操作似乎并未具有明确的acquire语义
  */
 func (this *Segment) put(key interface{}, hash uint32, value interface{}, onlyIfAbsent bool, action func(oldValue interface{}) (newVal interface{})) (oldValue interface{}) {
-	this.lock.Lock()
+	return this.putWithExpiration(key, hash, value, onlyIfAbsent, action, 0)
+}
+
+/**
+ * Same as put, but the mapping will expire at expireAt if value is stored.
+ * expireAt is ignored if action isn't nil.
+ */
+func (this *Segment) putWithExpiration(key interface{}, hash uint32, value interface{}, onlyIfAbsent bool, action func(oldValue interface{}) (newVal interface{}), expireAt int64) (oldValue interface{}) {
+	this.acquire()
 	defer this.lock.Unlock()
+	return this.putUnderLock(key, hash, value, onlyIfAbsent, action, expireAt)
+}
+
+/**
+ * The implementation of put.
+ * An expired entry is treated as absent, and it will be reused if a new value is stored.
+ * Call only while holding lock.
+ */
+func (this *Segment) putUnderLock(key interface{}, hash uint32, value interface{}, onlyIfAbsent bool, action func(oldValue interface{}) (newVal interface{}), expireAt int64) (oldValue interface{}) {
+	return this.putEntryUnderLock(key, hash, value, onlyIfAbsent, action, expireAt, 0, true)
+}
+
+/**
+ * Same as put, but the previous value is not returned, see storeUnderLock.
+ */
+func (this *Segment) store(key interface{}, hash uint32, value interface{}) {
+	this.acquire()
+	defer this.lock.Unlock()
+	this.storeUnderLock(key, hash, value, 0)
+}
+
+/**
+ * Same as putUnderLock, but the previous value is not returned, so it is not decompressed
+ * if no listener needs it, see WithCompression.
+ * Call only while holding lock.
+ */
+func (this *Segment) storeUnderLock(key interface{}, hash uint32, value interface{}, expireAt int64) {
+	this.putEntryUnderLock(key, hash, value, false, nil, expireAt, 0, false)
+}
 
+/**
+ * The implementation of putUnderLock and storeUnderLock.
+ * The previous value is read only if wantOld is true, action isn't nil or the mutations are listened.
+ * If action is nil, the stored mapping becomes stale at staleAt, 0 means never, see PutWithSoftTTL.
+ * Call only while holding lock.
+ */
+func (this *Segment) putEntryUnderLock(key interface{}, hash uint32, value interface{}, onlyIfAbsent bool, action func(oldValue interface{}) (newVal interface{}), expireAt int64, staleAt int64, wantOld bool) (oldValue interface{}) {
 	c := this.count
 	if c > this.threshold { // ensure capacity
 		this.rehash()
@@ -970,59 +1988,154 @@ func (this *Segment) put(key interface{}, hash uint32, value interface{}, onlyIf
 		e = e.next
 	}
 
+	//current is the value in entry even if it has expired
+	var current interface{}
+	expired := e != nil && e.expired()
+	if e != nil && (wantOld || action != nil || len(this.m.listeners) > 0) {
+		current = e.fastValue()
+	}
+	if expired {
+		//the expired mapping will be overwritten or removed
+		this.m.expiredNotifier.notify(e)
+	} else {
+		oldValue = current
+	}
+
 	if action == nil {
 		if e != nil {
-			oldValue = e.fastValue()
-			if !onlyIfAbsent {
-				e.storeValue(&value)
+			if !onlyIfAbsent || expired {
+				this.mutated(key, hash, current, value)
+				e = this.setStaleValue(e, value, staleAt)
+				atomic.StoreInt64(&e.expireAt, expireAt)
+				this.touch(e)
 			}
 		} else {
 			c++
-			oldValue = nil
-			this.modCount++
-			tab[index] = unsafe.Pointer(&Entry{key, hash, unsafe.Pointer(&value), first})
+			atomic.AddInt32(&this.modCount, 1)
+			e = this.newEntry(key, hash, value, first)
+			e.expireAt, e.staleAt = expireAt, staleAt
+			atomic.StorePointer(&tab[index], unsafe.Pointer(e))
 			atomic.StoreInt32(&this.count, c) // atomic write 这里可以保证对modCount和tab的修改不会被reorder到this.count之后
+			this.m.sizeChanged()
+			this.mutated(key, hash, nil, value)
+			if this.evictor != nil {
+				this.admit(e)
+			}
 		}
 	} else {
-		if e != nil {
-			oldValue = e.fastValue()
-		} else {
-			c++
-			oldValue = nil
-		}
-
 		newVal := action(oldValue)
 		if newVal != nil {
-			if oldValue == nil {
-				e = &Entry{key, hash, unsafe.Pointer(&value), first}
-				tab[index] = unsafe.Pointer(e)
-				this.modCount++
+			if e == nil {
+				c++
+				e = this.newEntry(key, hash, newVal, first)
+				atomic.StorePointer(&tab[index], unsafe.Pointer(e))
+				atomic.AddInt32(&this.modCount, 1)
 				atomic.StoreInt32(&this.count, c) // atomic write 这里可以保证对modCount和tab的修改不会被reorder到this.count之后
+				this.m.sizeChanged()
+				this.mutated(key, hash, nil, newVal)
+				if this.evictor != nil {
+					this.admit(e)
+				}
+			} else {
+				this.mutated(key, hash, current, newVal)
+				if expired {
+					//the expired mapping is replaced by a new mapping that never expires
+					e = this.setStaleValue(e, newVal, 0)
+					atomic.StoreInt64(&e.expireAt, 0)
+				} else {
+					e = this.setValue(e, newVal)
+				}
+				this.touch(e)
 			}
-			e.storeValue(&newVal)
 		} else if e != nil {
 			//remove key if action returns nil
-			c--
-			this.modCount++
-			newFirst := e.next
-			for p := first; p != e; p = p.next {
-				newFirst = &Entry{p.key, p.hash, p.value, newFirst}
-			}
-			tab[index] = unsafe.Pointer(newFirst)
-			atomic.StoreInt32(&this.count, c) //this.count = c
+			this.removeEntryUnderLock(tab, index, first, e)
 		}
 	}
 	return
 }
 
+/**
+ * Removes e from the bin at index, first is the first entry of the bin.
+ * Call only while holding lock.
+ */
+func (this *Segment) removeEntryUnderLock(tab []unsafe.Pointer, index uint32, first *Entry, e *Entry) {
+	// All entries following removed node can stay
+	// in list, but all preceding ones need to be
+	// cloned.
+	c := this.count - 1
+	atomic.AddInt32(&this.modCount, 1)
+	newFirst := e.next
+	for p := first; p != e; p = p.next {
+		newFirst = p.clone(newFirst)
+	}
+	atomic.StorePointer(&tab[index], unsafe.Pointer(newFirst))
+	atomic.AddInt32(&this.layout, 1)
+	atomic.StoreInt32(&this.count, c) //this.count = c
+	this.m.sizeChanged()
+	//the value is decompressed only if the mutations are listened
+	if len(this.m.listeners) > 0 {
+		this.mutated(e.key, e.hash, e.fastValue(), nil)
+	}
+}
+
+/**
+ * Applies f for keys[i] for every i in indexes under a single lock acquisition.
+ */
+func (this *Segment) computeAll(keys []interface{}, hashes []uint32, indexes []int, f func(key interface{}, oldVal interface{}) (newVal interface{})) {
+	this.acquire()
+	defer this.lock.Unlock()
+
+	for _, i := range indexes {
+		key := keys[i]
+		this.putUnderLock(key, hashes[i], nil, false, this.m.validatedAction(key, func(oldVal interface{}) interface{} {
+			return f(key, oldVal)
+		}), 0)
+	}
+}
+
+/**
+ * Returns the entry for key if it exists and has not expired.
+ * Call only while holding lock.
+ */
+func (this *Segment) findUnderLock(key interface{}, hash uint32) *Entry {
+	e := this.getFirst(hash)
+	for e != nil && (e.hash != hash || !equals(e.key, key)) {
+		e = e.next
+	}
+	if e != nil && e.expired() {
+		return nil
+	}
+	return e
+}
+
+/**
+ * Puts all pairs under a single lock acquisition, the later pair wins if the keys are same.
+ */
+func (this *Segment) putAll(kvs []*kvPair) {
+	this.acquire()
+	defer this.lock.Unlock()
+
+	for _, kv := range kvs {
+		this.storeUnderLock(kv.key, kv.hash, kv.value, 0)
+	}
+}
+
 /**
  * Remove; match on key only if value nil, else match both.
+ * An expired entry is always removed, but nil is returned.
  */
 func (this *Segment) remove(key interface{}, hash uint32, value interface{}) (oldValue interface{}) {
-	this.lock.Lock()
+	this.acquire()
 	defer this.lock.Unlock()
+	return this.removeUnderLock(key, hash, value)
+}
 
-	c := this.count - 1
+/**
+ * The implementation of remove.
+ * Call only while holding lock.
+ */
+func (this *Segment) removeUnderLock(key interface{}, hash uint32, value interface{}) (oldValue interface{}) {
 	tab := this.table()
 	index := hash & uint32(len(tab)-1)
 	first := (*Entry)(tab[index])
@@ -1033,36 +2146,108 @@ func (this *Segment) remove(key interface{}, hash uint32, value interface{}) (ol
 	}
 
 	if e != nil {
+		if e.expired() {
+			this.m.expiredNotifier.notify(e)
+			this.removeEntryUnderLock(tab, index, first, e)
+			return nil
+		}
 		v := e.fastValue()
-		if value == nil || value == v {
+		if value == nil || sameValue(value, v) {
 			oldValue = v
-			// All entries following removed node can stay
-			// in list, but all preceding ones need to be
-			// cloned.
-			this.modCount++
-			newFirst := e.next
-			for p := first; p != e; p = p.next {
-				newFirst = &Entry{p.key, p.hash, p.value, newFirst}
-			}
-			tab[index] = unsafe.Pointer(newFirst)
-			atomic.StoreInt32(&this.count, c) //this.count = c
+			this.removeEntryUnderLock(tab, index, first, e)
 		}
 	}
 	return
 }
 
+/**
+ * Removes the entry for key if it has expired at the specified time.
+ */
+func (this *Segment) expire(key interface{}, hash uint32, now int64) {
+	this.acquire()
+	defer this.lock.Unlock()
+	this.expireUnderLock(key, hash, now)
+}
+
+/**
+ * The implementation of expire.
+ * Call only while holding lock.
+ */
+func (this *Segment) expireUnderLock(key interface{}, hash uint32, now int64) {
+	tab := this.table()
+	index := hash & uint32(len(tab)-1)
+	first := (*Entry)(tab[index])
+	e := first
+
+	for e != nil && (e.hash != hash || !equals(e.key, key)) {
+		e = e.next
+	}
+
+	if e != nil && e.isExpired(now) {
+		this.m.expiredNotifier.notify(e)
+		this.removeEntryUnderLock(tab, index, first, e)
+	}
+}
+
 func (this *Segment) clear() {
 	if atomic.LoadInt32(&this.count) != 0 {
-		this.lock.Lock()
+		this.acquire()
 		defer this.lock.Unlock()
 
 		tab := this.table()
 		for i := 0; i < len(tab); i++ {
-			tab[i] = nil
+			if len(this.m.listeners) > 0 {
+				for e := (*Entry)(tab[i]); e != nil; e = e.next {
+					this.mutated(e.key, e.hash, e.fastValue(), nil)
+				}
+			}
+			atomic.StorePointer(&tab[i], nil)
 		}
-		this.modCount++
+		atomic.AddInt32(&this.layout, 1)
+		atomic.AddInt32(&this.modCount, 1)
 		atomic.StoreInt32(&this.count, 0) //this.count = 0 // write-volatile
+		this.m.sizeChanged()
+	}
+}
+
+/**
+ * Calls f for every entry in this segment.
+ * Expired entries are skipped.
+ * Uses atomic to load table and entries, so can be called while no lock,
+ * the result is weakly consistent like MapIterator.
+ */
+func (this *Segment) walk(f func(e *Entry)) {
+	if atomic.LoadInt32(&this.count) == 0 {
+		return
+	}
+	tab := this.loadTable()
+	for i := 0; i < len(tab); i++ {
+		for e := (*Entry)(atomic.LoadPointer(&tab[i])); e != nil; e = e.next {
+			if !e.expired() {
+				f(e)
+			}
+		}
+	}
+}
+
+/**
+ * Returns the copies of all entries in this segment under lock.
+ */
+func (this *Segment) lockedEntries() (entries []Entry) {
+	this.acquire()
+	defer this.lock.Unlock()
+
+	entries = make([]Entry, 0, this.count)
+	now := time.Now().UnixNano()
+	tab := this.table()
+	for i := 0; i < len(tab); i++ {
+		for e := (*Entry)(tab[i]); e != nil; e = e.next {
+			if !e.isExpired(now) {
+				entries = append(entries, e.unlinked())
+			}
+		}
 	}
+	return
 }
 
 /**
@@ -1096,9 +2281,21 @@ type MapIterator struct {
 	nextE            *Entry
 	lastReturned     *Entry
 	cm               *ConcurrentMap
+	//the permutation of indexes if the order is ITER_ORDER_RANDOM, see permute
+	offset, stride uint32
 }
 
+//advance moves to next entry that has not expired
 func (this *MapIterator) advance() {
+	for {
+		this.advanceEntry()
+		if this.nextE == nil || !this.nextE.expired() {
+			return
+		}
+	}
+}
+
+func (this *MapIterator) advanceEntry() {
 	if this.nextE != nil {
 		this.nextE = this.nextE.next
 		if this.nextE != nil {
@@ -1107,7 +2304,7 @@ func (this *MapIterator) advance() {
 	}
 
 	for this.nextTableIndex >= 0 {
-		this.nextE = (*Entry)(atomic.LoadPointer(&this.currentTable[this.nextTableIndex]))
+		this.nextE = (*Entry)(atomic.LoadPointer(&this.currentTable[this.permute(this.nextTableIndex, len(this.currentTable))]))
 		this.nextTableIndex--
 		if this.nextE != nil {
 			return
@@ -1115,12 +2312,12 @@ func (this *MapIterator) advance() {
 	}
 
 	for this.nextSegmentIndex >= 0 {
-		seg := this.cm.segments[this.nextSegmentIndex]
+		seg := this.cm.segments[this.permute(this.nextSegmentIndex, len(this.cm.segments))]
 		this.nextSegmentIndex--
 		if atomic.LoadInt32(&seg.count) != 0 {
 			this.currentTable = seg.loadTable()
 			for j := len(this.currentTable) - 1; j >= 0; j-- {
-				this.nextE = (*Entry)(atomic.LoadPointer(&this.currentTable[j]))
+				this.nextE = (*Entry)(atomic.LoadPointer(&this.currentTable[this.permute(j, len(this.currentTable))]))
 				if this.nextE != nil {
 					this.nextTableIndex = j - 1
 					return
@@ -1153,6 +2350,32 @@ func (this *MapIterator) Remove() (ok bool) {
 	return true
 }
 
+/**
+ * Returns the next entry, it is the error-returning variant of nextEntry.
+ *
+ * @return IllegalStateError if the iteration has no more entries
+ */
+func (this *MapIterator) NextEntry() (e *Entry, err error) {
+	if this.nextE == nil {
+		return nil, IllegalStateError
+	}
+	return this.nextEntry(), nil
+}
+
+/**
+ * Removes the mapping of the entry that was last returned, it is the error-returning variant of Remove.
+ *
+ * @return IllegalStateError if no entry was returned or it was already removed
+ */
+func (this *MapIterator) RemoveLastReturned() (err error) {
+	if this.lastReturned == nil {
+		return IllegalStateError
+	}
+	_, err = this.cm.Remove(this.lastReturned.key)
+	this.lastReturned = nil
+	return
+}
+
 func (this *MapIterator) nextEntry() *Entry {
 	if this.nextE == nil {
 		panic("IllegalStateException")
@@ -1162,8 +2385,11 @@ func (this *MapIterator) nextEntry() *Entry {
 	return this.lastReturned
 }
 
-func newMapIterator(cm *ConcurrentMap) *MapIterator {
+func newMapIterator(cm *ConcurrentMap, order IterationOrder) *MapIterator {
 	hi := MapIterator{}
+	if order == ITER_ORDER_RANDOM {
+		hi.offset, hi.stride = randomPermutation()
+	}
 	hi.nextSegmentIndex = len(cm.segments) - 1
 	hi.nextTableIndex = -1
 	hi.cm = cm
@@ -0,0 +1,251 @@
+/**
+ * Package concurrenttest provides the stress runners for the implementations of concurrent.Map,
+ * so the users and the CI of downstream projects can validate their custom hashers and wrappers.
+ * Run the tests that call Stress with the race detector, i.e. go test -race.
+ */
+package concurrenttest
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	concurrent "github.com/fanliao/go-concurrentMap"
+)
+
+/**
+ * Config configures Stress, the zero fields are replaced by the defaults.
+ */
+type Config struct {
+	//the number of goroutines that write their own keys, default is 4
+	Writers int
+	//the number of goroutines that read the keys of all writers, default is 4
+	Readers int
+	//the number of keys that every writer owns, default is 1000
+	Keys int
+	//the number of operations of every writer, default is 10000
+	Ops int
+	//the number of goroutines that grow and shrink the map repeatedly to force the resizes
+	ResizeStorms int
+	//the number of goroutines that iterate the map repeatedly
+	Iterators int
+	//the seed of random operations, so a failed run can be reproduced
+	Seed int64
+}
+
+func (this Config) withDefaults() Config {
+	if this.Writers <= 0 {
+		this.Writers = 4
+	}
+	if this.Readers <= 0 {
+		this.Readers = 4
+	}
+	if this.Keys <= 0 {
+		this.Keys = 1000
+	}
+	if this.Ops <= 0 {
+		this.Ops = 10000
+	}
+	return this
+}
+
+//value is the value stored by Stress, it records its key so the readers can check the pairing
+type value struct {
+	key int
+	seq int
+}
+
+//failures records the first failure and counts all failures
+type failures struct {
+	lock  sync.Mutex
+	first error
+	count int
+}
+
+func (this *failures) add(format string, args ...interface{}) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.first == nil {
+		this.first = fmt.Errorf(format, args...)
+	}
+	this.count++
+}
+
+func (this *failures) err() error {
+	if this.first == nil {
+		return nil
+	}
+	return fmt.Errorf("%v failures, the first is: %v", this.count, this.first)
+}
+
+/**
+ * Runs the writers, readers, resize storms and iterators against m concurrently,
+ * and checks the results of every operation and the final state of m.
+ *
+ * Every writer owns its keys, so the previous values returned by its operations are
+ * deterministic and are checked against a local model. The readers check that a key
+ * is never mapped to the value of another key, the iterators check the pairing of entries.
+ * m must be empty, and nothing else should write it while running.
+ *
+ * @return nil if all checks passed, otherwise the error describes the first failure
+ */
+func Stress(m concurrent.Map, cfg Config) error {
+	cfg = cfg.withDefaults()
+	if !m.IsEmpty() {
+		return fmt.Errorf("the map is not empty")
+	}
+
+	fails := new(failures)
+	models := make([]map[int]value, cfg.Writers)
+	var stopped int32
+	writers, others := new(sync.WaitGroup), new(sync.WaitGroup)
+
+	for w := 0; w < cfg.Writers; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			models[w] = write(m, cfg, w, fails)
+		}(w)
+	}
+	for r := 0; r < cfg.Readers; r++ {
+		others.Add(1)
+		go func(r int) {
+			defer others.Done()
+			read(m, cfg, rand.New(rand.NewSource(cfg.Seed+int64(1000+r))), &stopped, fails)
+		}(r)
+	}
+	for s := 0; s < cfg.ResizeStorms; s++ {
+		others.Add(1)
+		go func(s int) {
+			defer others.Done()
+			storm(m, cfg, s, &stopped, fails)
+		}(s)
+	}
+	for i := 0; i < cfg.Iterators; i++ {
+		others.Add(1)
+		go func() {
+			defer others.Done()
+			for atomic.LoadInt32(&stopped) == 0 {
+				checkEntries(m, fails)
+			}
+		}()
+	}
+
+	writers.Wait()
+	atomic.StoreInt32(&stopped, 1)
+	others.Wait()
+
+	size := 0
+	for w, model := range models {
+		size += len(model)
+		for i := 0; i < cfg.Keys; i++ {
+			k := w*cfg.Keys + i
+			v, err := m.Get(k)
+			if expected, ok := model[k]; err != nil || (ok && v != expected) || (!ok && v != nil) {
+				fails.add("Get %v at last, return %v, %v, want %v", k, v, err, expected)
+			}
+		}
+	}
+	if s := m.Size(); int(s) != size {
+		fails.add("Size at last, return %v, want %v", s, size)
+	}
+	checkEntries(m, fails)
+	return fails.err()
+}
+
+//write runs the random operations on the keys that writer w owns, returns the model of its keys
+func write(m concurrent.Map, cfg Config, w int, fails *failures) map[int]value {
+	rnd := rand.New(rand.NewSource(cfg.Seed + int64(w)))
+	model := make(map[int]value, cfg.Keys)
+	for seq := 1; seq <= cfg.Ops; seq++ {
+		k := w*cfg.Keys + rnd.Intn(cfg.Keys)
+		v := value{k, seq}
+		expected, exists := model[k]
+		var old interface{}
+		var err error
+		switch op := rnd.Intn(7); op {
+		case 0, 1:
+			old, err = m.Put(k, v)
+			model[k] = v
+		case 2:
+			old, err = m.PutIfAbsent(k, v)
+			if !exists {
+				model[k] = v
+			}
+		case 3:
+			old, err = m.Remove(k)
+			delete(model, k)
+		case 4:
+			old, err = m.Replace(k, v)
+			if exists {
+				model[k] = v
+			}
+		case 5:
+			old, err = m.Update(k, func(oldVal interface{}) interface{} {
+				return v
+			})
+			model[k] = v
+		case 6:
+			if !exists {
+				continue
+			}
+			var ok bool
+			if ok, err = m.CompareAndReplace(k, expected, v); !ok && err == nil {
+				fails.add("CompareAndReplace %v, return false, want true", k)
+			}
+			model[k] = v
+			continue
+		}
+		if err != nil {
+			fails.add("Write %v, return error %v", k, err)
+		} else if (exists && old != expected) || (!exists && old != nil) {
+			fails.add("Write %v, return previous value %v, want %v", k, old, expected)
+		}
+	}
+	return model
+}
+
+//read gets the random keys of all writers until stopped
+func read(m concurrent.Map, cfg Config, rnd *rand.Rand, stopped *int32, fails *failures) {
+	for atomic.LoadInt32(stopped) == 0 {
+		k := rnd.Intn(cfg.Writers * cfg.Keys)
+		v, err := m.Get(k)
+		if err != nil {
+			fails.add("Get %v, return error %v", k, err)
+		} else if v != nil && v.(value).key != k {
+			fails.add("Get %v, return the value of key %v", k, v.(value).key)
+		}
+	}
+}
+
+//storm puts many keys and removes them repeatedly until stopped, the keys are negative,
+//so they never conflict with the keys of writers
+func storm(m concurrent.Map, cfg Config, s int, stopped *int32, fails *failures) {
+	n := cfg.Writers * cfg.Keys
+	base := -(s + 1) * n
+	for round := 0; atomic.LoadInt32(stopped) == 0; round++ {
+		kvs := make(map[interface{}]interface{}, n)
+		for i := 0; i < n; i++ {
+			kvs[base+i] = value{base + i, round}
+		}
+		if err := m.PutAll(kvs); err != nil {
+			fails.add("PutAll in resize storm, return error %v", err)
+		}
+		for i := 0; i < n; i++ {
+			if old, err := m.Remove(base + i); err != nil || old != (value{base + i, round}) {
+				fails.add("Remove %v in resize storm, return %v, %v, want %v", base+i, old, err, value{base + i, round})
+			}
+		}
+	}
+}
+
+//checkEntries checks the pairing of all entries
+func checkEntries(m concurrent.Map, fails *failures) {
+	for _, e := range m.ToSlice() {
+		k, v := e.Key(), e.Value()
+		if val, ok := v.(value); !ok || val.key != k {
+			fails.add("Entry %v, has the value %v of another key", k, v)
+		}
+	}
+}
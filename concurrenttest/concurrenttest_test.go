@@ -0,0 +1,27 @@
+package concurrenttest
+
+import (
+	"testing"
+
+	concurrent "github.com/fanliao/go-concurrentMap"
+)
+
+func TestStress(t *testing.T) {
+	cfg := Config{Writers: 4, Readers: 2, Keys: 200, Ops: 2000, ResizeStorms: 1, Iterators: 1}
+	maps := map[string]concurrent.Map{
+		"ConcurrentMap": concurrent.NewConcurrentMap(),
+		"ReadMostlyMap": concurrent.NewReadMostlyMap(),
+		"AdaptiveMap":   concurrent.NewAdaptive(),
+	}
+	for name, m := range maps {
+		if err := Stress(m, cfg); err != nil {
+			t.Errorf("Stress %v, return %v, want nil", name, err)
+		}
+	}
+
+	m := concurrent.NewConcurrentMap()
+	m.Put(1, 1)
+	if err := Stress(m, cfg); err == nil {
+		t.Errorf("Stress non-empty map, return nil, want error")
+	}
+}
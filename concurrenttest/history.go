@@ -0,0 +1,171 @@
+package concurrenttest
+
+import (
+	"sync"
+	"time"
+
+	concurrent "github.com/fanliao/go-concurrentMap"
+)
+
+/**
+ * Operation is a recorded operation of Recorder, it can be converted to the input of
+ * a linearizability checker, e.g. porcupine, the operation takes effect atomically
+ * at some moment between Call and Return if the map is linearizable.
+ */
+type Operation struct {
+	//the name of method, e.g. "Put"
+	Kind string
+	Args []interface{}
+	//the results of method except the error, e.g. the previous value of Put
+	Results []interface{}
+	Err     error
+	//the nanoseconds since the recorder was created when the method was called and returned
+	Call   int64
+	Return int64
+}
+
+/**
+ * Recorder is a Map that records the history of operations on the wrapped map.
+ * The recording costs a lock acquisition per operation, so it's intended only for tests.
+ */
+type Recorder struct {
+	m     concurrent.Map
+	start time.Time
+
+	lock sync.Mutex
+	ops  []Operation
+}
+
+/**
+ * Creates a Recorder that records the operations on m.
+ */
+func NewRecorder(m concurrent.Map) *Recorder {
+	return &Recorder{m: m, start: time.Now()}
+}
+
+var _ concurrent.Map = (*Recorder)(nil)
+
+/**
+ * Returns a copy of the recorded operations in the order of Return.
+ */
+func (this *Recorder) History() []Operation {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return append([]Operation(nil), this.ops...)
+}
+
+func (this *Recorder) now() int64 {
+	return int64(time.Since(this.start))
+}
+
+func (this *Recorder) record(kind string, call int64, args []interface{}, err error, results ...interface{}) {
+	ret := this.now()
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.ops = append(this.ops, Operation{Kind: kind, Args: args, Results: results, Err: err, Call: call, Return: ret})
+}
+
+func (this *Recorder) Get(key interface{}) (value interface{}, err error) {
+	call := this.now()
+	value, err = this.m.Get(key)
+	this.record("Get", call, []interface{}{key}, err, value)
+	return
+}
+
+func (this *Recorder) ContainsKey(key interface{}) (found bool, err error) {
+	call := this.now()
+	found, err = this.m.ContainsKey(key)
+	this.record("ContainsKey", call, []interface{}{key}, err, found)
+	return
+}
+
+func (this *Recorder) Put(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	call := this.now()
+	oldVal, err = this.m.Put(key, value)
+	this.record("Put", call, []interface{}{key, value}, err, oldVal)
+	return
+}
+
+func (this *Recorder) PutIfAbsent(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	call := this.now()
+	oldVal, err = this.m.PutIfAbsent(key, value)
+	this.record("PutIfAbsent", call, []interface{}{key, value}, err, oldVal)
+	return
+}
+
+func (this *Recorder) PutAll(m map[interface{}]interface{}) (err error) {
+	call := this.now()
+	err = this.m.PutAll(m)
+	this.record("PutAll", call, []interface{}{m}, err)
+	return
+}
+
+/**
+ * Records the Update with the old value that action is called with and the value it returns,
+ * the last call is recorded if action is called more than once.
+ */
+func (this *Recorder) Update(key interface{}, action func(oldVal interface{}) (newVal interface{})) (oldVal interface{}, err error) {
+	call := this.now()
+	var newVal interface{}
+	oldVal, err = this.m.Update(key, func(old interface{}) interface{} {
+		newVal = action(old)
+		return newVal
+	})
+	this.record("Update", call, []interface{}{key}, err, oldVal, newVal)
+	return
+}
+
+func (this *Recorder) Remove(key interface{}) (oldVal interface{}, err error) {
+	call := this.now()
+	oldVal, err = this.m.Remove(key)
+	this.record("Remove", call, []interface{}{key}, err, oldVal)
+	return
+}
+
+func (this *Recorder) RemoveEntry(key interface{}, value interface{}) (ok bool, err error) {
+	call := this.now()
+	ok, err = this.m.RemoveEntry(key, value)
+	this.record("RemoveEntry", call, []interface{}{key, value}, err, ok)
+	return
+}
+
+func (this *Recorder) Replace(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	call := this.now()
+	oldVal, err = this.m.Replace(key, value)
+	this.record("Replace", call, []interface{}{key, value}, err, oldVal)
+	return
+}
+
+func (this *Recorder) CompareAndReplace(key interface{}, oldVal interface{}, newVal interface{}) (ok bool, err error) {
+	call := this.now()
+	ok, err = this.m.CompareAndReplace(key, oldVal, newVal)
+	this.record("CompareAndReplace", call, []interface{}{key, oldVal, newVal}, err, ok)
+	return
+}
+
+func (this *Recorder) Size() int32 {
+	call := this.now()
+	size := this.m.Size()
+	this.record("Size", call, nil, nil, size)
+	return size
+}
+
+func (this *Recorder) IsEmpty() bool {
+	call := this.now()
+	empty := this.m.IsEmpty()
+	this.record("IsEmpty", call, nil, nil, empty)
+	return empty
+}
+
+func (this *Recorder) Clear() {
+	call := this.now()
+	this.m.Clear()
+	this.record("Clear", call, nil, nil)
+}
+
+func (this *Recorder) ToSlice() (kvs []*concurrent.Entry) {
+	call := this.now()
+	kvs = this.m.ToSlice()
+	this.record("ToSlice", call, nil, nil, kvs)
+	return
+}
@@ -0,0 +1,55 @@
+package concurrenttest
+
+import (
+	"sync"
+	"testing"
+
+	concurrent "github.com/fanliao/go-concurrentMap"
+)
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder(concurrent.NewConcurrentMap())
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				r.Put(i%10, g)
+				r.Get(i % 10)
+				r.Remove(i % 10)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	history := r.History()
+	if len(history) != 1200 {
+		t.Errorf("Get length of history, return %v, want 1200", len(history))
+	}
+	for i, op := range history {
+		if op.Call > op.Return {
+			t.Errorf("Operation %v %v, call at %v and return at %v, want in order", i, op.Kind, op.Call, op.Return)
+		}
+	}
+
+	//a sequential history matches a plain map
+	r = NewRecorder(concurrent.NewConcurrentMap())
+	for i := 0; i < 100; i++ {
+		r.Put(i%7, i)
+		if i%3 == 0 {
+			r.Remove(i % 5)
+		}
+	}
+	model := map[interface{}]interface{}{}
+	for _, op := range r.History() {
+		if old := model[op.Args[0]]; old != op.Results[0] {
+			t.Errorf("%v %v in history, return %v, want %v", op.Kind, op.Args[0], op.Results[0], old)
+		}
+		if op.Kind == "Put" {
+			model[op.Args[0]] = op.Args[1]
+		} else {
+			delete(model, op.Args[0])
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package concurrent
+
+import (
+	"reflect"
+	"sync"
+)
+
+//kvPair is a key-value pair with the hash code of the key
+type kvPair struct {
+	key   interface{}
+	value interface{}
+	hash  uint32
+}
+
+/**
+ * Creates a new map with the same mappings as the given sync.Map.
+ * The map is created with a capacity of 1.5 times the number
+ * of mappings in the given map or 16 (whichever is greater),
+ * and a default load factor (0.75) and concurrencyLevel (16).
+ *
+ * The mappings are grouped by segment and every group is copied
+ * by its own goroutine, so the segments are filled concurrently.
+ *
+ * @param sm the sync.Map
+ * @return the new map, or error if any key or value in sm is not supported
+ */
+func FromSyncMap(sm *sync.Map) (cm *ConcurrentMap, err error) {
+	if sm == nil {
+		return nil, IllegalArgError
+	}
+
+	kvs := make([]*kvPair, 0, DEFAULT_INITIAL_CAPACITY)
+	sm.Range(func(k, v interface{}) bool {
+		kvs = append(kvs, &kvPair{key: k, value: v})
+		return true
+	})
+
+	cm = newConcurrentMap3(capacityFor(len(kvs)),
+		DEFAULT_LOAD_FACTOR, DEFAULT_CONCURRENCY_LEVEL)
+
+	groups := make([][]*kvPair, len(cm.segments))
+	for _, kv := range kvs {
+		if isNil(kv.key) {
+			return nil, NilKeyError
+		}
+		if isNil(kv.value) {
+			return nil, NilValueError
+		}
+		if kv.hash, err = hashKey(kv.key, cm, false); err != nil {
+			return nil, err
+		}
+		i := cm.segmentIndex(kv.hash)
+		groups[i] = append(groups[i], kv)
+	}
+
+	wg := new(sync.WaitGroup)
+	for i, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(seg *Segment, group []*kvPair) {
+			defer wg.Done()
+			for _, kv := range group {
+				seg.put(kv.key, kv.hash, kv.value, false, nil)
+			}
+		}(cm.segments[i], group)
+	}
+	wg.Wait()
+	return
+}
+
+/**
+ * Returns a sync.Map that includes all mappings of this map.
+ * Every segment is copied by its own goroutine, the result is
+ * weakly consistent like the Iterator.
+ */
+func (this *ConcurrentMap) ToSyncMap() *sync.Map {
+	sm := new(sync.Map)
+	wg := new(sync.WaitGroup)
+	for _, seg := range this.segments {
+		wg.Add(1)
+		go func(seg *Segment) {
+			defer wg.Done()
+			seg.walk(func(e *Entry) {
+				sm.Store(e.key, e.Value())
+			})
+		}(seg)
+	}
+	wg.Wait()
+	return sm
+}
+
+/**
+ * Creates a new map with the same mappings as the given map.
+ * Unlike NewConcurrentMapFromMap, anyMapValue can be any map type,
+ * e.g. map[string]int, the mappings are read by reflection.
+ *
+ * @param anyMapValue the map
+ * @return the new map, or IllegalArgError if anyMapValue is not a map
+ */
+func FromMapReflect(anyMapValue interface{}) (cm *ConcurrentMap, err error) {
+	rv := reflect.ValueOf(anyMapValue)
+	if !rv.IsValid() || rv.Kind() != reflect.Map {
+		return nil, IllegalArgError
+	}
+
+	cm = newConcurrentMap3(capacityFor(rv.Len()),
+		DEFAULT_LOAD_FACTOR, DEFAULT_CONCURRENCY_LEVEL)
+
+	itr := rv.MapRange()
+	for itr.Next() {
+		if _, err = cm.Put(itr.Key().Interface(), itr.Value().Interface()); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+/**
+ * Copies all of the mappings from this map to dst.
+ * dst can be any map type (or pointer to map), e.g. map[string]int,
+ * the mappings are written by reflection.
+ * If dst is a nil map that dst points to, a new map will be created.
+ *
+ * @param dst the map that the mappings be copied to
+ * @return IllegalArgError if dst is not a map,
+ * or a key or value cannot be assigned to the key or element type of dst, dst isn't changed then
+ */
+func (this *ConcurrentMap) CopyToTypedMap(dst interface{}) (err error) {
+	rv := reflect.ValueOf(dst)
+	if rv.IsValid() && rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+		if rv.Kind() == reflect.Map && rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+	}
+	if !rv.IsValid() || rv.Kind() != reflect.Map || rv.IsNil() {
+		return IllegalArgError
+	}
+
+	//all mappings are checked first, so dst isn't changed if any of them cannot be assigned
+	kt, vt := rv.Type().Key(), rv.Type().Elem()
+	kvs := make([]reflect.Value, 0, 2*this.Size())
+	for itr := this.Iterator(); itr.HasNext(); {
+		k, v, _ := itr.Next()
+		rk, rval := reflect.ValueOf(k), reflect.ValueOf(v)
+		if !rk.Type().AssignableTo(kt) || !rval.Type().AssignableTo(vt) {
+			return IllegalArgError
+		}
+		kvs = append(kvs, rk, rval)
+	}
+	for i := 0; i < len(kvs); i += 2 {
+		rv.SetMapIndex(kvs[i], kvs[i+1])
+	}
+	return
+}
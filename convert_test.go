@@ -0,0 +1,85 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSyncMap(t *testing.T) {
+	sm := new(sync.Map)
+	n := 1000
+	for i := 0; i < n; i++ {
+		sm.Store(i, i*10)
+	}
+
+	cm, err := FromSyncMap(sm)
+	if err != nil {
+		t.Errorf("FromSyncMap return error %v, want nil", err)
+	}
+	if s := cm.Size(); s != int32(n) {
+		t.Errorf("Get size of map after FromSyncMap, return %v, want %v", s, n)
+	}
+	for i := 0; i < n; i++ {
+		if v, err := cm.Get(i); v != i*10 || err != nil {
+			t.Errorf("Get %v, return %v, %v, want %v, nil", i, v, err, i*10)
+		}
+	}
+
+	sm1 := cm.ToSyncMap()
+	count := 0
+	sm1.Range(func(k, v interface{}) bool {
+		count++
+		if v != k.(int)*10 {
+			t.Errorf("Load %v from sync.Map, return %v, want %v", k, v, k.(int)*10)
+		}
+		return true
+	})
+	if count != n {
+		t.Errorf("Get size of sync.Map after ToSyncMap, return %v, want %v", count, n)
+	}
+
+	x := 1
+	sm.Store(&x, 1)
+	if _, err = FromSyncMap(sm); err == nil {
+		t.Errorf("FromSyncMap with pointer key, return nil, want error")
+	}
+}
+
+func TestMapReflect(t *testing.T) {
+	cm, err := FromMapReflect(map[string]int{"a": 1, "b": 2})
+	if err != nil {
+		t.Errorf("FromMapReflect return error %v, want nil", err)
+	}
+	if v, err := cm.Get("b"); v != 2 || err != nil {
+		t.Errorf("Get %v, return %v, %v, want %v, nil", "b", v, err, 2)
+	}
+	if _, err = FromMapReflect([]int{1}); err == nil {
+		t.Errorf("FromMapReflect with slice, return nil, want error")
+	}
+
+	dst := map[string]int{}
+	if err = cm.CopyToTypedMap(dst); err != nil || len(dst) != 2 || dst["a"] != 1 {
+		t.Errorf("CopyToTypedMap return %v, %v, want map[a:1 b:2], nil", dst, err)
+	}
+
+	var pdst map[string]int
+	if err = cm.CopyToTypedMap(&pdst); err != nil || len(pdst) != 2 {
+		t.Errorf("CopyToTypedMap to pointer of nil map return %v, %v, want map[a:1 b:2], nil", pdst, err)
+	}
+
+	wrong := map[string]string{}
+	if err = cm.CopyToTypedMap(wrong); err == nil {
+		t.Errorf("CopyToTypedMap to %T, return nil, want error", wrong)
+	}
+
+	//dst isn't changed if any mapping cannot be assigned
+	mixed := NewConcurrentMap()
+	for i := 0; i < 100; i++ {
+		mixed.Put(i, i)
+	}
+	mixed.Put(100, "x")
+	partial := map[int]int{}
+	if err = mixed.CopyToTypedMap(partial); err != IllegalArgError || len(partial) != 0 {
+		t.Errorf("CopyToTypedMap with a wrong value, return %v, %v mappings copied, want IllegalArgError, 0", err, len(partial))
+	}
+}
@@ -0,0 +1,63 @@
+package concurrent
+
+import (
+	"reflect"
+	"sync"
+)
+
+//copiers are the deep-copy functions registered by RegisterCopier, map[reflect.Type]func(v interface{}) interface{}
+var copiers sync.Map
+
+/**
+ * Registers the deep-copy function of the specified type, it is used by KeyCopy and ValueCopy
+ * of Entry and by NextCopy of MapIterator for the keys and values of that type.
+ * The functions should be registered at init, a registration replaces the previous one.
+ *
+ * @param typ the dynamic type of keys or values, e.g. reflect.TypeOf(&Config{})
+ * @param copy returns a deep copy of v, v is never nil
+ */
+func RegisterCopier(typ reflect.Type, copy func(v interface{}) interface{}) {
+	if typ == nil || copy == nil {
+		panic(IllegalArgError)
+	}
+	copiers.Store(typ, copy)
+}
+
+//copyOf returns a copy of v by the registered copier, or v itself if no copier for its type
+func copyOf(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	if copy, ok := copiers.Load(reflect.TypeOf(v)); ok {
+		return copy.(func(v interface{}) interface{})(v)
+	}
+	return v
+}
+
+/**
+ * Returns a deep copy of the key by the copier registered for its type,
+ * or the key itself if no copier is registered, e.g. for the immutable types.
+ */
+func (this *Entry) KeyCopy() interface{} {
+	return copyOf(this.Key())
+}
+
+/**
+ * Returns a deep copy of the value by the copier registered for its type,
+ * or the value itself if no copier is registered, see KeyCopy.
+ * So the mutable shared values can be handed out without being modified through the map.
+ */
+func (this *Entry) ValueCopy() interface{} {
+	return copyOf(this.Value())
+}
+
+/**
+ * Same as Next, but returns the copies of the key and value, see KeyCopy and ValueCopy of Entry.
+ */
+func (this *MapIterator) NextCopy() (key interface{}, value interface{}, ok bool) {
+	if this.nextE == nil {
+		return nil, nil, false
+	}
+	e := this.nextEntry()
+	return e.KeyCopy(), e.ValueCopy(), true
+}
@@ -0,0 +1,57 @@
+package concurrent
+
+import (
+	"reflect"
+	"testing"
+)
+
+type copyConfig struct {
+	Name  string
+	Hosts []string
+}
+
+func init() {
+	RegisterCopier(reflect.TypeOf(&copyConfig{}), func(v interface{}) interface{} {
+		c := *v.(*copyConfig)
+		c.Hosts = append([]string(nil), c.Hosts...)
+		return &c
+	})
+}
+
+func TestEntryCopy(t *testing.T) {
+	cm := NewConcurrentMap()
+	origin := &copyConfig{"a", []string{"h1", "h2"}}
+	cm.Put("cfg", origin)
+	cm.Put("n", 1)
+
+	for itr := cm.Iterator(); itr.HasNext(); {
+		e := itr.nextEntry()
+		if e.Key() != e.KeyCopy() {
+			t.Errorf("KeyCopy, return %v, want %v", e.KeyCopy(), e.Key())
+		}
+		if e.Key() == "n" && e.ValueCopy() != 1 {
+			t.Errorf("ValueCopy without copier, return %v, want 1", e.ValueCopy())
+		}
+		if e.Key() == "cfg" {
+			c := e.ValueCopy().(*copyConfig)
+			if c == origin || !reflect.DeepEqual(c, origin) {
+				t.Errorf("ValueCopy, return %v, want a copy of %v", c, origin)
+			}
+			c.Hosts[0] = "changed"
+			if origin.Hosts[0] != "h1" {
+				t.Errorf("ValueCopy, the copy shares Hosts with the stored value")
+			}
+		}
+	}
+
+	n := 0
+	for itr := cm.Iterator(); itr.HasNext(); n++ {
+		k, v, ok := itr.NextCopy()
+		if !ok || (k == "cfg" && v == origin) {
+			t.Errorf("NextCopy, return %v, %v, %v, want a copy", k, v, ok)
+		}
+	}
+	if n != 2 {
+		t.Errorf("NextCopy, return %v entries, want 2", n)
+	}
+}
@@ -0,0 +1,31 @@
+package concurrent
+
+/**
+ * Returns an Option that transforms the stored values by decoder on Get,
+ * so the values can be stored in a compact form and be decoded lazily, e.g. compressed bytes.
+ * The decoder is called without lock every time a value is got, so it must be safe
+ * for concurrent use and should cache the decoded value itself if decoding is expensive.
+ *
+ * It is applied to the current value returned by Get, GetOrDefault, GetAll, GetWithTimeout,
+ * GetWithTag, GetWithStale, ComputeIfAbsent, Compute, Merge, EntryHandle.Value, Session.Get,
+ * MarshalJSON and ExportRecords.
+ * The previous values returned by the writes, e.g. Put, Swap, Remove and GetAndDelete,
+ * and the values seen by Range, ForEach, Values, ToMap, All, the iterators,
+ * LockedSegments.Get, Tx.Get and the comparisons of CompareAndReplace are the stored values.
+ */
+func WithValueDecoder(decoder func(stored interface{}) interface{}) Option {
+	if decoder == nil {
+		panic(NilActionError)
+	}
+	return func(m *ConcurrentMap) {
+		m.decoder = decoder
+	}
+}
+
+//decode transforms the stored value by decoder, nil is not transformed
+func (this *ConcurrentMap) decode(stored interface{}) interface{} {
+	if this.decoder == nil || stored == nil {
+		return stored
+	}
+	return this.decoder(stored)
+}
@@ -0,0 +1,46 @@
+package concurrent
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithValueDecoder(t *testing.T) {
+	cm := NewConcurrentMap(WithValueDecoder(func(stored interface{}) interface{} {
+		return strings.ToUpper(stored.(string))
+	}))
+	cm.Put(1, "a")
+	cm.PutWithTag(2, "b", 1)
+
+	if v, _ := cm.Get(1); v != "A" {
+		t.Errorf("Get with decoder, return %v, want A", v)
+	}
+	if v, _ := cm.GetWithTimeout(1, time.Second); v != "A" {
+		t.Errorf("GetWithTimeout with decoder, return %v, want A", v)
+	}
+	if v, tag, _ := cm.GetWithTag(2); v != "B" || tag != 1 {
+		t.Errorf("GetWithTag with decoder, return %v, %v, want B, 1", v, tag)
+	}
+	if v, _ := cm.NewSession().Get(1); v != "A" {
+		t.Errorf("Get from session with decoder, return %v, want A", v)
+	}
+	s := cm.NewSession()
+	s.Put(4, "d")
+	if v, _ := s.Get(4); v != "D" {
+		t.Errorf("Get buffered write from session with decoder, return %v, want D", v)
+	}
+	if v, _ := cm.Get(3); v != nil {
+		t.Errorf("Get absent key with decoder, return %v, want nil", v)
+	}
+	//the stored value is not changed
+	if old, _ := cm.Put(1, "c"); old != "a" {
+		t.Errorf("Put with decoder, return %v, want a", old)
+	}
+	if v, loaded, _ := cm.Swap(2, "d"); v != "b" || !loaded {
+		t.Errorf("Swap with decoder, return %v, %v, want b, true", v, loaded)
+	}
+	if v, loaded, _ := cm.GetAndDelete(1); v != "c" || !loaded {
+		t.Errorf("GetAndDelete with decoder, return %v, %v, want c, true", v, loaded)
+	}
+}
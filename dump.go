@@ -0,0 +1,57 @@
+package concurrent
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+/**
+ * Writes the layout of the map to w in the DOT format of Graphviz,
+ * it is used to analyse the pathological layouts offline, e.g. long chains
+ * caused by a poor hash function.
+ *
+ * Every segment is rendered as a node with its count and capacity,
+ * every non-empty bucket as a node with its chain depth,
+ * and every entry as a node with the hash code of its key, linked in chain order.
+ * The keys and values are not written, the graph is named by WithName if the map has a name.
+ *
+ * The tables are read without lock, so the result is weakly consistent like the Iterator.
+ *
+ * @return the error returned by w
+ */
+func (this *ConcurrentMap) DumpStructure(w io.Writer) (err error) {
+	bw := bufio.NewWriter(w)
+	if this.name == "" {
+		fmt.Fprintf(bw, "digraph ConcurrentMap {\n")
+	} else {
+		fmt.Fprintf(bw, "digraph %q {\n", this.name)
+	}
+	fmt.Fprintf(bw, "\tnode [shape=record];\n")
+	for i, seg := range this.segments {
+		tab := seg.loadTable()
+		fmt.Fprintf(bw, "\ts%d [label=\"segment %d|count %d|capacity %d\"];\n",
+			i, i, atomic.LoadInt32(&seg.count), len(tab))
+		for j := 0; j < len(tab); j++ {
+			first := (*Entry)(atomic.LoadPointer(&tab[j]))
+			if first == nil {
+				continue
+			}
+			depth := 0
+			for e := first; e != nil; e = e.next {
+				depth++
+			}
+
+			prev := fmt.Sprintf("s%d_b%d", i, j)
+			fmt.Fprintf(bw, "\t%s [label=\"bucket %d|depth %d\"];\n\ts%d -> %s;\n", prev, j, depth, i, prev)
+			for k, e := 0, first; e != nil; k, e = k+1, e.next {
+				node := fmt.Sprintf("s%d_b%d_e%d", i, j, k)
+				fmt.Fprintf(bw, "\t%s [label=\"%#08x\"];\n\t%s -> %s;\n", node, e.hash, prev, node)
+				prev = node
+			}
+		}
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
@@ -0,0 +1,54 @@
+package concurrent
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type badHashKey int
+
+func (this badHashKey) HashBytes() []byte {
+	return []byte{0}
+}
+
+func (this badHashKey) Equals(v interface{}) bool {
+	v1, ok := v.(badHashKey)
+	return ok && v1 == this
+}
+
+type failedWriter struct{}
+
+func (this failedWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestDumpStructure(t *testing.T) {
+	cm := NewConcurrentMap()
+	for i := 0; i < 5; i++ {
+		cm.Put(badHashKey(i), i)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := cm.DumpStructure(buf); err != nil {
+		t.Errorf("DumpStructure, return %v, want nil", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "digraph ConcurrentMap {") || !strings.HasSuffix(out, "}\n") {
+		t.Errorf("DumpStructure, return %v, want a DOT graph", out)
+	}
+	if n := strings.Count(out, "[label=\"segment "); n != len(cm.segments) {
+		t.Errorf("Count segments in dump, return %v, want %v", n, len(cm.segments))
+	}
+	if !strings.Contains(out, "|depth 5\"]") {
+		t.Errorf("DumpStructure, return %v, want a chain of depth 5", out)
+	}
+	if n := strings.Count(out, "[label=\"0x"); n != 5 {
+		t.Errorf("Count entries in dump, return %v, want 5", n)
+	}
+
+	if err := cm.DumpStructure(failedWriter{}); err == nil {
+		t.Errorf("DumpStructure to failed writer, return nil, want error")
+	}
+}
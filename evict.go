@@ -0,0 +1,315 @@
+package concurrent
+
+import (
+	"container/heap"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	//the candidates of a segment are rebuilt from its table if the stale candidates
+	//are more than the entries plus evictionSlack, so the rebuilding is amortized
+	evictionSlack = 64
+)
+
+//EvictionPolicy decides which mapping is evicted first if the priorities are same, see WithMaxEntries
+type EvictionPolicy int
+
+const (
+	//EVICT_LRU evicts the least recently read or written mapping first, it is the default policy
+	EVICT_LRU EvictionPolicy = iota
+	//EVICT_FIFO evicts the earliest added mapping first, the reads and updates are not recorded,
+	//so it is cheaper than EVICT_LRU and the turnover is predictable
+	EVICT_FIFO
+)
+
+//evictionCandidate records an entry that may be evicted, it is validated against
+//the entry when it is popped, because the entry may have been removed, read or re-prioritized
+type evictionCandidate struct {
+	key      interface{}
+	hash     uint32
+	priority int8
+	accessed int64
+}
+
+//candidateHeap orders the candidates by priority, then by the last access time
+type candidateHeap []evictionCandidate
+
+func (this candidateHeap) Len() int {
+	return len(this)
+}
+
+func (this candidateHeap) Less(i, j int) bool {
+	if this[i].priority != this[j].priority {
+		return this[i].priority < this[j].priority
+	}
+	return this[i].accessed < this[j].accessed
+}
+
+func (this candidateHeap) Swap(i, j int) {
+	this[i], this[j] = this[j], this[i]
+}
+
+func (this *candidateHeap) Push(x interface{}) {
+	*this = append(*this, x.(evictionCandidate))
+}
+
+func (this *candidateHeap) Pop() interface{} {
+	old := *this
+	c := old[len(old)-1]
+	old[len(old)-1] = evictionCandidate{}
+	*this = old[:len(old)-1]
+	return c
+}
+
+//evictor bounds the number of mappings in a segment
+type evictor struct {
+	limit      int32
+	candidates candidateHeap
+}
+
+//evictionPacer evicts the mappings of bounded map in background, see WithEvictionPacing
+type evictionPacer struct {
+	running int32 //atomic, 1 if the pacing goroutine is running
+	batch   int
+	tick    time.Duration
+}
+
+/**
+ * Returns an Option that bounds the number of mappings, when a Put adds a mapping
+ * and the bound is exceeded, the mappings with the lowest priority are evicted,
+ * and the least recently read or written one is evicted first if the priorities are same,
+ * see WithEvictionPolicy for other policies.
+ * The mapping that is being put is never evicted by its own Put.
+ *
+ * The bound is split among the segments and every segment evicts its own mappings
+ * while holding its lock, so a mapping may be evicted before the size of map reaches n.
+ * n must not be less than the number of segments, pass a smaller concurrencyLevel for a small bound.
+ * The evictions are notified like Remove, but they are not delivered by Expired.
+ * The evictions of a segment are deferred while it is pinned by ForEachPinned.
+ */
+func WithMaxEntries(n int) Option {
+	if n <= 0 {
+		panic(IllegalArgError)
+	}
+	return func(m *ConcurrentMap) {
+		segs := len(m.segments)
+		if n < segs {
+			panic(IllegalArgError)
+		}
+		for i, seg := range m.segments {
+			limit := n / segs
+			if i < n%segs {
+				limit++
+			}
+			seg.evictor = &evictor{limit: int32(limit)}
+		}
+	}
+}
+
+/**
+ * Returns an Option that sets the eviction policy of a bounded map, see WithMaxEntries.
+ * It has no effect if the map is unbounded.
+ */
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	if policy != EVICT_LRU && policy != EVICT_FIFO {
+		panic(IllegalArgError)
+	}
+	return func(m *ConcurrentMap) {
+		m.evictionPolicy = policy
+	}
+}
+
+/**
+ * Returns an Option that moves the evictions of a bounded map out of Put, see WithMaxEntries.
+ * A Put that exceeds the bound only records the new mapping, and a background goroutine
+ * evicts at most batch mappings of every segment per tick, so a Put never absorbs
+ * a large eviction pause. The size of map may exceed the bound until the evictions catch up,
+ * and the mapping that was just put may be evicted.
+ *
+ * The goroutine is started when a segment exceeds its bound, and exits when no segment
+ * exceeds its bound or the map is closed. It has no effect if the map is unbounded.
+ */
+func WithEvictionPacing(batch int, tick time.Duration) Option {
+	if batch <= 0 || tick <= 0 {
+		panic(IllegalArgError)
+	}
+	return func(m *ConcurrentMap) {
+		m.evictionPacer = &evictionPacer{batch: batch, tick: tick}
+	}
+}
+
+/**
+ * Maps the specified key to the specified value with an eviction priority,
+ * the mappings with lower priority are evicted before the mappings with higher priority
+ * regardless of recency, see WithMaxEntries. The priority is kept by later writes of the key,
+ * and a mapping that is put without priority has priority 0.
+ * Neither the key nor the value can be nil.
+ *
+ * @return the previous value associated with key, or nil if there was no mapping for key
+ */
+func (this *ConcurrentMap) PutWithPriority(key interface{}, value interface{}, priority int8) (oldVal interface{}, err error) {
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+	if isNil(value) {
+		return nil, NilValueError
+	}
+	if e := this.validate(key, value); e != nil {
+		return nil, e
+	}
+
+	hash, err := hashKey(key, this, false)
+	if err != nil {
+		return
+	}
+	Printf("PutWithPriority, %v, %v\n", key, hash)
+	seg := this.segmentFor(hash)
+	seg.acquire()
+	defer seg.lock.Unlock()
+	oldVal = seg.putUnderLock(key, hash, value, false, nil, 0)
+	if e := seg.findUnderLock(key, hash); e != nil && e.priority != priority {
+		e.priority = priority
+		if seg.evictor != nil {
+			heap.Push(&seg.evictor.candidates, evictionCandidate{key, hash, priority, atomic.LoadInt64(&e.accessed)})
+		}
+	}
+	return
+}
+
+/**
+ * Records the access of entry if the map is bounded by EVICT_LRU.
+ */
+func (this *Segment) touch(e *Entry) {
+	if this.evictor != nil && this.m.evictionPolicy == EVICT_LRU {
+		atomic.StoreInt64(&e.accessed, time.Now().UnixNano())
+	}
+}
+
+/**
+ * Records the new entry as a candidate, and evicts the candidates
+ * until the segment doesn't exceed its limit, e is never evicted.
+ * If WithEvictionPacing is used, the evictions are left to the pacing goroutine.
+ * Call only while holding lock.
+ */
+func (this *Segment) admit(e *Entry) {
+	ev := this.evictor
+	now := time.Now().UnixNano()
+	atomic.StoreInt64(&e.accessed, now)
+	heap.Push(&ev.candidates, evictionCandidate{e.key, e.hash, e.priority, now})
+
+	if this.pinned() {
+		//the evictions are deferred until the segment is unpinned, see ForEachPinned
+		return
+	}
+
+	if p := this.m.evictionPacer; p != nil {
+		if this.count > ev.limit && atomic.CompareAndSwapInt32(&p.running, 0, 1) {
+			go this.m.paceEvictions(p)
+		}
+	} else {
+		this.evict(e, -1)
+	}
+
+	if len(ev.candidates) > 2*int(this.count)+evictionSlack {
+		this.rebuildCandidates()
+	}
+}
+
+/**
+ * Evicts the candidates until the segment doesn't exceed its limit or max mappings are evicted,
+ * max < 0 means no max. The kept entry is never evicted if it isn't nil.
+ * Call only while holding lock.
+ *
+ * @return the number of evicted mappings
+ */
+func (this *Segment) evict(kept *Entry, max int) (n int) {
+	ev := this.evictor
+	var keptCandidate *evictionCandidate
+	for this.count > ev.limit && len(ev.candidates) > 0 && (max < 0 || n < max) {
+		c := heap.Pop(&ev.candidates).(evictionCandidate)
+		victim := this.find(c.key, c.hash)
+		switch {
+		case victim == nil || victim.priority != c.priority:
+			//the candidate is stale, the entry was removed or re-prioritized
+		case kept != nil && victim.hash == kept.hash && equals(victim.key, kept.key):
+			//kept may have been cloned by the removals
+			keptCandidate = &c
+		case atomic.LoadInt64(&victim.accessed) > c.accessed:
+			//the entry was accessed after the candidate was recorded
+			c.accessed = atomic.LoadInt64(&victim.accessed)
+			heap.Push(&ev.candidates, c)
+		default:
+			Printf("Evict, %v, %v\n", c.key, c.hash)
+			this.removeUnderLock(c.key, c.hash, nil)
+			n++
+		}
+	}
+	if keptCandidate != nil {
+		heap.Push(&ev.candidates, *keptCandidate)
+	}
+	return
+}
+
+//paceEvictions evicts a batch of every segment that exceeds its limit at every tick,
+//it exits if the map is closed or no segment exceeds its limit
+func (this *ConcurrentMap) paceEvictions(p *evictionPacer) {
+	ticker := time.NewTicker(p.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-this.closed:
+			atomic.StoreInt32(&p.running, 0)
+			return
+		}
+
+		exceeded := false
+		for _, seg := range this.segments {
+			if atomic.LoadInt32(&seg.count) <= seg.evictor.limit {
+				continue
+			}
+			seg.acquire()
+			if !seg.pinned() {
+				seg.evict(nil, p.batch)
+			}
+			exceeded = exceeded || seg.count > seg.evictor.limit
+			seg.lock.Unlock()
+		}
+		if !exceeded {
+			atomic.StoreInt32(&p.running, 0)
+			//a Put may exceed the limit after the check but before running was reset
+			if !this.exceedsLimit() || !atomic.CompareAndSwapInt32(&p.running, 0, 1) {
+				return
+			}
+		}
+	}
+}
+
+//exceedsLimit returns true if any segment exceeds its limit
+func (this *ConcurrentMap) exceedsLimit() bool {
+	for _, seg := range this.segments {
+		if atomic.LoadInt32(&seg.count) > seg.evictor.limit {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Drops the stale candidates of removed entries by rebuilding the candidates from table.
+ * Call only while holding lock.
+ */
+func (this *Segment) rebuildCandidates() {
+	ev := this.evictor
+	candidates := make(candidateHeap, 0, this.count)
+	tab := this.table()
+	for i := 0; i < len(tab); i++ {
+		for e := (*Entry)(tab[i]); e != nil; e = e.next {
+			candidates = append(candidates, evictionCandidate{e.key, e.hash, e.priority, atomic.LoadInt64(&e.accessed)})
+		}
+	}
+	heap.Init(&candidates)
+	ev.candidates = candidates
+}
@@ -0,0 +1,150 @@
+package concurrent
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithMaxEntries(t *testing.T) {
+	cm := NewConcurrentMap(16, float32(0.75), 1, WithMaxEntries(100))
+	for i := 0; i < 100; i++ {
+		cm.Put(i, i)
+	}
+	//reads 0-9, so 10-19 are the least recently used
+	for i := 0; i < 10; i++ {
+		cm.Get(i)
+	}
+	for i := 100; i < 110; i++ {
+		cm.Put(i, i)
+	}
+	if s := cm.Size(); s != 100 {
+		t.Errorf("Get size of bounded map, return %v, want 100", s)
+	}
+	for i := 0; i < 20; i++ {
+		v, _ := cm.Get(i)
+		if i < 10 && v != i {
+			t.Errorf("Get recently used %v, return %v, want %v", i, v, i)
+		} else if i >= 10 && v != nil {
+			t.Errorf("Get evicted %v, return %v, want nil", i, v)
+		}
+	}
+
+	//many removals do not leak the candidates
+	for i := 1000; i < 10000; i++ {
+		cm.Put(i, i)
+		cm.Remove(i)
+	}
+	if n := len(cm.segments[0].evictor.candidates); n > 2*100+evictionSlack {
+		t.Errorf("Get count of candidates, return %v, want <= %v", n, 2*100+evictionSlack)
+	}
+
+	defer func() {
+		if r := recover(); r != IllegalArgError {
+			t.Errorf("WithMaxEntries less than segments, panic %v, want IllegalArgError", r)
+		}
+	}()
+	NewConcurrentMap(WithMaxEntries(8))
+}
+
+func TestPutWithPriority(t *testing.T) {
+	cm := NewConcurrentMap(16, float32(0.75), 1, WithMaxEntries(10))
+	for i := 0; i < 5; i++ {
+		cm.PutWithPriority(i, i, 1)
+	}
+	for i := 5; i < 10; i++ {
+		cm.Put(i, i)
+	}
+	//the priority is kept by Put
+	cm.Put(0, 0)
+	//the high priority mappings are not evicted even if they are least recently used
+	for i := 10; i < 15; i++ {
+		cm.Put(i, i)
+	}
+	for i := 0; i < 15; i++ {
+		v, _ := cm.Get(i)
+		if (i < 5 || i >= 10) && v != i {
+			t.Errorf("Get %v, return %v, want %v", i, v, i)
+		} else if i >= 5 && i < 10 && v != nil {
+			t.Errorf("Get evicted low priority %v, return %v, want nil", i, v)
+		}
+	}
+
+	//lowers the priority of 0, so it is evicted first
+	cm.PutWithPriority(0, 0, -1)
+	cm.Put(15, 15)
+	if v, _ := cm.Get(0); v != nil {
+		t.Errorf("Get 0 after lowering priority, return %v, want nil", v)
+	}
+	if v, _ := cm.Get(1); v != 1 {
+		t.Errorf("Get 1, return %v, want 1", v)
+	}
+	if _, err := cm.PutWithPriority(nil, 1, 1); err != NilKeyError {
+		t.Errorf("PutWithPriority nil key, return %v, want NilKeyError", err)
+	}
+}
+
+func TestFIFOEviction(t *testing.T) {
+	cm := NewConcurrentMap(16, float32(0.75), 1, WithEvictionPolicy(EVICT_FIFO), WithMaxEntries(10))
+	for i := 0; i < 10; i++ {
+		cm.Put(i, i)
+	}
+	//the reads and updates do not change the order
+	cm.Get(0)
+	cm.Put(1, 1)
+	for i := 10; i < 15; i++ {
+		cm.Put(i, i)
+	}
+	for i := 0; i < 15; i++ {
+		v, _ := cm.Get(i)
+		if i < 5 && v != nil {
+			t.Errorf("Get evicted %v, return %v, want nil", i, v)
+		} else if i >= 5 && v != i {
+			t.Errorf("Get %v, return %v, want %v", i, v, i)
+		}
+	}
+}
+
+func TestEvictionPacing(t *testing.T) {
+	cm := NewConcurrentMap(16, float32(0.75), 1, WithEvictionPolicy(EVICT_FIFO), WithMaxEntries(100),
+		WithEvictionPacing(50, 5*time.Millisecond))
+	defer cm.Close()
+	for i := 0; i < 300; i++ {
+		cm.Put(i, i)
+	}
+	//the Puts don't evict
+	if s := cm.Size(); s != 300 {
+		t.Errorf("Size after Puts, return %v, want 300", s)
+	}
+
+	//the pacer evicts 50 per tick, so it needs at least 4 ticks
+	waitPacingDone(t, cm)
+	if s := cm.Size(); s != 100 {
+		t.Errorf("Size after pacing, return %v, want 100", s)
+	}
+	for i := 200; i < 300; i++ {
+		if v, _ := cm.Get(i); v != i {
+			t.Errorf("Get %v after pacing, return %v, want %v", i, v, i)
+		}
+	}
+
+	//the pacer is started again after it exited
+	for i := 300; i < 350; i++ {
+		cm.Put(i, i)
+	}
+	waitPacingDone(t, cm)
+	if s := cm.Size(); s != 100 {
+		t.Errorf("Size after pacing again, return %v, want 100", s)
+	}
+}
+
+//waitPacingDone waits until the pacing goroutine exits, it exits only after all segments are within their limits
+func waitPacingDone(t *testing.T, cm *ConcurrentMap) {
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&cm.evictionPacer.running) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("pacing is still running after 1s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
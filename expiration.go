@@ -0,0 +1,82 @@
+package concurrent
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+/**
+ * Expiration is a mapping that will expire, it is returned by NextExpirations.
+ */
+type Expiration struct {
+	Key      interface{}
+	Value    interface{}
+	ExpireAt time.Time
+}
+
+/**
+ * Returns at most n mappings that will expire soonest, in the order of expiration time.
+ * So the schedulers can align their own work with the imminent expirations.
+ *
+ * The mappings are found by the timing wheel or coarse buckets without scanning the map,
+ * but all scheduled timers are visited, so it is O(timers) rather than O(n).
+ * The result is weakly consistent like MapIterator.
+ */
+func (this *ConcurrentMap) NextExpirations(n int) (exps []Expiration) {
+	if n <= 0 {
+		return nil
+	}
+
+	var tick int64
+	var walk func(f func(timers []*wheelTimer) bool)
+	if c := this.coarseTTL; c != nil {
+		tick, walk = c.granularity, c.walk
+	} else {
+		wheel := this.timingWheel()
+		tick, walk = wheel.tick, wheel.walk
+	}
+	now := time.Now().UnixNano()
+	walk(func(timers []*wheelTimer) bool {
+		found := make([]Expiration, 0, len(timers))
+		for _, t := range timers {
+			e := this.segmentFor(t.hash).find(t.key, t.hash)
+			if e == nil {
+				continue
+			}
+			//the timer is stale if the expiration time of entry was changed after scheduling
+			expireAt := atomic.LoadInt64(&e.expireAt)
+			if expireAt == 0 || expireAt <= now || expireAt/tick+1 != t.deadline {
+				continue
+			}
+			if duplicated(found, t.key, expireAt) {
+				continue
+			}
+			found = append(found, Expiration{Key: t.key, Value: e.Value(), ExpireAt: time.Unix(0, expireAt)})
+		}
+
+		exps = append(exps, found...)
+		//a slot of higher level may hold earlier expirations than the slots of lower level,
+		//so all slots are walked and the expirations are sorted before truncating
+		return true
+	})
+
+	sort.Slice(exps, func(i, j int) bool {
+		return exps[i].ExpireAt.Before(exps[j].ExpireAt)
+	})
+	if len(exps) > n {
+		exps = exps[:n]
+	}
+	return
+}
+
+//duplicated returns true if key has been found, the key may have multiple timers
+//with same deadline if the same expiration time was set more than once
+func duplicated(found []Expiration, key interface{}, expireAt int64) bool {
+	for _, exp := range found {
+		if exp.ExpireAt.UnixNano() == expireAt && equals(exp.Key, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package concurrent
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+/**
+ * ExportFormat is the format of records written by ExportRecords.
+ */
+type ExportFormat int
+
+const (
+	//JSON Lines, i.e. a JSON object per line
+	EXPORT_JSON ExportFormat = iota
+	//CSV with a header row
+	EXPORT_CSV
+)
+
+type recordField struct {
+	name  string
+	index []int
+}
+
+/**
+ * recordSchema is the columns of records, a column for the key and a column for every exported field
+ * of the struct value. If the values are not structs, there is a single column named "value".
+ */
+type recordSchema struct {
+	typ    reflect.Type
+	fields []recordField
+}
+
+func newRecordSchema(v interface{}) *recordSchema {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+		t = t.Elem()
+	}
+	schema := &recordSchema{typ: t}
+	if t.Kind() != reflect.Struct {
+		schema.fields = []recordField{{name: "value"}}
+		return schema
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			}
+		}
+		schema.fields = append(schema.fields, recordField{name, f.Index})
+	}
+	return schema
+}
+
+/**
+ * Returns the values of fields of v, the fields are zero values if v is a nil pointer.
+ */
+func (this *recordSchema) values(v interface{}) ([]interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && rv.Type().Elem() == this.typ {
+		if rv.IsNil() {
+			rv = reflect.Zero(this.typ)
+		} else {
+			rv = rv.Elem()
+		}
+	}
+	if rv.Type() != this.typ {
+		return nil, IllegalArgError
+	}
+	if this.typ.Kind() != reflect.Struct {
+		return []interface{}{v}, nil
+	}
+
+	values := make([]interface{}, len(this.fields))
+	for i, f := range this.fields {
+		values[i] = rv.FieldByIndex(f.index).Interface()
+	}
+	return values, nil
+}
+
+//recordWriter writes the records in an ExportFormat
+type recordWriter interface {
+	header(names []string) error
+	record(values []interface{}) error
+	flush() error
+}
+
+type jsonRecordWriter struct {
+	w     *bufio.Writer
+	names [][]byte
+}
+
+func (this *jsonRecordWriter) header(names []string) (err error) {
+	for _, name := range names {
+		b, _ := json.Marshal(name)
+		this.names = append(this.names, b)
+	}
+	return
+}
+
+//record writes the fields in the order of schema, so the JSON objects cannot be encoded by json.Marshal
+func (this *jsonRecordWriter) record(values []interface{}) error {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for i, v := range values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(this.names[i])
+		buf.WriteByte(':')
+		buf.Write(b)
+	}
+	buf.WriteString("}\n")
+	_, err := this.w.Write(buf.Bytes())
+	return err
+}
+
+func (this *jsonRecordWriter) flush() error {
+	return this.w.Flush()
+}
+
+type csvRecordWriter struct {
+	w *csv.Writer
+}
+
+func (this *csvRecordWriter) header(names []string) error {
+	return this.w.Write(names)
+}
+
+//record writes the strings and scalar values by fmt, and other values by json.Marshal
+func (this *csvRecordWriter) record(values []interface{}) error {
+	row := make([]string, len(values))
+	for i, v := range values {
+		switch rv := reflect.ValueOf(v); rv.Kind() {
+		case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Ptr, reflect.Interface:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			row[i] = string(b)
+		default:
+			row[i] = fmt.Sprint(v)
+		}
+	}
+	return this.w.Write(row)
+}
+
+func (this *csvRecordWriter) flush() error {
+	this.w.Flush()
+	return this.w.Error()
+}
+
+/**
+ * Writes every mapping as a typed record, so the exports are consumable by analytics tools.
+ * The first column is the key that is converted like MarshalJSON, the other columns are
+ * the exported fields of the struct value, they are named by the json tags of fields,
+ * and the fields tagged "-" are skipped. The schema is decided by the first value,
+ * so all values must have the same type, the struct or pointer to the struct.
+ * The result is weakly consistent like the Iterator.
+ *
+ * @return IllegalArgError if any key cannot be converted or any value has another type,
+ *         or the error returned by w
+ */
+func (this *ConcurrentMap) ExportRecords(w io.Writer, format ExportFormat) (err error) {
+	var rw recordWriter
+	switch format {
+	case EXPORT_JSON:
+		rw = &jsonRecordWriter{w: bufio.NewWriter(w)}
+	case EXPORT_CSV:
+		rw = &csvRecordWriter{w: csv.NewWriter(w)}
+	default:
+		return IllegalArgError
+	}
+
+	var schema *recordSchema
+	for _, seg := range this.segments {
+		seg.walk(func(e *Entry) {
+			if err != nil {
+				return
+			}
+			v := this.decode(e.Value())
+			if schema == nil {
+				schema = newRecordSchema(v)
+				names := []string{"key"}
+				for _, f := range schema.fields {
+					names = append(names, f.name)
+				}
+				if err = rw.header(names); err != nil {
+					return
+				}
+			}
+
+			var key string
+			var values []interface{}
+			if key, err = this.jsonKeys.encodeKey(e.key); err != nil {
+				return
+			}
+			if values, err = schema.values(v); err != nil {
+				return
+			}
+			err = rw.record(append([]interface{}{key}, values...))
+		})
+		if err != nil {
+			return
+		}
+	}
+	return rw.flush()
+}
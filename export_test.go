@@ -0,0 +1,55 @@
+package concurrent
+
+import (
+	"bytes"
+	"testing"
+)
+
+type exportValue struct {
+	Name    string `json:"name"`
+	Score   float64
+	Tags    []string `json:"tags,omitempty"`
+	Secret  string   `json:"-"`
+	private int
+}
+
+func TestExportRecords(t *testing.T) {
+	cm := NewConcurrentMap()
+	cm.Put("a", exportValue{Name: "x, y", Score: 1.5, Tags: []string{"t"}, Secret: "s"})
+
+	buf := new(bytes.Buffer)
+	if err := cm.ExportRecords(buf, EXPORT_JSON); err != nil {
+		t.Errorf("ExportRecords JSON, return error %v", err)
+	}
+	if s, want := buf.String(), `{"key":"a","name":"x, y","Score":1.5,"tags":["t"]}`+"\n"; s != want {
+		t.Errorf("ExportRecords JSON, write %v, want %v", s, want)
+	}
+
+	buf.Reset()
+	if err := cm.ExportRecords(buf, EXPORT_CSV); err != nil {
+		t.Errorf("ExportRecords CSV, return error %v", err)
+	}
+	if s, want := buf.String(), "key,name,Score,tags\na,\"x, y\",1.5,\"[\"\"t\"\"]\"\n"; s != want {
+		t.Errorf("ExportRecords CSV, write %v, want %v", s, want)
+	}
+
+	//the pointers to struct use the same schema
+	cm = NewConcurrentMap()
+	cm.Put(1, &exportValue{Name: "p"})
+	buf.Reset()
+	if err := cm.ExportRecords(buf, EXPORT_CSV); err != nil || buf.String() != "key,name,Score,tags\n1,p,0,null\n" {
+		t.Errorf("ExportRecords pointer values, write %v, %v", buf.String(), err)
+	}
+
+	cm.Put(2, "not a struct")
+	if err := cm.ExportRecords(new(bytes.Buffer), EXPORT_JSON); err != IllegalArgError {
+		t.Errorf("ExportRecords values of different types, return %v, want IllegalArgError", err)
+	}
+
+	cm = NewConcurrentMap()
+	cm.Put(1, 10)
+	buf.Reset()
+	if err := cm.ExportRecords(buf, EXPORT_JSON); err != nil || buf.String() != `{"key":"1","value":10}`+"\n" {
+		t.Errorf("ExportRecords scalar values, write %v, %v", buf.String(), err)
+	}
+}
@@ -0,0 +1,139 @@
+package concurrent
+
+import (
+	"sync/atomic"
+)
+
+/**
+ * EntryHandle points to the entry of a mapping, it is returned by PutWithHandle and
+ * PutIfAbsentWithHandle. The value can be read and updated through the handle
+ * without hashing the key and traversing the table again, e.g. for the hot keys
+ * that are updated in tight loops.
+ *
+ * The handle is invalidated if the entries of its segment are cloned or unlinked,
+ * i.e. by any remove, rehash, Clear or Compact of the segment, even if its own mapping is still in map.
+ * The methods return IllegalStateError after the handle is invalidated,
+ * a new handle can be got by PutIfAbsentWithHandle then.
+ */
+type EntryHandle struct {
+	seg    *Segment
+	e      *Entry
+	layout int32
+}
+
+/**
+ * Same as Put, and returns a handle of the mapping.
+ */
+func (this *ConcurrentMap) PutWithHandle(key interface{}, value interface{}) (oldVal interface{}, handle *EntryHandle, err error) {
+	return this.putWithHandle(key, value, false)
+}
+
+/**
+ * Same as PutIfAbsent, and returns a handle of the mapping,
+ * it points to the previous mapping if the key was mapped.
+ */
+func (this *ConcurrentMap) PutIfAbsentWithHandle(key interface{}, value interface{}) (oldVal interface{}, handle *EntryHandle, err error) {
+	return this.putWithHandle(key, value, true)
+}
+
+func (this *ConcurrentMap) putWithHandle(key interface{}, value interface{}, onlyIfAbsent bool) (oldVal interface{}, handle *EntryHandle, err error) {
+	if isNil(key) {
+		return nil, nil, NilKeyError
+	}
+	if isNil(value) {
+		return nil, nil, NilValueError
+	}
+	if e := this.validate(key, value); e != nil {
+		return nil, nil, e
+	}
+
+	hash, err := hashKey(key, this, false)
+	if err != nil {
+		return
+	}
+	Printf("PutWithHandle, %v, %v\n", key, hash)
+	seg := this.segmentFor(hash)
+	seg.acquire()
+	defer seg.lock.Unlock()
+	oldVal = seg.putUnderLock(key, hash, value, onlyIfAbsent, nil, 0)
+	handle = &EntryHandle{seg: seg, e: seg.findUnderLock(key, hash), layout: seg.layout}
+	return
+}
+
+/**
+ * Returns true if the handle has not been invalidated and its mapping has not expired.
+ */
+func (this *EntryHandle) Valid() bool {
+	return atomic.LoadInt32(&this.seg.layout) == this.layout && !this.e.expired()
+}
+
+/**
+ * Returns the key of mapping.
+ */
+func (this *EntryHandle) Key() interface{} {
+	return this.e.key
+}
+
+/**
+ * Returns the value of mapping without lock.
+ *
+ * @return IllegalStateError if the handle has been invalidated
+ */
+func (this *EntryHandle) Value() (value interface{}, err error) {
+	if !this.Valid() {
+		return nil, IllegalStateError
+	}
+	return this.seg.m.decode(this.e.Value()), nil
+}
+
+/**
+ * Replaces the value of mapping like Replace, the expiration time is kept.
+ *
+ * @return the previous value, or IllegalStateError if the handle has been invalidated
+ */
+func (this *EntryHandle) Set(value interface{}) (oldVal interface{}, err error) {
+	if isNil(value) {
+		return nil, NilValueError
+	}
+	//the value is validated by Update
+	return this.Update(func(interface{}) interface{} {
+		return value
+	})
+}
+
+/**
+ * Replaces the value of mapping by the value that action returns, the action is called
+ * with the current value under the segment lock like Update. The expiration time is kept.
+ * If action returns nil, the mapping is removed and the handle is invalidated.
+ *
+ * @return the previous value, or IllegalStateError if the handle has been invalidated
+ */
+func (this *EntryHandle) Update(action func(oldVal interface{}) (newVal interface{})) (oldVal interface{}, err error) {
+	if action == nil {
+		return nil, NilActionError
+	}
+	seg := this.seg
+	defer seg.m.recoverCallback(&err)
+	defer recoverRejection(&err)
+	seg.acquire()
+	defer seg.lock.Unlock()
+	if seg.layout != this.layout || this.e.expired() {
+		return nil, IllegalStateError
+	}
+
+	e := this.e
+	old := e.fastValue()
+	newVal := action(old)
+	seg.m.checkComputed(e.key, newVal)
+	if oldVal = old; newVal == nil {
+		seg.removeUnderLock(e.key, e.hash, nil)
+		return
+	}
+	seg.mutated(e.key, e.hash, oldVal, newVal)
+	if this.e = seg.setValue(e, newVal); this.e != e {
+		//the entry was replaced by this handle itself, so the handle is still valid
+		this.layout = seg.layout
+	}
+	seg.touch(this.e)
+	return
+}
@@ -0,0 +1,69 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestEntryHandle(t *testing.T) {
+	cm := NewConcurrentMap()
+	_, h, err := cm.PutWithHandle("counter", 0)
+	if err != nil || !h.Valid() || h.Key() != "counter" {
+		t.Errorf("PutWithHandle, return %v, %v, want a valid handle", h, err)
+	}
+
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				h.Update(func(oldVal interface{}) interface{} {
+					return oldVal.(int) + 1
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := cm.Get("counter"); v != 4000 {
+		t.Errorf("Get after updates by handle, return %v, want 4000", v)
+	}
+
+	//the representation change keeps the handle valid
+	if old, err := h.Set("str"); old != 4000 || err != nil {
+		t.Errorf("Set by handle, return %v, %v, want 4000, nil", old, err)
+	}
+	if v, err := h.Value(); v != "str" || err != nil {
+		t.Errorf("Value of handle, return %v, %v, want str, nil", v, err)
+	}
+
+	old, h2, _ := cm.PutIfAbsentWithHandle("counter", 1)
+	if v, _ := h2.Value(); old != "str" || v != "str" {
+		t.Errorf("PutIfAbsentWithHandle existing key, return %v and handle of %v, want str", old, v)
+	}
+
+	//any remove of segment invalidates the handle
+	for i := 0; i < 100; i++ {
+		cm.Put(i, i)
+	}
+	for i := 0; i < 100; i++ {
+		cm.Remove(i)
+	}
+	if h.Valid() {
+		t.Errorf("Valid after removes, return true, want false")
+	}
+	if _, err := h.Set(1); err != IllegalStateError {
+		t.Errorf("Set by invalid handle, return %v, want IllegalStateError", err)
+	}
+	if _, err := h.Value(); err != IllegalStateError {
+		t.Errorf("Value of invalid handle, return %v, want IllegalStateError", err)
+	}
+
+	_, h, _ = cm.PutIfAbsentWithHandle("counter", 1)
+	h.Update(func(oldVal interface{}) interface{} {
+		return nil
+	})
+	if v, _ := cm.Get("counter"); v != nil || h.Valid() {
+		t.Errorf("Get after removed by handle, return %v, want nil and invalid handle", v)
+	}
+}
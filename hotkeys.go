@@ -0,0 +1,326 @@
+package concurrent
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+const (
+	hotKeySketchDepth = 4
+	hotKeySketchBits  = 10
+	hotKeySketchWidth = 1 << hotKeySketchBits
+	//only 1/hotKeySampling of Gets are counted, so the counters of sketch aren't contended by the hot keys
+	hotKeySampling = 16
+	//the counters are halved after every hotKeyWindow counted Gets, so the old accesses are forgotten
+	hotKeyWindow = 1 << 14
+	//a key is hot if its count is hotKeyRatio times more than the average of sketch counters,
+	//and isn't less than hotKeyMinCount
+	hotKeyRatio    = 8
+	hotKeyMinCount = 32
+	//the number of tracked hot keys if it isn't specified by WithHotKeyDetection
+	defaultHotKeyLimit = 8
+	cacheLineSize      = 64
+)
+
+var hotKeySeeds = [hotKeySketchDepth]uint32{0x9e3779b1, 0x85ebca77, 0xc2b2ae3d, 0x27d4eb2f}
+
+/**
+ * HotKey is a key whose access rate dwarfs others, see HotKeys.
+ */
+type HotKey struct {
+	Key interface{}
+	//the estimated number of Gets of the key in the recent window
+	Hits int64
+}
+
+//replicaValue is a copy of the value of hot key
+type replicaValue struct {
+	value    interface{}
+	expireAt int64
+}
+
+//replicaSlot is padded to a cache line, so the readers of different slots don't share the cache line
+type replicaSlot struct {
+	value unsafe.Pointer //*replicaValue, atomic
+	_     [cacheLineSize - unsafe.Sizeof(unsafe.Pointer(nil))]byte
+}
+
+/**
+ * hotKey is a tracked hot key, the slots are nil if replication isn't enabled.
+ * All slots hold the same replicaValue, they are filled and invalidated only while holding
+ * the lock of the segment of key, so they never hold the value that has been overwritten.
+ */
+type hotKey struct {
+	key   interface{}
+	hash  uint32
+	slots []replicaSlot
+}
+
+/**
+ * hotKeyDetector counts the Gets by a count-min sketch and tracks the hot keys,
+ * see WithHotKeyDetection.
+ */
+type hotKeyDetector struct {
+	total  int64 //atomic, the sum of the counters in every row of sketch
+	sketch [hotKeySketchDepth][hotKeySketchWidth]int32
+	//the max number of tracked hot keys
+	limit int
+	//the number of replicas of every hot key, 0 means replication isn't enabled
+	replicas int
+
+	lock sync.Mutex
+	hot  unsafe.Pointer //*[]*hotKey, copy-on-write while holding lock
+}
+
+/**
+ * Returns an Option that enables the detection of hot keys, i.e. the keys whose access rate
+ * dwarfs others, see HotKeys. The Gets are sampled and counted by a count-min sketch,
+ * and at most limit hot keys are tracked.
+ */
+func WithHotKeyDetection(limit int) Option {
+	if limit <= 0 {
+		panic(IllegalArgError)
+	}
+	return func(m *ConcurrentMap) {
+		m.hotKeyDetector().limit = limit
+	}
+}
+
+/**
+ * Returns an Option that spreads the Gets of hot keys across the specified number of replicas,
+ * every replica is padded to a cache line, so the readers of a hot key don't contend for
+ * the cache line of its entry. The detection of hot keys is enabled if it isn't.
+ *
+ * The replicas are invalidated by every mutation of the key and are filled again by a later Get,
+ * so the Gets still see the latest value. But the Gets that are served by replicas
+ * don't update the access time of entry, see WithEvictionPolicy.
+ */
+func WithHotKeyReplication(replicas int) Option {
+	if replicas <= 0 {
+		panic(IllegalArgError)
+	}
+	return func(m *ConcurrentMap) {
+		m.hotKeyDetector().replicas = replicas
+	}
+}
+
+func (this *ConcurrentMap) hotKeyDetector() *hotKeyDetector {
+	if this.hotKeys == nil {
+		this.hotKeys = &hotKeyDetector{limit: defaultHotKeyLimit}
+		this.listeners = append(this.listeners, this.hotKeys)
+	}
+	return this.hotKeys
+}
+
+/**
+ * Returns the hot keys in the order of hits, the most frequently read key is the first.
+ * The hits are estimated from the sampled Gets in the recent window.
+ *
+ * @return nil if the detection of hot keys isn't enabled
+ */
+func (this *ConcurrentMap) HotKeys() []HotKey {
+	h := this.hotKeys
+	if h == nil {
+		return nil
+	}
+	keys := make([]HotKey, 0, h.limit)
+	total := atomic.LoadInt64(&h.total)
+	for _, k := range h.tracked() {
+		if count := h.estimate(k.hash); isHot(count, total) {
+			keys = append(keys, HotKey{k.key, int64(count) * hotKeySampling})
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Hits > keys[j].Hits
+	})
+	return keys
+}
+
+func isHot(count int32, total int64) bool {
+	return count >= hotKeyMinCount && int64(count)*hotKeySketchWidth >= hotKeyRatio*total
+}
+
+func (this *hotKeyDetector) tracked() []*hotKey {
+	if p := atomic.LoadPointer(&this.hot); p != nil {
+		return *(*[]*hotKey)(p)
+	}
+	return nil
+}
+
+func (this *hotKeyDetector) find(key interface{}, hash uint32) *hotKey {
+	for _, k := range this.tracked() {
+		if k.hash == hash && equals(k.key, key) {
+			return k
+		}
+	}
+	return nil
+}
+
+func sketchIndex(hash uint32, row int) uint32 {
+	return (hash * hotKeySeeds[row]) >> (32 - hotKeySketchBits)
+}
+
+func (this *hotKeyDetector) estimate(hash uint32) int32 {
+	count := int32(math.MaxInt32)
+	for i := range this.sketch {
+		if c := atomic.LoadInt32(&this.sketch[i][sketchIndex(hash, i)]); c < count {
+			count = c
+		}
+	}
+	return count
+}
+
+/**
+ * Counts a Get of key and returns the value from the replicas if key is a hot key.
+ *
+ * @return ok is false if the value must be read from the segment
+ */
+func (this *hotKeyDetector) get(seg *Segment, key interface{}, hash uint32) (value interface{}, ok bool) {
+	if rand.Intn(hotKeySampling) == 0 {
+		this.count(key, hash)
+	}
+	if this.replicas == 0 {
+		return nil, false
+	}
+
+	k := this.find(key, hash)
+	if k == nil {
+		return nil, false
+	}
+	if rv := (*replicaValue)(atomic.LoadPointer(&k.slots[rand.Intn(len(k.slots))].value)); rv != nil {
+		if rv.expireAt == 0 || rv.expireAt > time.Now().UnixNano() {
+			return rv.value, true
+		}
+		return nil, false
+	}
+	return k.fill(seg)
+}
+
+/**
+ * Fills the replicas by the current value of key, nothing is done if the segment is locked
+ * by others, so the readers never wait for the writers.
+ *
+ * @return ok is false if the replicas aren't filled
+ */
+func (this *hotKey) fill(seg *Segment) (value interface{}, ok bool) {
+	if !seg.lock.TryLock() {
+		return nil, false
+	}
+	defer seg.lock.Unlock()
+
+	//the absence of key is replicated too
+	rv := new(replicaValue)
+	if e := seg.findUnderLock(this.key, this.hash); e != nil {
+		rv.value, rv.expireAt = e.fastValue(), e.expireAt
+		seg.touch(e)
+	}
+	for i := range this.slots {
+		atomic.StorePointer(&this.slots[i].value, unsafe.Pointer(rv))
+	}
+	return rv.value, true
+}
+
+func (this *hotKey) invalidate() {
+	for i := range this.slots {
+		atomic.StorePointer(&this.slots[i].value, nil)
+	}
+}
+
+func (this *hotKeyDetector) count(key interface{}, hash uint32) {
+	count := int32(math.MaxInt32)
+	for i := range this.sketch {
+		if c := atomic.AddInt32(&this.sketch[i][sketchIndex(hash, i)], 1); c < count {
+			count = c
+		}
+	}
+
+	total := atomic.AddInt64(&this.total, 1)
+	if total == 2*hotKeyWindow {
+		//only one goroutine gets this total, it halves the counters
+		this.age()
+	} else if isHot(count, total) && this.find(key, hash) == nil {
+		this.promote(key, hash, count)
+	}
+}
+
+//promote tracks key as a hot key, it replaces the coldest tracked key if the limit is reached
+func (this *hotKeyDetector) promote(key interface{}, hash uint32, count int32) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.find(key, hash) != nil {
+		return
+	}
+
+	old := this.tracked()
+	hot := make([]*hotKey, 0, len(old)+1)
+	coldest, coldestCount := -1, count
+	for i, k := range old {
+		if c := this.estimate(k.hash); c < coldestCount {
+			coldest, coldestCount = i, c
+		}
+		hot = append(hot, k)
+	}
+	if len(hot) >= this.limit {
+		if coldest < 0 {
+			return
+		}
+		hot = append(hot[:coldest], hot[coldest+1:]...)
+	}
+
+	k := &hotKey{key: key, hash: hash}
+	if this.replicas > 0 {
+		k.slots = make([]replicaSlot, this.replicas)
+	}
+	hot = append(hot, k)
+	atomic.StorePointer(&this.hot, unsafe.Pointer(&hot))
+}
+
+//age halves the counters of sketch and stops tracking the keys that aren't hot any more
+func (this *hotKeyDetector) age() {
+	for i := range this.sketch {
+		for j := range this.sketch[i] {
+			c := &this.sketch[i][j]
+			atomic.StoreInt32(c, atomic.LoadInt32(c)/2)
+		}
+	}
+	total := atomic.AddInt64(&this.total, -hotKeyWindow)
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	old := this.tracked()
+	hot := make([]*hotKey, 0, len(old))
+	for _, k := range old {
+		if isHot(this.estimate(k.hash), total) {
+			hot = append(hot, k)
+		}
+	}
+	atomic.StorePointer(&this.hot, unsafe.Pointer(&hot))
+}
+
+func (this *hotKeyDetector) onMutation(key interface{}, hash uint32, oldVal interface{}, newVal interface{}) {
+	if this.replicas > 0 {
+		this.invalidate(key, hash)
+	}
+}
+
+/**
+ * Invalidates the replicas of key, it must be called while holding the lock of segment
+ * whenever the value or expiration time of key is changed.
+ */
+func (this *hotKeyDetector) invalidate(key interface{}, hash uint32) {
+	if k := this.find(key, hash); k != nil {
+		k.invalidate()
+	}
+}
+
+//invalidateAll invalidates the replicas of all hot keys
+func (this *hotKeyDetector) invalidateAll() {
+	for _, k := range this.tracked() {
+		k.invalidate()
+	}
+}
@@ -0,0 +1,86 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestHotKeys(t *testing.T) {
+	cm := NewConcurrentMap(WithHotKeyDetection(2))
+	if keys := NewConcurrentMap().HotKeys(); keys != nil {
+		t.Errorf("HotKeys without detection, return %v, want nil", keys)
+	}
+	for i := 0; i < 1000; i++ {
+		cm.Put(i, i)
+	}
+	for n := 0; n < 10; n++ {
+		for i := 0; i < 1000; i++ {
+			cm.Get(i)
+		}
+	}
+	for n := 0; n < 100000; n++ {
+		cm.Get(7)
+	}
+
+	keys := cm.HotKeys()
+	if len(keys) != 1 || keys[0].Key != 7 {
+		t.Errorf("HotKeys, return %v, want only key 7", keys)
+	} else if keys[0].Hits < 50000 || keys[0].Hits > 150000 {
+		t.Errorf("HotKeys, return %v hits, want about 100000", keys[0].Hits)
+	}
+}
+
+func TestHotKeyReplication(t *testing.T) {
+	cm := NewConcurrentMap(WithHotKeyReplication(4))
+	cm.Put("hot", 0)
+	for n := 0; n < 10000; n++ {
+		cm.Get("hot")
+	}
+	if keys := cm.HotKeys(); len(keys) != 1 || keys[0].Key != "hot" {
+		t.Errorf("HotKeys, return %v, want only key hot", keys)
+	}
+
+	for i := 1; i < 100; i++ {
+		cm.Put("hot", i)
+		if v, _ := cm.Get("hot"); v != i {
+			t.Errorf("Get hot key after Put, return %v, want %v", v, i)
+		}
+	}
+	cm.Remove("hot")
+	if v, _ := cm.Get("hot"); v != nil {
+		t.Errorf("Get hot key after Remove, return %v, want nil", v)
+	}
+	cm.Put("hot", 1)
+	cm.Clear()
+	if v, _ := cm.Get("hot"); v != nil {
+		t.Errorf("Get hot key after Clear, return %v, want nil", v)
+	}
+
+	//the readers never see the value older than the last Put that has returned
+	cm.Put("hot", 0)
+	var written int64
+	lock := new(sync.RWMutex)
+	wg := new(sync.WaitGroup)
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 10000; n++ {
+				lock.RLock()
+				min := written
+				lock.RUnlock()
+				if v, _ := cm.Get("hot"); int64(v.(int)) < min {
+					t.Errorf("Get hot key concurrently, return %v, want >= %v", v, min)
+					return
+				}
+			}
+		}()
+	}
+	for i := 1; i <= 1000; i++ {
+		cm.Put("hot", i)
+		lock.Lock()
+		written = int64(i)
+		lock.Unlock()
+	}
+	wg.Wait()
+}
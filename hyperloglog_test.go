@@ -0,0 +1,41 @@
+package concurrent
+
+import (
+	"math"
+	"testing"
+)
+
+func TestApproxDistinctValues(t *testing.T) {
+	cm := NewConcurrentMap()
+	if _, err := cm.ApproxDistinctValues(); err == nil {
+		t.Errorf("ApproxDistinctValues without WithDistinctValueCounting, return nil, want error")
+	}
+
+	cm = NewConcurrentMap(WithDistinctValueCounting())
+	n := 100000
+	for i := 0; i < n; i++ {
+		//n/10 distinct values
+		cm.Put(i, i%(n/10))
+	}
+	checkApprox := func(want int) {
+		got, err := cm.ApproxDistinctValues()
+		if err != nil || math.Abs(float64(got)-float64(want)) > 0.1*float64(want) {
+			t.Errorf("ApproxDistinctValues, return %v, %v, want about %v, nil", got, err, want)
+		}
+	}
+	checkApprox(n / 10)
+
+	//removes half of the distinct values
+	for i := 0; i < n; i++ {
+		if i%(n/10) < n/20 {
+			cm.Remove(i)
+		}
+	}
+	checkApprox(n / 20)
+
+	cm.Replace(n-1, "x")
+	cm.Clear()
+	if got, _ := cm.ApproxDistinctValues(); got != 0 {
+		t.Errorf("ApproxDistinctValues after Clear, return %v, want 0", got)
+	}
+}
@@ -0,0 +1,46 @@
+//go:build go1.23
+
+package concurrent
+
+import (
+	"iter"
+)
+
+/**
+ * Returns an iterator over all mappings for the range-over-func loop, e.g.
+ *
+ *	for k, v := range cm.All() {
+ *	}
+ *
+ * It walks the segments like Range, so it is weakly consistent like MapIterator,
+ * and breaking the loop stops the walk.
+ */
+func (this *ConcurrentMap) All() iter.Seq2[interface{}, interface{}] {
+	return func(yield func(key interface{}, value interface{}) bool) {
+		this.Range(yield)
+	}
+}
+
+/**
+ * Returns an iterator over all keys for the range-over-func loop, see All.
+ * Keys returns the keys as a slice instead.
+ */
+func (this *ConcurrentMap) KeysSeq() iter.Seq[interface{}] {
+	return func(yield func(key interface{}) bool) {
+		this.Range(func(key interface{}, value interface{}) bool {
+			return yield(key)
+		})
+	}
+}
+
+/**
+ * Returns an iterator over all values for the range-over-func loop, see All.
+ * Values returns the values as a slice instead.
+ */
+func (this *ConcurrentMap) ValuesSeq() iter.Seq[interface{}] {
+	return func(yield func(value interface{}) bool) {
+		this.Range(func(key interface{}, value interface{}) bool {
+			return yield(value)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+//go:build go1.23
+
+package concurrent
+
+import (
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	cm := NewConcurrentMap()
+	for i := 0; i < 100; i++ {
+		cm.Put(i, i*10)
+	}
+
+	n := 0
+	for k, v := range cm.All() {
+		if v != k.(int)*10 {
+			t.Errorf("All, yield %v, %v, want %v", k, v, k.(int)*10)
+		}
+		n++
+	}
+	if n != 100 {
+		t.Errorf("All, yield %v mappings, want 100", n)
+	}
+
+	n = 0
+	for range cm.All() {
+		if n++; n == 10 {
+			break
+		}
+	}
+	if n != 10 {
+		t.Errorf("All with break, yield %v mappings, want 10", n)
+	}
+
+	keys, values := 0, 0
+	for k := range cm.KeysSeq() {
+		keys += k.(int)
+	}
+	for v := range cm.ValuesSeq() {
+		values += v.(int)
+	}
+	if keys != 4950 || values != 49500 {
+		t.Errorf("KeysSeq and ValuesSeq, yield sums %v, %v, want 4950, 49500", keys, values)
+	}
+}
@@ -0,0 +1,65 @@
+package concurrent
+
+import (
+	"math/rand"
+)
+
+/**
+ * IterationOrder is the order that MapIterator visits the mappings.
+ */
+type IterationOrder int
+
+const (
+	/**
+	 * The segments are visited from the last one to the first one, the buckets of every segment
+	 * are visited from the last one to the first one, and the entries of a bucket are visited
+	 * from the newest one. So the order is reproducible for the same keys that were put
+	 * in the same order into the maps with the same capacity and concurrency level,
+	 * e.g. for exports that are compared by diff. It is the default order.
+	 */
+	ITER_ORDER_STABLE IterationOrder = iota
+	/**
+	 * Every iterator visits the segments and buckets in a different random order,
+	 * so the callers cannot rely on the order by accident.
+	 */
+	ITER_ORDER_RANDOM
+)
+
+/**
+ * Returns an Option that sets the order of Iterator, and of ToSlice that uses it.
+ */
+func WithIterationOrder(order IterationOrder) Option {
+	if order != ITER_ORDER_STABLE && order != ITER_ORDER_RANDOM {
+		panic(IllegalArgError)
+	}
+	return func(m *ConcurrentMap) {
+		m.iterationOrder = order
+	}
+}
+
+/**
+ * Returns an iterator in the specified order, regardless of WithIterationOrder.
+ */
+func (this *ConcurrentMap) IteratorWithOrder(order IterationOrder) *MapIterator {
+	if order != ITER_ORDER_STABLE && order != ITER_ORDER_RANDOM {
+		panic(IllegalArgError)
+	}
+	return newMapIterator(this, order)
+}
+
+//randomPermutation returns the offset and the odd stride of a random permutation, see permute
+func randomPermutation() (offset, stride uint32) {
+	return rand.Uint32(), rand.Uint32() | 1
+}
+
+/**
+ * Returns the index that the iterator visits at step i of n, n must be power of 2.
+ * The odd stride is coprime with n, so i*stride+offset mod n is a permutation of [0, n),
+ * it is the identity if the order is stable.
+ */
+func (this *MapIterator) permute(i int, n int) int {
+	if this.stride == 0 {
+		return i
+	}
+	return int((uint32(i)*this.stride + this.offset) & uint32(n-1))
+}
@@ -0,0 +1,53 @@
+package concurrent
+
+import (
+	"reflect"
+	"testing"
+)
+
+func iterationKeys(itr *MapIterator) []interface{} {
+	keys := make([]interface{}, 0)
+	for itr.HasNext() {
+		k, _, _ := itr.Next()
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func TestIterationOrder(t *testing.T) {
+	stable1, stable2 := NewConcurrentMap(), NewConcurrentMap()
+	random := NewConcurrentMap(WithIterationOrder(ITER_ORDER_RANDOM))
+	for i := 0; i < 1000; i++ {
+		stable1.Put(i, i)
+		stable2.Put(i, i)
+		random.Put(i, i)
+	}
+
+	want := iterationKeys(stable1.Iterator())
+	if got := iterationKeys(stable2.Iterator()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Iterator of ITER_ORDER_STABLE, return %v, want %v", got, want)
+	}
+
+	//every key is visited once in the random order
+	differs := false
+	for n := 0; n < 5; n++ {
+		got := iterationKeys(random.Iterator())
+		seen := make(map[interface{}]bool)
+		for _, k := range got {
+			seen[k] = true
+		}
+		if len(got) != 1000 || len(seen) != 1000 {
+			t.Errorf("Iterator of ITER_ORDER_RANDOM, return %v keys, %v distinct, want 1000", len(got), len(seen))
+		}
+		if !reflect.DeepEqual(got, want) {
+			differs = true
+		}
+	}
+	if !differs {
+		t.Errorf("Iterator of ITER_ORDER_RANDOM, always return the stable order")
+	}
+
+	if got := iterationKeys(random.IteratorWithOrder(ITER_ORDER_STABLE)); !reflect.DeepEqual(got, want) {
+		t.Errorf("IteratorWithOrder(ITER_ORDER_STABLE), return %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,36 @@
+package concurrent
+
+import (
+	"math/rand"
+	"time"
+)
+
+/**
+ * Returns an Option that randomizes the TTL of PutWithTTL by ± fraction of it,
+ * e.g. 0.1 means the TTL is changed to a random duration between 90% and 110% of it.
+ * So a cohort of mappings written together doesn't expire in the same instant
+ * and thunder the backing store. ExpireAt is not randomized, because its time is absolute.
+ *
+ * @param fraction must be in [0, 1)
+ */
+func WithTTLJitter(fraction float64) Option {
+	if !(fraction >= 0 && fraction < 1) {
+		panic(IllegalArgError)
+	}
+	return func(m *ConcurrentMap) {
+		m.ttlJitter = fraction
+	}
+}
+
+//jitter returns the ttl that is randomized by ttlJitter
+func (this *ConcurrentMap) jitter(ttl time.Duration) time.Duration {
+	if this.ttlJitter == 0 || ttl <= 0 {
+		return ttl
+	}
+	d := time.Duration(float64(ttl) * this.ttlJitter * (2*rand.Float64() - 1))
+	if ttl+d <= 0 {
+		//a positive TTL never becomes the TTL that means never expire
+		return 1
+	}
+	return ttl + d
+}
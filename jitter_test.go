@@ -0,0 +1,38 @@
+package concurrent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithTTLJitter(t *testing.T) {
+	cm := NewConcurrentMap(WithTTLJitter(0.5))
+	defer cm.Close()
+	min, max := time.Duration(1<<62), time.Duration(0)
+	for i := 0; i < 100; i++ {
+		cm.PutWithTTL(i, i, time.Hour)
+		ttl, _, _ := cm.TTL(i)
+		if ttl < min {
+			min = ttl
+		}
+		if ttl > max {
+			max = ttl
+		}
+	}
+	if min < 30*time.Minute-time.Second || max > 90*time.Minute {
+		t.Errorf("TTL with jitter, return [%v, %v], want in [30m, 90m]", min, max)
+	}
+	if max-min < 10*time.Minute {
+		t.Errorf("TTL with jitter, return [%v, %v], want randomized", min, max)
+	}
+
+	if d := NewConcurrentMap().jitter(time.Hour); d != time.Hour {
+		t.Errorf("TTL without jitter, return %v, want 1h", d)
+	}
+	defer func() {
+		if r := recover(); r != IllegalArgError {
+			t.Errorf("WithTTLJitter(1), panic %v, want IllegalArgError", r)
+		}
+	}()
+	WithTTLJitter(1)
+}
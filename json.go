@@ -0,0 +1,135 @@
+package concurrent
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+//jsonKeys configures how MarshalJSON and UnmarshalJSON convert the keys, see WithJSONKeys
+type jsonKeys struct {
+	useStringer bool
+	decode      func(text string) (key interface{}, err error)
+}
+
+/**
+ * Returns an Option that configures how the keys are converted to and from the names of JSON object.
+ *
+ * @param useStringer if true, the keys that implement fmt.Stringer but not encoding.TextMarshaler
+ *                    are encoded by String, otherwise MarshalJSON returns IllegalArgError for them
+ * @param decode converts the name back to key in UnmarshalJSON, e.g. by the UnmarshalText of key type,
+ *               if it is nil, the keys are kept as string
+ */
+func WithJSONKeys(useStringer bool, decode func(text string) (key interface{}, err error)) Option {
+	return func(m *ConcurrentMap) {
+		m.jsonKeys = jsonKeys{useStringer, decode}
+	}
+}
+
+//encodeKey returns the name of key in JSON object
+func (this *jsonKeys) encodeKey(key interface{}) (string, error) {
+	switch k := key.(type) {
+	case string:
+		return k, nil
+	case encoding.TextMarshaler:
+		text, err := k.MarshalText()
+		return string(text), err
+	case int:
+		return strconv.FormatInt(int64(k), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(k), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(k), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(k), 10), nil
+	case int64:
+		return strconv.FormatInt(k, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(k), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(k), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(k), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(k), 10), nil
+	case uint64:
+		return strconv.FormatUint(k, 10), nil
+	case uintptr:
+		return strconv.FormatUint(uint64(k), 10), nil
+	}
+	if s, ok := key.(fmt.Stringer); ok && this.useStringer {
+		return s.String(), nil
+	}
+	return "", IllegalArgError
+}
+
+/**
+ * Encodes the mappings as a JSON object, implements json.Marshaler.
+ * The keys are converted to the names of object in the order: string, encoding.TextMarshaler,
+ * integer, and fmt.Stringer if it's enabled by WithJSONKeys. The values are encoded by json.Marshal.
+ * The result is weakly consistent like the Iterator.
+ *
+ * @return IllegalArgError if any key cannot be converted, or two keys have the same name
+ */
+func (this *ConcurrentMap) MarshalJSON() ([]byte, error) {
+	obj := make(map[string]interface{}, this.Size())
+	var err error
+	for _, seg := range this.segments {
+		seg.walk(func(e *Entry) {
+			if err != nil {
+				return
+			}
+			var name string
+			if name, err = this.jsonKeys.encodeKey(e.key); err != nil {
+				return
+			}
+			if _, ok := obj[name]; ok {
+				err = IllegalArgError
+				return
+			}
+			obj[name] = this.decode(e.Value())
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(obj)
+}
+
+/**
+ * Puts the mappings of a JSON object, implements json.Unmarshaler.
+ * The names of object are converted to the keys by the decoder of WithJSONKeys,
+ * and the values are decoded like json.Unmarshal into interface{}, e.g. a number is float64.
+ * The null values are skipped.
+ *
+ * The target map must be created by NewConcurrentMap, e.g. the field of a struct must be set
+ * before calling json.Unmarshal, the zero value of ConcurrentMap can't store the mappings.
+ *
+ * @return IllegalStateError if the map isn't created by NewConcurrentMap
+ */
+func (this *ConcurrentMap) UnmarshalJSON(data []byte) error {
+	if this.segments == nil {
+		return IllegalStateError
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+
+	kvs := make(map[interface{}]interface{}, len(obj))
+	for name, v := range obj {
+		if v == nil {
+			continue
+		}
+		var key interface{} = name
+		if decode := this.jsonKeys.decode; decode != nil {
+			var err error
+			if key, err = decode(name); err != nil {
+				return err
+			}
+		}
+		kvs[key] = v
+	}
+	return this.PutAll(kvs)
+}
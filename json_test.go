@@ -0,0 +1,77 @@
+package concurrent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type stringerKey struct {
+	id int
+}
+
+func (this stringerKey) String() string {
+	return fmt.Sprintf("s%v", this.id)
+}
+
+type textKey struct {
+	tenant string
+	id     int
+}
+
+func (this textKey) MarshalText() ([]byte, error) {
+	return []byte(this.tenant + "/" + strconv.Itoa(this.id)), nil
+}
+
+func parseTextKey(text string) (interface{}, error) {
+	i := strings.IndexByte(text, '/')
+	id, err := strconv.Atoi(text[i+1:])
+	return textKey{text[:i], id}, err
+}
+
+func TestMarshalJSON(t *testing.T) {
+	cm := NewConcurrentMap()
+	cm.Put(textKey{"a", 1}, "a")
+	cm.Put(textKey{"b", 2}, 2)
+	data, err := json.Marshal(cm)
+	if s := string(data); err != nil || s != `{"a/1":"a","b/2":2}` {
+		t.Errorf("Marshal TextMarshaler keys, return %v, %v", s, err)
+	}
+
+	restored := NewConcurrentMap(WithJSONKeys(false, parseTextKey))
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Errorf("Unmarshal, return error %v", err)
+	}
+	if v, _ := restored.Get(textKey{"b", 2}); v != 2.0 || restored.Size() != 2 {
+		t.Errorf("Get after Unmarshal, return %v, want 2", v)
+	}
+
+	cm = NewConcurrentMap()
+	cm.Put(stringerKey{1}, 1)
+	if _, err := json.Marshal(cm); err == nil || !strings.Contains(err.Error(), IllegalArgError.Error()) {
+		t.Errorf("Marshal Stringer keys without WithJSONKeys, return %v, want IllegalArgError", err)
+	}
+	cm = NewConcurrentMap(WithJSONKeys(true, nil))
+	cm.Put(stringerKey{1}, 1)
+	cm.Put(int64(2), 2)
+	if data, err := json.Marshal(cm); string(data) != `{"2":2,"s1":1}` || err != nil {
+		t.Errorf("Marshal Stringer and integer keys, return %v, %v", string(data), err)
+	}
+
+	//the keys are kept as string without decoder
+	restored = NewConcurrentMap()
+	if err := restored.UnmarshalJSON([]byte(`{"1":"a","2":null}`)); err != nil {
+		t.Errorf("UnmarshalJSON, return error %v", err)
+	}
+	if v, _ := restored.Get("1"); v != "a" || restored.Size() != 1 {
+		t.Errorf("Get after UnmarshalJSON, return %v, want a", v)
+	}
+
+	//the zero value of map allocated by json.Unmarshal can't store the mappings
+	var obj struct{ M *ConcurrentMap }
+	if err := json.Unmarshal([]byte(`{"M":{"1":"a"}}`), &obj); err != IllegalStateError {
+		t.Errorf("Unmarshal into zero value map, return %v, want IllegalStateError", err)
+	}
+}
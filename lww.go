@@ -0,0 +1,191 @@
+package concurrent
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+/**
+ * LWWRecord is the state of a key in LWWMap, it is immutable after created,
+ * so the records can be shared by maps and be sent to other nodes by gossip.
+ */
+type LWWRecord struct {
+	Key   interface{}
+	Value interface{}
+	//the hybrid logical time in nanoseconds of the write
+	Timestamp int64
+	//the actor that wrote the record
+	Actor uint64
+	//true if the key was removed, the record is kept as a tombstone so the removal is merged too
+	Deleted bool
+}
+
+/**
+ * Returns true if this record wins over other, i.e. it has greater timestamp,
+ * or greater actor if the timestamps are same.
+ */
+func (this *LWWRecord) newerThan(other *LWWRecord) bool {
+	if this.Timestamp != other.Timestamp {
+		return this.Timestamp > other.Timestamp
+	}
+	return this.Actor > other.Actor
+}
+
+/**
+ * LWWMap is a last-writer-wins map, a state-based CRDT for multi-node caches that are
+ * synchronized via gossip. Every write is stamped with the hybrid logical time and
+ * the actor of this node, and the conflicts are resolved by the greater stamp,
+ * so all nodes converge to the same state after they merge the records of each other
+ * in any order.
+ *
+ * The removals are kept as tombstones until PurgeTombstones, the tombstones must be kept
+ * long enough for all nodes to merge them, otherwise the removed mappings may be revived.
+ */
+type LWWMap struct {
+	clock int64 //atomic, the last timestamp of this node
+	actor uint64
+	m     *ConcurrentMap
+}
+
+/**
+ * Creates a LWWMap for the specified actor, the actor must be unique among the nodes.
+ * The options are applied to the underlying ConcurrentMap.
+ */
+func NewLWWMap(actor uint64, opts ...Option) *LWWMap {
+	paras := make([]interface{}, len(opts))
+	for i, opt := range opts {
+		paras[i] = opt
+	}
+	return &LWWMap{actor: actor, m: NewConcurrentMap(paras...)}
+}
+
+//now returns a timestamp that is greater than all timestamps written or merged by this node
+func (this *LWWMap) now() int64 {
+	for {
+		last := atomic.LoadInt64(&this.clock)
+		ts := time.Now().UnixNano()
+		if ts <= last {
+			ts = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&this.clock, last, ts) {
+			return ts
+		}
+	}
+}
+
+//observe advances the clock to the merged timestamp, so the later local writes win over it
+func (this *LWWMap) observe(ts int64) {
+	for {
+		last := atomic.LoadInt64(&this.clock)
+		if ts <= last || atomic.CompareAndSwapInt64(&this.clock, last, ts) {
+			return
+		}
+	}
+}
+
+//merge stores the record if it wins over the current record of its key
+func (this *LWWMap) merge(r *LWWRecord) (err error) {
+	_, err = this.m.Update(r.Key, func(oldVal interface{}) interface{} {
+		if oldVal == nil || r.newerThan(oldVal.(*LWWRecord)) {
+			return r
+		}
+		return oldVal
+	})
+	return
+}
+
+/**
+ * Returns the value of key, or nil if no mapping for key or the key was removed.
+ */
+func (this *LWWMap) Get(key interface{}) (value interface{}, err error) {
+	v, err := this.m.Get(key)
+	if r, ok := v.(*LWWRecord); ok && !r.Deleted {
+		value = r.Value
+	}
+	return
+}
+
+/**
+ * Maps key to value with a new timestamp of this node.
+ */
+func (this *LWWMap) Put(key interface{}, value interface{}) (err error) {
+	if isNil(value) {
+		return NilValueError
+	}
+	return this.merge(&LWWRecord{Key: key, Value: value, Timestamp: this.now(), Actor: this.actor})
+}
+
+/**
+ * Removes the mapping for key by writing a tombstone with a new timestamp of this node.
+ */
+func (this *LWWMap) Remove(key interface{}) (err error) {
+	return this.merge(&LWWRecord{Key: key, Timestamp: this.now(), Actor: this.actor, Deleted: true})
+}
+
+/**
+ * Returns the records of all keys, includes the tombstones, they can be sent to other nodes
+ * and be merged by Merge. The result is weakly consistent like the Iterator.
+ */
+func (this *LWWMap) Records() []*LWWRecord {
+	entries := this.m.ToSlice()
+	records := make([]*LWWRecord, len(entries))
+	for i, e := range entries {
+		records[i] = e.Value().(*LWWRecord)
+	}
+	return records
+}
+
+/**
+ * Merges the records received from other nodes, the record of a key wins
+ * if it has greater timestamp, or greater actor if the timestamps are same.
+ * The merge is commutative, associative and idempotent.
+ */
+func (this *LWWMap) Merge(records []*LWWRecord) (err error) {
+	for _, r := range records {
+		if isNil(r.Key) || (!r.Deleted && isNil(r.Value)) {
+			return IllegalArgError
+		}
+		this.observe(r.Timestamp)
+		if err = this.merge(r); err != nil {
+			return
+		}
+	}
+	return
+}
+
+/**
+ * Merges the records of other map, see Merge.
+ */
+func (this *LWWMap) MergeFrom(other *LWWMap) error {
+	return this.Merge(other.Records())
+}
+
+/**
+ * Removes the tombstones that are older than the specified duration.
+ *
+ * @return the number of removed tombstones
+ */
+func (this *LWWMap) PurgeTombstones(olderThan time.Duration) (n int) {
+	deadline := time.Now().Add(-olderThan).UnixNano()
+	for _, r := range this.Records() {
+		if r.Deleted && r.Timestamp < deadline {
+			//the tombstone is kept if it was overwritten after listed
+			if ok, _ := this.m.RemoveEntry(r.Key, r); ok {
+				n++
+			}
+		}
+	}
+	return
+}
+
+/**
+ * Returns the number of mappings that aren't removed, it scans the map.
+ */
+func (this *LWWMap) Size() (n int) {
+	for _, r := range this.Records() {
+		if !r.Deleted {
+			n++
+		}
+	}
+	return
+}
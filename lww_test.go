@@ -0,0 +1,70 @@
+package concurrent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLWWMap(t *testing.T) {
+	a, b, c := NewLWWMap(1), NewLWWMap(2), NewLWWMap(3)
+	a.Put("k", "a")
+	b.Put("k", "b")
+	b.Put("only-b", 1)
+	c.Put("removed", 1)
+	c.Remove("removed")
+	a.Put("removed", "old")
+
+	//the nodes merge each other in different orders
+	a.MergeFrom(b)
+	a.MergeFrom(c)
+	c.MergeFrom(b)
+	c.MergeFrom(a)
+	b.MergeFrom(c)
+	b.MergeFrom(b)
+
+	for i, m := range []*LWWMap{a, b, c} {
+		if v, _ := m.Get("k"); v != "b" {
+			t.Errorf("Get from node %v after merge, return %v, want b", i, v)
+		}
+		if v, _ := m.Get("removed"); v != "old" {
+			t.Errorf("Get overwritten tombstone from node %v, return %v, want old", i, v)
+		}
+		if m.Size() != 3 {
+			t.Errorf("Size of node %v after merge, return %v, want 3", i, m.Size())
+		}
+	}
+
+	//the local write after merge wins over the merged records, even if the clocks skew
+	future := &LWWRecord{Key: "skew", Value: "future", Timestamp: time.Now().Add(time.Hour).UnixNano(), Actor: 9}
+	a.Merge([]*LWWRecord{future})
+	a.Put("skew", "local")
+	if v, _ := a.Get("skew"); v != "local" {
+		t.Errorf("Get after merging future record and Put, return %v, want local", v)
+	}
+
+	//the same timestamp is resolved by actor
+	r1 := &LWWRecord{Key: "tie", Value: "1", Timestamp: 1, Actor: 1}
+	r2 := &LWWRecord{Key: "tie", Value: "2", Timestamp: 1, Actor: 2}
+	b.Merge([]*LWWRecord{r2, r1})
+	c.Merge([]*LWWRecord{r1, r2})
+	if vb, _ := b.Get("tie"); vb != "2" {
+		t.Errorf("Get tie from node b, return %v, want 2", vb)
+	}
+	if vc, _ := c.Get("tie"); vc != "2" {
+		t.Errorf("Get tie from node c, return %v, want 2", vc)
+	}
+
+	b.Remove("k")
+	if v, _ := b.Get("k"); v != nil || b.Size() != 3 {
+		t.Errorf("Get after Remove, return %v, want nil", v)
+	}
+	if n := b.PurgeTombstones(time.Hour); n != 0 {
+		t.Errorf("PurgeTombstones of new tombstones, return %v, want 0", n)
+	}
+	if n := b.PurgeTombstones(-time.Hour); n != 1 {
+		t.Errorf("PurgeTombstones, return %v, want 1", n)
+	}
+	if err := b.Merge([]*LWWRecord{{Key: "nil"}}); err != IllegalArgError {
+		t.Errorf("Merge record without value, return %v, want IllegalArgError", err)
+	}
+}
@@ -0,0 +1,28 @@
+package concurrent
+
+/**
+ * Map is the common interface of the concurrent map implementations in this package,
+ * so libraries can accept any implementation, and users can choose the
+ * implementation by configuration.
+ *
+ * All methods must be safe for concurrent use by multiple goroutines,
+ * nil cannot be used as key or value.
+ */
+type Map interface {
+	Get(key interface{}) (value interface{}, err error)
+	ContainsKey(key interface{}) (found bool, err error)
+	Put(key interface{}, value interface{}) (oldVal interface{}, err error)
+	PutIfAbsent(key interface{}, value interface{}) (oldVal interface{}, err error)
+	PutAll(m map[interface{}]interface{}) (err error)
+	Update(key interface{}, action func(oldVal interface{}) (newVal interface{})) (oldVal interface{}, err error)
+	Remove(key interface{}) (oldVal interface{}, err error)
+	RemoveEntry(key interface{}, value interface{}) (ok bool, err error)
+	Replace(key interface{}, value interface{}) (oldVal interface{}, err error)
+	CompareAndReplace(key interface{}, oldVal interface{}, newVal interface{}) (ok bool, err error)
+	Size() int32
+	IsEmpty() bool
+	Clear()
+	ToSlice() (kvs []*Entry)
+}
+
+var _ Map = (*ConcurrentMap)(nil)
@@ -1100,3 +1100,431 @@ func TestConcurrent(t *testing.T) {
 //	t.Log("9.", s)
 
 //}
+
+func TestForEachSegmentLocked(t *testing.T) {
+	cm := NewConcurrentMap()
+	n := 100
+	for i := 0; i < n; i++ {
+		cm.Put(i, i)
+	}
+
+	count, segs := 0, 0
+	cm.ForEachSegmentLocked(func(entries []Entry) {
+		segs++
+		for _, e := range entries {
+			count++
+			if e.Key() != e.Value() {
+				t.Errorf("Get entry %v, %v, want key equals value", e.Key(), e.Value())
+			}
+		}
+	})
+	if count != n || segs != DEFAULT_CONCURRENCY_LEVEL {
+		t.Errorf("ForEachSegmentLocked visit %v entries in %v segments, want %v in %v", count, segs, n, DEFAULT_CONCURRENCY_LEVEL)
+	}
+}
+
+func TestComputeAll(t *testing.T) {
+	cm := NewConcurrentMap()
+	cm.Put(1, 10)
+	cm.Put(2, 20)
+
+	err := cm.ComputeAll([]interface{}{1, 2, 3}, func(k, oldVal interface{}) interface{} {
+		switch k {
+		case 1:
+			return oldVal.(int) + 1
+		case 2:
+			return nil
+		default:
+			return 30
+		}
+	})
+	if err != nil {
+		t.Errorf("ComputeAll return %v, want nil", err)
+	}
+
+	if v, _ := cm.Get(1); v != 11 {
+		t.Errorf("Get 1 after ComputeAll, return %v, want 11", v)
+	}
+	if v, _ := cm.Get(2); v != nil {
+		t.Errorf("Get 2 after ComputeAll, return %v, want nil", v)
+	}
+	if v, _ := cm.Get(3); v != 30 {
+		t.Errorf("Get 3 after ComputeAll, return %v, want 30", v)
+	}
+	if s := cm.Size(); s != 2 {
+		t.Errorf("Get size of m after ComputeAll, return %v, want 2", s)
+	}
+
+	if err = cm.ComputeAll([]interface{}{1, nil}, func(k, oldVal interface{}) interface{} { return 1 }); err == nil {
+		t.Errorf("ComputeAll with nil key, return nil, want error")
+	}
+}
+
+func TestIteratorErrors(t *testing.T) {
+	m := NewConcurrentMap()
+	m.Put(1, 10)
+	itr := m.Iterator()
+	if err := itr.RemoveLastReturned(); err != IllegalStateError {
+		t.Errorf("RemoveLastReturned before NextEntry, return %v, want IllegalStateError", err)
+	}
+	if e, err := itr.NextEntry(); err != nil || e.Key() != 1 || e.Value() != 10 {
+		t.Errorf("NextEntry, return %v, %v, want entry of 1", e, err)
+	}
+	if e, err := itr.NextEntry(); e != nil || err != IllegalStateError {
+		t.Errorf("NextEntry after the end, return %v, %v, want nil, IllegalStateError", e, err)
+	}
+	if err := itr.RemoveLastReturned(); err != nil || m.Size() != 0 {
+		t.Errorf("RemoveLastReturned, return %v, Size is %v, want nil, 0", err, m.Size())
+	}
+	if err := itr.RemoveLastReturned(); err != IllegalStateError {
+		t.Errorf("RemoveLastReturned twice, return %v, want IllegalStateError", err)
+	}
+
+	//nextEntry still panics with the string value after the end
+	defer func() {
+		if r := recover(); r != "IllegalStateException" {
+			t.Errorf("nextEntry after the end, panic %v, want IllegalStateException", r)
+		}
+	}()
+	itr.nextEntry()
+}
+
+func TestUpdateIfPresent(t *testing.T) {
+	cm := NewConcurrentMap()
+	called := false
+	inc := func(oldVal interface{}) interface{} {
+		called = true
+		return oldVal.(int) + 1
+	}
+
+	//the absent key is not created and the action is not called
+	if old, err := cm.UpdateIfPresent(1, inc); old != nil || err != nil || called || cm.Size() != 0 {
+		t.Errorf("UpdateIfPresent absent key, return %v, %v, called %v, want nil, nil, false", old, err, called)
+	}
+
+	cm.Put(1, 10)
+	if old, err := cm.UpdateIfPresent(1, inc); old != 10 || err != nil {
+		t.Errorf("UpdateIfPresent, return %v, %v, want 10, nil", old, err)
+	}
+	if v, _ := cm.Get(1); v != 11 {
+		t.Errorf("Get after UpdateIfPresent, return %v, want 11", v)
+	}
+
+	if old, _ := cm.UpdateIfPresent(1, func(oldVal interface{}) interface{} {
+		return nil
+	}); old != 11 || cm.Size() != 0 {
+		t.Errorf("UpdateIfPresent returning nil, return %v, Size is %v, want 11, 0", old, cm.Size())
+	}
+	if _, err := cm.UpdateIfPresent(1, nil); err != NilActionError {
+		t.Errorf("UpdateIfPresent nil action, return %v, want NilActionError", err)
+	}
+}
+
+func TestGetOrDefault(t *testing.T) {
+	cm := NewConcurrentMap()
+	cm.Put(1, 10)
+	if v, err := cm.GetOrDefault(1, 0); v != 10 || err != nil {
+		t.Errorf("GetOrDefault present key, return %v, %v, want 10, nil", v, err)
+	}
+	if v, err := cm.GetOrDefault(2, -1); v != -1 || err != nil {
+		t.Errorf("GetOrDefault absent key, return %v, %v, want -1, nil", v, err)
+	}
+	if cm.Size() != 1 {
+		t.Errorf("GetOrDefault, Size is %v, want 1, the default must not be put", cm.Size())
+	}
+	if _, err := cm.GetOrDefault(nil, -1); err != NilKeyError {
+		t.Errorf("GetOrDefault nil key, return %v, want NilKeyError", err)
+	}
+}
+
+func TestGetAndDelete(t *testing.T) {
+	cm := NewConcurrentMap()
+	for i := 0; i < 1000; i++ {
+		cm.Put(i, i)
+	}
+
+	//every value is taken by exactly one goroutine
+	var taken int32
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if v, loaded, _ := cm.GetAndDelete(i); loaded {
+					if v != i {
+						t.Errorf("GetAndDelete %v, return %v, want %v", i, v, i)
+					}
+					atomic.AddInt32(&taken, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if taken != 1000 || cm.Size() != 0 {
+		t.Errorf("GetAndDelete concurrently, taken %v, Size is %v, want 1000, 0", taken, cm.Size())
+	}
+	if v, loaded, err := cm.GetAndDelete(1); v != nil || loaded || err != nil {
+		t.Errorf("GetAndDelete absent key, return %v, %v, %v, want nil, false, nil", v, loaded, err)
+	}
+	if _, _, err := cm.GetAndDelete(nil); err != NilKeyError {
+		t.Errorf("GetAndDelete nil key, return %v, want NilKeyError", err)
+	}
+}
+
+func TestSwap(t *testing.T) {
+	cm := NewConcurrentMap()
+	if prev, loaded, err := cm.Swap(1, 10); prev != nil || loaded || err != nil {
+		t.Errorf("Swap absent key, return %v, %v, %v, want nil, false, nil", prev, loaded, err)
+	}
+	if prev, loaded, err := cm.Swap(1, 20); prev != 10 || !loaded || err != nil {
+		t.Errorf("Swap present key, return %v, %v, %v, want 10, true, nil", prev, loaded, err)
+	}
+	if v, _ := cm.Get(1); v != 20 {
+		t.Errorf("Get after Swap, return %v, want 20", v)
+	}
+	if _, _, err := cm.Swap(1, nil); err != NilValueError {
+		t.Errorf("Swap nil value, return %v, want NilValueError", err)
+	}
+}
+
+func TestContainsValue(t *testing.T) {
+	cm := NewConcurrentMap()
+	for i := 0; i < 100; i++ {
+		cm.Put(i, i*10)
+	}
+	if found, err := cm.ContainsValue(990); !found || err != nil {
+		t.Errorf("ContainsValue present value, return %v, %v, want true, nil", found, err)
+	}
+	if found, _ := cm.ContainsValue(5); found {
+		t.Errorf("ContainsValue absent value, return true, want false")
+	}
+	cm.Remove(99)
+	if found, _ := cm.ContainsValue(990); found {
+		t.Errorf("ContainsValue removed value, return true, want false")
+	}
+	if _, err := cm.ContainsValue(nil); err != NilValueError {
+		t.Errorf("ContainsValue nil, return %v, want NilValueError", err)
+	}
+
+	//the value that is never removed is found while the map is being modified
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1000; i < 5000; i++ {
+			cm.Put(i, i)
+			cm.Remove(i - 1)
+		}
+	}()
+	for n := 0; n < 100; n++ {
+		if found, _ := cm.ContainsValue(0); !found {
+			t.Errorf("ContainsValue while modifying, return false, want true")
+			break
+		}
+	}
+	<-done
+}
+
+func TestKeys(t *testing.T) {
+	cm := NewConcurrentMap()
+	if keys := cm.Keys(); len(keys) != 0 {
+		t.Errorf("Keys of empty map, return %v, want empty", keys)
+	}
+	for i := 0; i < 100; i++ {
+		cm.Put(i, i*10)
+	}
+	cm.Remove(50)
+
+	keys := cm.Keys()
+	ints := make([]int, len(keys))
+	for i, k := range keys {
+		ints[i] = k.(int)
+	}
+	sort.Ints(ints)
+	if len(ints) != 99 || ints[0] != 0 || ints[49] != 49 || ints[50] != 51 || ints[98] != 99 {
+		t.Errorf("Keys, return %v, want 0-99 without 50", ints)
+	}
+}
+
+func TestValues(t *testing.T) {
+	cm := NewConcurrentMap()
+	if values := cm.Values(); len(values) != 0 {
+		t.Errorf("Values of empty map, return %v, want empty", values)
+	}
+	for i := 0; i < 100; i++ {
+		cm.Put(i, i*10)
+	}
+	cm.Remove(50)
+
+	values := cm.Values()
+	ints := make([]int, len(values))
+	for i, v := range values {
+		ints[i] = v.(int)
+	}
+	sort.Ints(ints)
+	if len(ints) != 99 || ints[0] != 0 || ints[49] != 490 || ints[50] != 510 || ints[98] != 990 {
+		t.Errorf("Values, return %v, want 0-990 without 500", ints)
+	}
+}
+
+func TestToMap(t *testing.T) {
+	cm := NewConcurrentMap()
+	if m := cm.ToMap(); m == nil || len(m) != 0 {
+		t.Errorf("ToMap of empty map, return %v, want empty map", m)
+	}
+	for i := 0; i < 100; i++ {
+		cm.Put(i, i*10)
+	}
+	cm.Remove(50)
+
+	m := cm.ToMap()
+	if len(m) != 99 {
+		t.Errorf("ToMap, return %v mappings, want 99", len(m))
+	}
+	for i := 0; i < 100; i++ {
+		if v, ok := m[i]; i == 50 && ok {
+			t.Errorf("ToMap, return %v for removed key 50, want no mapping", v)
+		} else if i != 50 && v != i*10 {
+			t.Errorf("ToMap, return %v for key %v, want %v", v, i, i*10)
+		}
+	}
+
+	//the result is a copy
+	m[1000] = 1
+	if v, _ := cm.Get(1000); v != nil {
+		t.Errorf("Get after the result of ToMap is changed, return %v, want nil", v)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	cm := NewConcurrentMap()
+	cm.ForEach(func(key interface{}, value interface{}) {
+		t.Errorf("ForEach of empty map, call fn with %v, %v, want no call", key, value)
+	})
+	for i := 0; i < 100; i++ {
+		cm.Put(i, i*10)
+	}
+	cm.Remove(50)
+	cm.PutWithTTL(200, 2000, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	seen := make(map[interface{}]interface{})
+	cm.ForEach(func(key interface{}, value interface{}) {
+		if _, ok := seen[key]; ok {
+			t.Errorf("ForEach, call fn with key %v twice, want once", key)
+		}
+		seen[key] = value
+	})
+	if len(seen) != 99 {
+		t.Errorf("ForEach, call fn %v times, want 99", len(seen))
+	}
+	for k, v := range seen {
+		if k == 50 || k == 200 || v != k.(int)*10 {
+			t.Errorf("ForEach, call fn with %v, %v, want 0-99 without 50", k, v)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	cm := NewConcurrentMap()
+	for i := 0; i < 100; i++ {
+		cm.Put(i, i*10)
+	}
+
+	n := 0
+	cm.Range(func(key interface{}, value interface{}) bool {
+		if value != key.(int)*10 {
+			t.Errorf("Range, call fn with %v, %v, want %v", key, value, key.(int)*10)
+		}
+		n++
+		return true
+	})
+	if n != 100 {
+		t.Errorf("Range, call fn %v times, want 100", n)
+	}
+
+	n = 0
+	cm.Range(func(key interface{}, value interface{}) bool {
+		n++
+		return n < 10
+	})
+	if n != 10 {
+		t.Errorf("Range stopped by fn, call fn %v times, want 10", n)
+	}
+}
+
+func TestParallelForEach(t *testing.T) {
+	cm := NewConcurrentMap()
+	for i := 0; i < 10000; i++ {
+		cm.Put(i, i*10)
+	}
+
+	for _, workers := range []int{1, 4, 100} {
+		var n, sum int64
+		cm.ParallelForEach(func(key interface{}, value interface{}) {
+			atomic.AddInt64(&n, 1)
+			atomic.AddInt64(&sum, int64(value.(int)))
+		}, workers)
+		if n != 10000 || sum != 499950000 {
+			t.Errorf("ParallelForEach with %v workers, call fn %v times with sum %v, want 10000, 499950000", workers, n, sum)
+		}
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "fn failed" {
+				t.Errorf("ParallelForEach with panicking fn, panic %v, want fn failed", r)
+			}
+		}()
+		cm.ParallelForEach(func(key interface{}, value interface{}) {
+			panic("fn failed")
+		}, 4)
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r != IllegalArgError {
+				t.Errorf("ParallelForEach with 0 workers, panic %v, want IllegalArgError", r)
+			}
+		}()
+		cm.ParallelForEach(func(key interface{}, value interface{}) {}, 0)
+	}()
+}
+
+//the readers must see the value of every published entry without lock, also run it with -race
+func TestPublication(t *testing.T) {
+	cm := NewConcurrentMap()
+	var published int32 = -1
+	n := 20000
+	done := make(chan struct{})
+	wg := new(sync.WaitGroup)
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				//the key is put before it is published, so Get must return its value
+				k := int(atomic.LoadInt32(&published))
+				if k < 0 {
+					continue
+				}
+				if v, err := cm.Get(k); v != k*10 || err != nil {
+					t.Errorf("Get published key %v, return %v, %v, want %v, nil", k, v, err, k*10)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		cm.Put(i, i*10)
+		atomic.StoreInt32(&published, int32(i))
+	}
+	close(done)
+	wg.Wait()
+}
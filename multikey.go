@@ -0,0 +1,151 @@
+package concurrent
+
+import (
+	"container/heap"
+	"sync/atomic"
+)
+
+/**
+ * Locks the segments at index i and j in index order, so the goroutines that lock
+ * multiple segments can't deadlock. The segment is locked once if i == j.
+ *
+ * @return the function that unlocks the segments
+ */
+func (this *ConcurrentMap) lockSegments(i int, j int) (unlock func()) {
+	if i > j {
+		i, j = j, i
+	}
+	si, sj := this.segments[i], this.segments[j]
+	si.acquire()
+	if i != j {
+		sj.acquire()
+	}
+	return func() {
+		if i != j {
+			sj.lock.Unlock()
+		}
+		si.lock.Unlock()
+	}
+}
+
+func (this *ConcurrentMap) hashKeys(k1 interface{}, k2 interface{}) (h1 uint32, h2 uint32, err error) {
+	if isNil(k1) || isNil(k2) {
+		return 0, 0, NilKeyError
+	}
+	if h1, err = hashKey(k1, this, false); err != nil {
+		return
+	}
+	h2, err = hashKey(k2, this, false)
+	return
+}
+
+/**
+ * Swaps the mappings of k1 and k2 atomically, the expiration time, the soft TTL, the tag
+ * and the eviction priority are swapped with the value.
+ * If only one of the keys is in the map, its mapping is moved to the other key.
+ *
+ * The segments of both keys are locked in index order,
+ * so no goroutine can see the state that only one key has been changed.
+ */
+func (this *ConcurrentMap) SwapKeys(k1 interface{}, k2 interface{}) (err error) {
+	h1, h2, err := this.hashKeys(k1, k2)
+	if err != nil {
+		return
+	}
+	Printf("SwapKeys, %v, %v, %v, %v\n", k1, h1, k2, h2)
+	if equals(k1, k2) {
+		return
+	}
+
+	s1, s2 := this.segmentFor(h1), this.segmentFor(h2)
+	unlock := this.lockSegments(this.segmentIndex(h1), this.segmentIndex(h2))
+	e1, e2 := s1.findUnderLock(k1, h1), s2.findUnderLock(k2, h2)
+	var kv1, kv2 Entry
+	if e1 != nil {
+		kv1 = e1.unlinked()
+	}
+	if e2 != nil {
+		kv2 = e2.unlinked()
+	}
+
+	//the mapping is removed before it is moved, so the bounded segment doesn't exceed
+	//its limit temporarily and evict an unrelated mapping
+	if e1 != nil && e2 == nil {
+		s1.removeUnderLock(k1, h1, nil)
+	} else if e2 != nil && e1 == nil {
+		s2.removeUnderLock(k2, h2, nil)
+	}
+	if e2 != nil {
+		s1.storeWithStaleUnderLock(k1, h1, kv2.fastValue(), kv2.expireAt, kv2.staleAt)
+		s1.moveAttrsUnderLock(k1, h1, &kv2)
+	}
+	if e1 != nil {
+		s2.storeWithStaleUnderLock(k2, h2, kv1.fastValue(), kv1.expireAt, kv1.staleAt)
+		s2.moveAttrsUnderLock(k2, h2, &kv1)
+	}
+	unlock()
+
+	if kv2.expireAt != 0 {
+		this.scheduleExpiration(k1, h1, kv2.expireAt)
+	}
+	if kv1.expireAt != 0 {
+		this.scheduleExpiration(k2, h2, kv1.expireAt)
+	}
+	return
+}
+
+/**
+ * Moves the mapping of oldKey to newKey atomically, the expiration time, the soft TTL, the tag
+ * and the eviction priority are moved with the value.
+ * The previous mapping of newKey is overwritten.
+ * It is useful for the renames like session ID rotation, that can't be done by Remove and Put.
+ *
+ * @return true if the mapping be moved, false if there was no mapping for oldKey
+ */
+func (this *ConcurrentMap) Rename(oldKey interface{}, newKey interface{}) (ok bool, err error) {
+	h1, h2, err := this.hashKeys(oldKey, newKey)
+	if err != nil {
+		return
+	}
+	Printf("Rename, %v, %v, %v, %v\n", oldKey, h1, newKey, h2)
+
+	s1, s2 := this.segmentFor(h1), this.segmentFor(h2)
+	unlock := this.lockSegments(this.segmentIndex(h1), this.segmentIndex(h2))
+	e := s1.findUnderLock(oldKey, h1)
+	if e == nil {
+		unlock()
+		return false, nil
+	}
+	if equals(oldKey, newKey) {
+		unlock()
+		return true, nil
+	}
+
+	kv := e.unlinked()
+	s1.removeUnderLock(oldKey, h1, nil)
+	s2.storeWithStaleUnderLock(newKey, h2, kv.fastValue(), kv.expireAt, kv.staleAt)
+	s2.moveAttrsUnderLock(newKey, h2, &kv)
+	unlock()
+
+	if kv.expireAt != 0 {
+		this.scheduleExpiration(newKey, h2, kv.expireAt)
+	}
+	return true, nil
+}
+
+//moveAttrsUnderLock copies the tag and eviction priority of the moved entry to the mapping of key,
+//because storeUnderLock keeps the ones of an existing mapping and a new mapping has none.
+//Call only while holding lock.
+func (this *Segment) moveAttrsUnderLock(key interface{}, hash uint32, from *Entry) {
+	e := this.findUnderLock(key, hash)
+	if e == nil {
+		return
+	}
+	atomic.StoreUint64(&e.tag, from.tag)
+	if e.priority != from.priority {
+		e.priority = from.priority
+		if this.evictor != nil {
+			heap.Push(&this.evictor.candidates, evictionCandidate{key, hash, e.priority, atomic.LoadInt64(&e.accessed)})
+		}
+	}
+}
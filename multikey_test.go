@@ -0,0 +1,130 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSwapKeys(t *testing.T) {
+	cm := NewConcurrentMap()
+	cm.Put("a", 1)
+	cm.PutWithTTL("b", 2, time.Hour)
+
+	if err := cm.SwapKeys("a", "b"); err != nil {
+		t.Errorf("SwapKeys, return %v, want nil", err)
+	}
+	if v, _ := cm.Get("a"); v != 2 {
+		t.Errorf("Get a after SwapKeys, return %v, want 2", v)
+	}
+	if v, _ := cm.Get("b"); v != 1 {
+		t.Errorf("Get b after SwapKeys, return %v, want 1", v)
+	}
+	if d, _, _ := cm.TTL("a"); d <= 0 {
+		t.Errorf("TTL a after SwapKeys, return %v, want > 0", d)
+	}
+	if d, _, _ := cm.TTL("b"); d >= 0 {
+		t.Errorf("TTL b after SwapKeys, return %v, want < 0", d)
+	}
+
+	cm.SwapKeys("a", "c")
+	if ok, _ := cm.ContainsKey("a"); ok {
+		t.Errorf("ContainsKey a after swapping with absent key, return true, want false")
+	}
+	if v, _ := cm.Get("c"); v != 2 {
+		t.Errorf("Get c after SwapKeys, return %v, want 2", v)
+	}
+	if err := cm.SwapKeys(nil, "c"); err != NilKeyError {
+		t.Errorf("SwapKeys nil key, return %v, want NilKeyError", err)
+	}
+}
+
+func TestRename(t *testing.T) {
+	cm := NewConcurrentMap()
+	cm.Put("a", 1)
+	cm.Put("b", 2)
+
+	if ok, err := cm.Rename("a", "b"); !ok || err != nil {
+		t.Errorf("Rename, return %v, %v, want true, nil", ok, err)
+	}
+	if v, _ := cm.Get("b"); v != 1 || cm.Size() != 1 {
+		t.Errorf("Get b after Rename, return %v, want 1", v)
+	}
+	if ok, _ := cm.Rename("a", "c"); ok {
+		t.Errorf("Rename absent key, return true, want false")
+	}
+
+	//concurrent renames never lose the mapping
+	n := 100
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				cm.Rename("b", i)
+				cm.Rename(i, "b")
+			}
+		}()
+	}
+	wg.Wait()
+	if s := cm.Size(); s != 1 {
+		t.Errorf("Get size after concurrent renames, return %v, want 1", s)
+	}
+}
+
+func TestMoveKeepsTagAndPriority(t *testing.T) {
+	cm := NewConcurrentMap(16, float32(0.75), 1, WithMaxEntries(10))
+	cm.PutWithTag("a", 1, 7)
+	cm.PutWithPriority("b", 2, 5)
+	priority := func(key interface{}) int8 {
+		hash, _ := hashKey(key, cm, false)
+		return cm.segments[0].findUnderLock(key, hash).priority
+	}
+
+	cm.SwapKeys("a", "b")
+	if v, tag, _ := cm.GetWithTag("b"); v != 1 || tag != 7 {
+		t.Errorf("GetWithTag b after SwapKeys, return %v, %v, want 1, 7", v, tag)
+	}
+	if p := priority("a"); p != 5 {
+		t.Errorf("Get priority of a after SwapKeys, return %v, want 5", p)
+	}
+	if p := priority("b"); p != 0 {
+		t.Errorf("Get priority of b after SwapKeys, return %v, want 0", p)
+	}
+
+	cm.Rename("a", "c")
+	cm.Rename("b", "d")
+	if v, tag, _ := cm.GetWithTag("d"); v != 1 || tag != 7 {
+		t.Errorf("GetWithTag d after Rename, return %v, %v, want 1, 7", v, tag)
+	}
+	if p := priority("c"); p != 5 {
+		t.Errorf("Get priority of c after Rename, return %v, want 5", p)
+	}
+}
+
+func TestMoveInBoundedMap(t *testing.T) {
+	cm := NewConcurrentMap(16, float32(0.75), 1, WithMaxEntries(2))
+	cm.Put("x", 0)
+	cm.Put("b", 2)
+	//moving b to the absent key a doesn't change the size, so nothing is evicted
+	cm.SwapKeys("a", "b")
+	if v, _ := cm.Get("x"); v != 0 {
+		t.Errorf("Get x after SwapKeys in bounded map, return %v, want 0", v)
+	}
+	if v, _ := cm.Get("a"); v != 2 || cm.Size() != 2 {
+		t.Errorf("Get a after SwapKeys in bounded map, return %v, Size is %v, want 2, 2", v, cm.Size())
+	}
+
+	//the soft TTL is moved with the mapping
+	cm.PutWithSoftTTL("s", 1, time.Millisecond, 0)
+	time.Sleep(5 * time.Millisecond)
+	cm.Rename("s", "t")
+	if v, stale, _ := cm.GetWithStale("t"); v != 1 || !stale {
+		t.Errorf("GetWithStale after Rename, return %v, %v, want 1, true", v, stale)
+	}
+	cm.SwapKeys("t", "u")
+	if v, stale, _ := cm.GetWithStale("u"); v != 1 || !stale {
+		t.Errorf("GetWithStale after SwapKeys, return %v, %v, want 1, true", v, stale)
+	}
+}
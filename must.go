@@ -0,0 +1,30 @@
+package concurrent
+
+/**
+ * Same as Put, but panics if Put returns error, e.g. the key or value is nil.
+ * It is for the init-time population code where the error handling is pure noise.
+ *
+ * @return the previous value associated with key, or
+ *         nil if there was no mapping for key
+ */
+func (this *ConcurrentMap) MustPut(key interface{}, value interface{}) (oldVal interface{}) {
+	oldVal, err := this.Put(key, value)
+	if err != nil {
+		panic(err)
+	}
+	return
+}
+
+/**
+ * Same as Get, but panics if Get returns error, e.g. the key is nil.
+ *
+ * @return the value to which the specified key is mapped,
+ *         or nil if this map contains no mapping for the key
+ */
+func (this *ConcurrentMap) MustGet(key interface{}) (value interface{}) {
+	value, err := this.Get(key)
+	if err != nil {
+		panic(err)
+	}
+	return
+}
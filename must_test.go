@@ -0,0 +1,22 @@
+package concurrent
+
+import (
+	"testing"
+)
+
+func TestMust(t *testing.T) {
+	cm := NewConcurrentMap()
+	if v := cm.MustPut("a", 1); v != nil {
+		t.Errorf("MustPut a, return %v, want nil", v)
+	}
+	if v := cm.MustGet("a"); v != 1 {
+		t.Errorf("MustGet a, return %v, want 1", v)
+	}
+
+	defer func() {
+		if e := recover(); e != NilKeyError {
+			t.Errorf("MustPut nil key, panic %v, want NilKeyError", e)
+		}
+	}()
+	cm.MustPut(nil, 1)
+}
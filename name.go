@@ -0,0 +1,21 @@
+package concurrent
+
+/**
+ * Returns an Option that names the map, so the services that have multiple maps
+ * can tell them apart. The name is included in Stats and in the graph of DumpStructure.
+ */
+func WithName(name string) Option {
+	if name == "" {
+		panic(IllegalArgError)
+	}
+	return func(m *ConcurrentMap) {
+		m.name = name
+	}
+}
+
+/**
+ * Returns the name set by WithName, or "" if the map isn't named.
+ */
+func (this *ConcurrentMap) Name() string {
+	return this.name
+}
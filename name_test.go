@@ -0,0 +1,33 @@
+package concurrent
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWithName(t *testing.T) {
+	cm := NewConcurrentMap(WithName("sessions"), WithLatencyStats())
+	if cm.Name() != "sessions" {
+		t.Errorf("Name, return %v, want sessions", cm.Name())
+	}
+	if stats, _ := cm.Stats(); stats.Name != "sessions" {
+		t.Errorf("Stats, Name is %v, want sessions", stats.Name)
+	}
+
+	var buf bytes.Buffer
+	cm.DumpStructure(&buf)
+	if !strings.HasPrefix(buf.String(), "digraph \"sessions\" {") {
+		t.Errorf("DumpStructure, return %v, want the graph named sessions", buf.String())
+	}
+
+	if NewConcurrentMap().Name() != "" {
+		t.Errorf("Name of unnamed map, return %v, want empty", NewConcurrentMap().Name())
+	}
+	defer func() {
+		if r := recover(); r != IllegalArgError {
+			t.Errorf("WithName with empty name, panic %v, want IllegalArgError", r)
+		}
+	}()
+	WithName("")
+}
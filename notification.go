@@ -0,0 +1,76 @@
+package concurrent
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	/**
+	 * The buffer size of channel returned by Expired.
+	 * If receiver is too slow and buffer is full, the expired entries will be dropped.
+	 */
+	EXPIRED_CHANNEL_BUFFER int = 1024
+)
+
+//expiredNotifier delivers expired entries to the subscribed channels
+type expiredNotifier struct {
+	lock sync.RWMutex
+	subs []chan Entry
+}
+
+//subscribe returns a channel that will be closed when ctx or done is done
+func (this *expiredNotifier) subscribe(ctx context.Context, done <-chan struct{}) <-chan Entry {
+	ch := make(chan Entry, EXPIRED_CHANNEL_BUFFER)
+	this.lock.Lock()
+	this.subs = append(this.subs, ch)
+	this.lock.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		this.lock.Lock()
+		defer this.lock.Unlock()
+		for i, sub := range this.subs {
+			if sub == ch {
+				this.subs = append(this.subs[:i:i], this.subs[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}()
+	return ch
+}
+
+//notify sends the entry to all subscribers without blocking,
+//it will be dropped for the subscribers that buffer is full
+func (this *expiredNotifier) notify(e *Entry) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	if len(this.subs) == 0 {
+		return
+	}
+
+	copied := e.unlinked()
+	for _, ch := range this.subs {
+		select {
+		case ch <- copied:
+		default:
+		}
+	}
+}
+
+/**
+ * Returns a channel that receives the entries at the moment they expire.
+ * The entries are delivered when they are removed by the timing wheel,
+ * or when an expired entry is overwritten or removed before that.
+ *
+ * The delivery is best-effort, the channel has a bounded buffer
+ * (EXPIRED_CHANNEL_BUFFER) and entries are dropped if the buffer is full.
+ * The channel will be closed when ctx is done or the map is closed.
+ */
+func (this *ConcurrentMap) Expired(ctx context.Context) <-chan Entry {
+	return this.expiredNotifier.subscribe(ctx, this.closed)
+}
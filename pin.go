@@ -0,0 +1,51 @@
+package concurrent
+
+import (
+	"sync/atomic"
+)
+
+/**
+ * Calls f for every entry until f returns false, the entries are passed without copies.
+ * The segment that is being visited is pinned: the pin is published under the segment lock,
+ * so an eviction in progress finishes before the visit starts, and no entry of the segment
+ * is evicted until the visit ends, the evictions are deferred to the first Put after that.
+ *
+ * Pinning only defers the evictions, the writers are not blocked, so the values of entries
+ * may be changed and the entries may still be removed, replaced or expire by the other
+ * goroutines while pinned. f may hold the entry pointers after it returns, as the GC keeps them alive,
+ * but an entry unlinked from the map is not updated anymore.
+ * The result is weakly consistent like MapIterator.
+ */
+func (this *ConcurrentMap) ForEachPinned(f func(e *Entry) bool) {
+	for _, seg := range this.segments {
+		if !seg.forEachPinned(f) {
+			return
+		}
+	}
+}
+
+func (this *Segment) forEachPinned(f func(e *Entry) bool) bool {
+	//publish the pin under lock, so it doesn't race with an admit or evict in progress
+	this.acquire()
+	atomic.AddInt32(&this.pins, 1)
+	this.lock.Unlock()
+	defer atomic.AddInt32(&this.pins, -1)
+
+	if atomic.LoadInt32(&this.count) == 0 {
+		return true
+	}
+	tab := this.loadTable()
+	for i := 0; i < len(tab); i++ {
+		for e := (*Entry)(atomic.LoadPointer(&tab[i])); e != nil; e = e.next {
+			if !e.expired() && !f(e) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//pinned returns true if the segment is being visited by ForEachPinned
+func (this *Segment) pinned() bool {
+	return atomic.LoadInt32(&this.pins) > 0
+}
@@ -0,0 +1,59 @@
+package concurrent
+
+import (
+	"testing"
+)
+
+func TestForEachPinned(t *testing.T) {
+	cm := NewConcurrentMap(16, float32(0.75), 1, WithMaxEntries(10))
+	for i := 0; i < 10; i++ {
+		cm.Put(i, i)
+	}
+
+	visited := make([]*Entry, 0, 10)
+	cm.ForEachPinned(func(e *Entry) bool {
+		if len(visited) == 0 {
+			//the Puts in callback do not evict the entries
+			for i := 100; i < 110; i++ {
+				cm.Put(i, 0)
+			}
+		}
+		visited = append(visited, e)
+		return true
+	})
+	if len(visited) < 10 {
+		t.Errorf("ForEachPinned, visit %v entries, want >= 10", len(visited))
+	}
+	for _, e := range visited {
+		if v, _ := cm.Get(e.Key()); v != e.Value() {
+			t.Errorf("Get visited %v, return %v, want %v", e.Key(), v, e.Value())
+		}
+	}
+	if s := cm.Size(); s != 20 {
+		t.Errorf("Get size while evictions are deferred, return %v, want 20", s)
+	}
+
+	//the deferred evictions are done by the next Put
+	cm.Put(200, 0)
+	if s := cm.Size(); s != 10 {
+		t.Errorf("Get size after unpinned, return %v, want 10", s)
+	}
+
+	n := 0
+	cm.ForEachPinned(func(e *Entry) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Errorf("ForEachPinned stopped by callback, visit %v entries, want 3", n)
+	}
+
+	//pinning only defers the evictions, Remove still proceeds
+	cm.ForEachPinned(func(e *Entry) bool {
+		cm.Remove(e.Key())
+		if v, _ := cm.Get(e.Key()); v != nil {
+			t.Errorf("Get removed %v while pinned, return %v, want nil", e.Key(), v)
+		}
+		return false
+	})
+}
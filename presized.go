@@ -0,0 +1,49 @@
+package concurrent
+
+import (
+	"math"
+)
+
+//the max number of entries of a slab, so a slab of the large map is not a huge single allocation
+const maxSlabSize = 1024
+
+/**
+ * Creates a ConcurrentMap for bulk loading n mappings once, e.g. by ETL jobs that insert
+ * tens of millions of mappings. The tables of segments are sized with a headroom for
+ * the uneven spread of keys, so no rehash occurs while inserting the first n mappings
+ * in practice, and the entries are allocated in slabs instead of one by one.
+ *
+ * The memory of a slab is released only after all of its entries are removed,
+ * so the map should not be used for a workload that removes most mappings and keeps a few.
+ *
+ * @param n the expected number of mappings, must not be negative
+ */
+func NewPresized(n int, opts ...Option) (m *ConcurrentMap) {
+	if n < 0 {
+		panic(IllegalArgError)
+	}
+	segs := 1
+	for segs < DEFAULT_CONCURRENCY_LEVEL {
+		segs <<= 1
+	}
+	//the number of mappings of a segment is binomial, 4 standard deviations is enough headroom
+	perSeg := (n + segs - 1) / segs
+	expected := perSeg + int(4*math.Sqrt(float64(perSeg)))
+
+	capacity := capacityFor(expected)
+	if capacity > MAXIMUM_CAPACITY/segs {
+		capacity = MAXIMUM_CAPACITY / segs
+	}
+
+	m = newConcurrentMap3(capacity*segs, DEFAULT_LOAD_FACTOR, DEFAULT_CONCURRENCY_LEVEL)
+	for _, seg := range m.segments {
+		seg.slabSize = perSeg
+		if seg.slabSize > maxSlabSize {
+			seg.slabSize = maxSlabSize
+		}
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return
+}
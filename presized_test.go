@@ -0,0 +1,53 @@
+package concurrent
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestNewPresized(t *testing.T) {
+	n := 100000
+	cm := NewPresized(n)
+	capacities := make([]int, len(cm.segments))
+	for i, seg := range cm.segments {
+		capacities[i] = len(seg.table())
+	}
+
+	keys := rand.Perm(n * 10)[:n]
+	for _, k := range keys {
+		cm.Put(k, k)
+	}
+	if cm.Size() != int32(n) {
+		t.Errorf("Size of presized map, return %v, want %v", cm.Size(), n)
+	}
+	for i, seg := range cm.segments {
+		if c := len(seg.table()); c != capacities[i] {
+			t.Errorf("capacity of segment %v after %v inserts, is %v, want %v without rehash", i, n, c, capacities[i])
+		}
+	}
+
+	//the entries are allocated in slabs
+	seg := cm.segments[0]
+	if seg.slabSize != maxSlabSize || seg.slab == nil {
+		t.Errorf("slab of segment, size is %v, want %v", seg.slabSize, maxSlabSize)
+	}
+	for _, k := range keys[:100] {
+		if v, _ := cm.Get(k); v != k {
+			t.Errorf("Get %v from presized map, return %v, want %v", k, v, k)
+		}
+		cm.Remove(k)
+	}
+	if cm.Size() != int32(n-100) {
+		t.Errorf("Size after Remove, return %v, want %v", cm.Size(), n-100)
+	}
+
+	if cm := NewPresized(0, WithName("empty")); cm.Name() != "empty" || cm.segments[0].slabSize != 0 {
+		t.Errorf("NewPresized(0), return name %v, slab size %v, want empty, 0", cm.Name(), cm.segments[0].slabSize)
+	}
+	defer func() {
+		if r := recover(); r != IllegalArgError {
+			t.Errorf("NewPresized(-1), panic %v, want IllegalArgError", r)
+		}
+	}()
+	NewPresized(-1)
+}
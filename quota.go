@@ -0,0 +1,182 @@
+package concurrent
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	QuotaExceededError = errors.New("QuotaExceededException")
+)
+
+//tenantState is the usage of a tenant, lock serializes the Puts of the tenant through TenantQuota
+type tenantState struct {
+	usage int64 //atomic
+	limit int64 //atomic, -1 means the default limit
+	lock  sync.Mutex
+}
+
+/**
+ * TenantQuota is a quota-enforcing view of a map that is shared by tenants,
+ * the tenant of a mapping is extracted from its key, and the Puts through the view
+ * return QuotaExceededError if they make the tenant exceed its limit.
+ *
+ * The usage of tenants is maintained on every mutation of the map, includes the removals,
+ * expirations, evictions and the writes that don't go through the view.
+ * But only the Puts through the view are enforced.
+ */
+type TenantQuota struct {
+	m        *ConcurrentMap
+	tenantOf func(key interface{}) interface{}
+	weigher  func(key interface{}, value interface{}) int64
+	limit    int64
+
+	lock    sync.RWMutex
+	tenants map[interface{}]*tenantState
+}
+
+/**
+ * Creates a TenantQuota, it must be attached to a map by WithTenantQuota.
+ *
+ * @param tenantOf returns the tenant of key, the tenant must be comparable
+ * @param limit the default limit of every tenant, see SetLimit
+ * @param weigher returns the weight of mapping, if it is nil, the usage is the number of mappings
+ */
+func NewTenantQuota(tenantOf func(key interface{}) interface{}, limit int64,
+	weigher func(key interface{}, value interface{}) int64) *TenantQuota {
+	if tenantOf == nil {
+		panic(NilActionError)
+	}
+	if limit < 0 {
+		panic(IllegalArgError)
+	}
+	return &TenantQuota{
+		tenantOf: tenantOf,
+		weigher:  weigher,
+		limit:    limit,
+		tenants:  make(map[interface{}]*tenantState),
+	}
+}
+
+/**
+ * Returns an Option that attaches the quota to the map, a quota can be attached to only one map.
+ */
+func WithTenantQuota(q *TenantQuota) Option {
+	return func(m *ConcurrentMap) {
+		if q.m != nil {
+			panic(IllegalArgError)
+		}
+		q.m = m
+		m.listeners = append(m.listeners, q)
+	}
+}
+
+func (this *TenantQuota) state(tenant interface{}) *tenantState {
+	this.lock.RLock()
+	ts, ok := this.tenants[tenant]
+	this.lock.RUnlock()
+	if ok {
+		return ts
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if ts, ok = this.tenants[tenant]; !ok {
+		ts = &tenantState{limit: -1}
+		this.tenants[tenant] = ts
+	}
+	return ts
+}
+
+func (this *TenantQuota) weight(key interface{}, value interface{}) int64 {
+	if value == nil {
+		return 0
+	}
+	if this.weigher == nil {
+		return 1
+	}
+	return this.weigher(key, value)
+}
+
+func (this *TenantQuota) onMutation(key interface{}, hash uint32, oldVal interface{}, newVal interface{}) {
+	if delta := this.weight(key, newVal) - this.weight(key, oldVal); delta != 0 {
+		atomic.AddInt64(&this.state(this.tenantOf(key)).usage, delta)
+	}
+}
+
+/**
+ * Sets the limit of tenant, it overrides the default limit.
+ * The mappings are not removed if the usage of tenant has exceeded the new limit.
+ */
+func (this *TenantQuota) SetLimit(tenant interface{}, limit int64) {
+	if limit < 0 {
+		panic(IllegalArgError)
+	}
+	atomic.StoreInt64(&this.state(tenant).limit, limit)
+}
+
+/**
+ * Returns the limit of tenant.
+ */
+func (this *TenantQuota) Limit(tenant interface{}) int64 {
+	if limit := atomic.LoadInt64(&this.state(tenant).limit); limit >= 0 {
+		return limit
+	}
+	return this.limit
+}
+
+/**
+ * Returns the usage of tenant, i.e. the number or total weight of its mappings.
+ */
+func (this *TenantQuota) Usage(tenant interface{}) int64 {
+	return atomic.LoadInt64(&this.state(tenant).usage)
+}
+
+/**
+ * Maps the specified key to the specified value like Put,
+ * if the mapping makes the tenant of key exceed its limit, nothing is changed.
+ * A Put that doesn't increase the usage always succeeds even if the tenant has exceeded its limit.
+ *
+ * @return QuotaExceededError if the tenant exceeds its limit,
+ *         IllegalStateError if the quota isn't attached to a map,
+ *         ValidationError if the mapping is rejected by WithValidator
+ */
+func (this *TenantQuota) Put(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	if this.m == nil {
+		return nil, IllegalStateError
+	}
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+	if isNil(value) {
+		return nil, NilValueError
+	}
+	if e := this.m.validate(key, value); e != nil {
+		return nil, e
+	}
+
+	hash, err := hashKey(key, this.m, false)
+	if err != nil {
+		return
+	}
+	Printf("TenantQuota Put, %v, %v\n", key, hash)
+	tenant := this.tenantOf(key)
+	ts := this.state(tenant)
+	//the Puts of a tenant are serialized, so they cannot exceed the limit together
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	seg := this.m.segmentFor(hash)
+	seg.acquire()
+	defer seg.lock.Unlock()
+	var current interface{}
+	if e := seg.findUnderLock(key, hash); e != nil {
+		current = e.fastValue()
+	}
+	delta := this.weight(key, value) - this.weight(key, current)
+	if delta > 0 && atomic.LoadInt64(&ts.usage)+delta > this.Limit(tenant) {
+		return nil, QuotaExceededError
+	}
+	return seg.putUnderLock(key, hash, value, false, nil, 0), nil
+}
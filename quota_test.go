@@ -0,0 +1,91 @@
+package concurrent
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTenantQuota(t *testing.T) {
+	q := NewTenantQuota(func(key interface{}) interface{} {
+		return strings.SplitN(key.(string), "/", 2)[0]
+	}, 10, nil)
+	cm := NewConcurrentMap(WithTenantQuota(q))
+	q.SetLimit("b", 100)
+
+	wg := new(sync.WaitGroup)
+	var lock sync.Mutex
+	exceeded := 0
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				if _, err := q.Put("a/"+string(rune('a'+g))+string(rune('a'+i)), i); err == QuotaExceededError {
+					lock.Lock()
+					exceeded++
+					lock.Unlock()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if u := q.Usage("a"); u != 10 || exceeded != 30 {
+		t.Errorf("Usage of a after concurrent Puts, return %v, %v exceeded, want 10, 30 exceeded", u, exceeded)
+	}
+
+	//updates do not increase the usage
+	for itr := cm.Iterator(); itr.HasNext(); {
+		k, _, _ := itr.Next()
+		if _, err := q.Put(k, 100); err != nil {
+			t.Errorf("Update %v, return %v, want nil", k, err)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := q.Put("b/"+string(rune('a'+i)), i); err != nil {
+			t.Errorf("Put to tenant b, return %v, want nil", err)
+		}
+	}
+	if u, l := q.Usage("b"), q.Limit("b"); u != 20 || l != 100 {
+		t.Errorf("Usage and limit of b, return %v, %v, want 20, 100", u, l)
+	}
+
+	//the removals of map are counted
+	for itr := cm.Iterator(); itr.HasNext(); {
+		k, _, _ := itr.Next()
+		if strings.HasPrefix(k.(string), "a/") {
+			cm.Remove(k)
+			break
+		}
+	}
+	if u := q.Usage("a"); u != 9 {
+		t.Errorf("Usage of a after Remove, return %v, want 9", u)
+	}
+	if _, err := q.Put("a/new", 1); err != nil {
+		t.Errorf("Put to tenant a after Remove, return %v, want nil", err)
+	}
+
+	if _, err := NewTenantQuota(func(key interface{}) interface{} { return 0 }, 1, nil).Put(1, 1); err != IllegalStateError {
+		t.Errorf("Put to detached quota, return %v, want IllegalStateError", err)
+	}
+}
+
+func TestTenantQuotaWeigher(t *testing.T) {
+	q := NewTenantQuota(func(key interface{}) interface{} {
+		return key.(int) % 2
+	}, 10, func(key interface{}, value interface{}) int64 {
+		return int64(len(value.(string)))
+	})
+	NewConcurrentMap(WithTenantQuota(q))
+
+	q.Put(1, "12345")
+	if _, err := q.Put(3, "123456"); err != QuotaExceededError {
+		t.Errorf("Put over weight, return %v, want QuotaExceededError", err)
+	}
+	if _, err := q.Put(1, "1234567890"); err != nil {
+		t.Errorf("Update to limit weight, return %v, want nil", err)
+	}
+	if u := q.Usage(1); u != 10 {
+		t.Errorf("Usage after update, return %v, want 10", u)
+	}
+}
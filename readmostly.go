@@ -0,0 +1,588 @@
+package concurrent
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+const (
+	/**
+	 * The dirty overlay of ReadMostlyMap is merged into the read table
+	 * if it has at least this many mappings and at least 1/4 as many mappings as the read table.
+	 */
+	READ_MOSTLY_MERGE_THRESHOLD int32 = 64
+)
+
+//expunged marks an entry of read table that was deleted and has been dropped by merge,
+//the key must be written to the dirty overlay instead of this entry
+var expunged = unsafe.Pointer(new(interface{}))
+
+type rcuEntry struct {
+	key   interface{}
+	hash  uint32
+	value unsafe.Pointer //*interface{}, nil if deleted
+}
+
+/**
+ * Returns the value of entry, ok is false if the entry has been expunged.
+ */
+func (this *rcuEntry) load() (value interface{}, ok bool) {
+	p := atomic.LoadPointer(&this.value)
+	if p == expunged {
+		return nil, false
+	}
+	if p != nil {
+		value = *(*interface{})(p)
+	}
+	return value, true
+}
+
+//rcuTable is the read table of ReadMostlyMap, it is immutable except the values of entries
+type rcuTable struct {
+	buckets map[uint32][]*rcuEntry
+	entries []*rcuEntry
+}
+
+func newRcuTable(capacity int) *rcuTable {
+	return &rcuTable{
+		buckets: make(map[uint32][]*rcuEntry, capacity),
+		entries: make([]*rcuEntry, 0, capacity),
+	}
+}
+
+func (this *rcuTable) add(e *rcuEntry) {
+	this.buckets[e.hash] = append(this.buckets[e.hash], e)
+	this.entries = append(this.entries, e)
+}
+
+func (this *rcuTable) find(key interface{}, hash uint32) *rcuEntry {
+	for _, e := range this.buckets[hash] {
+		if equals(e.key, key) {
+			return e
+		}
+	}
+	return nil
+}
+
+/**
+ * ReadMostlyMap is a map optimized for the workloads that almost only read,
+ * like sync.Map, but it provides the same API as ConcurrentMap.
+ *
+ * The mappings are stored in an immutable read table that is atomically swapped,
+ * and a small dirty overlay protected by a mutex that holds the keys not in read table.
+ * Get never holds lock if key is in read table, and the value of a key in read table
+ * is changed by CAS without lock too. The dirty overlay is merged into a new read table
+ * when it grows or too many reads miss the read table.
+ *
+ * Writing new keys is slower than ConcurrentMap, so use ConcurrentMap unless
+ * the set of keys is almost stable.
+ */
+type ReadMostlyMap struct {
+	read unsafe.Pointer //*rcuTable
+	//count is the number of live entries in read table, must use atomic to read/write
+	count int32
+
+	lock sync.Mutex
+	//dirty is a map with one segment that holds the keys not in read table,
+	//it is only accessed while holding lock, and is also used to compute the hash code of keys
+	dirty  *ConcurrentMap
+	misses int32 //protected by lock
+}
+
+var _ Map = (*ReadMostlyMap)(nil)
+
+/**
+ * Creates a new, empty ReadMostlyMap.
+ */
+func NewReadMostlyMap() *ReadMostlyMap {
+	return &ReadMostlyMap{
+		read:  unsafe.Pointer(newRcuTable(0)),
+		dirty: newConcurrentMap3(DEFAULT_INITIAL_CAPACITY, DEFAULT_LOAD_FACTOR, 1),
+	}
+}
+
+func (this *ReadMostlyMap) table() *rcuTable {
+	return (*rcuTable)(atomic.LoadPointer(&this.read))
+}
+
+func (this *ReadMostlyMap) dirtySegment() *Segment {
+	return this.dirty.segments[0]
+}
+
+/**
+ * Changes the value of e by f with CAS, f returns the new value and false if it doesn't write.
+ * f may be called more than once if the value is changed concurrently.
+ *
+ * @return false if e has been expunged
+ */
+func (this *ReadMostlyMap) update(e *rcuEntry, f func(old interface{}) (newVal interface{}, write bool)) bool {
+	for {
+		p := atomic.LoadPointer(&e.value)
+		if p == expunged {
+			return false
+		}
+		var old interface{}
+		if p != nil {
+			old = *(*interface{})(p)
+		}
+
+		newVal, write := f(old)
+		if !write {
+			return true
+		}
+		var np unsafe.Pointer
+		if newVal != nil {
+			np = unsafe.Pointer(&newVal)
+		}
+		if atomic.CompareAndSwapPointer(&e.value, p, np) {
+			if p == nil && np != nil {
+				atomic.AddInt32(&this.count, 1)
+			} else if p != nil && np == nil {
+				atomic.AddInt32(&this.count, -1)
+			}
+			return true
+		}
+	}
+}
+
+//write applies f to the entry of key in read table without lock,
+//or calls slow with the dirty overlay while holding lock if key isn't in read table
+func (this *ReadMostlyMap) write(key interface{}, hash uint32, f func(old interface{}) (newVal interface{}, write bool), slow func(seg *Segment)) {
+	if e := this.table().find(key, hash); e != nil && this.update(e, f) {
+		return
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	//the entries of the latest read table can't be expunged while holding lock
+	if e := this.table().find(key, hash); e != nil {
+		this.update(e, f)
+		return
+	}
+	slow(this.dirtySegment())
+	this.dirtyChanged()
+}
+
+//dirtyChanged merges dirty overlay if it is large enough, call only while holding lock
+func (this *ReadMostlyMap) dirtyChanged() {
+	n := atomic.LoadInt32(&this.dirtySegment().count)
+	if n >= READ_MOSTLY_MERGE_THRESHOLD && int(n) >= len(this.table().entries)/4 {
+		this.merge()
+	}
+}
+
+//merge creates a new read table that includes the live entries of current read table
+//and the mappings in dirty overlay, call only while holding lock
+func (this *ReadMostlyMap) merge() {
+	old := this.table()
+	dirty := this.dirtySegment().lockedEntries()
+	t := newRcuTable(len(old.entries) + len(dirty))
+	for _, e := range old.entries {
+		//the deleted entries are dropped, CAS prevents the concurrent writers from reviving them
+		if atomic.CompareAndSwapPointer(&e.value, nil, expunged) {
+			continue
+		}
+		t.add(e)
+	}
+	for i := range dirty {
+		v := dirty[i].fastValue()
+		t.add(&rcuEntry{key: dirty[i].key, hash: dirty[i].hash, value: unsafe.Pointer(&v)})
+	}
+
+	atomic.StorePointer(&this.read, unsafe.Pointer(t))
+	this.dirty.Clear()
+	atomic.AddInt32(&this.count, int32(len(dirty)))
+	this.misses = 0
+}
+
+func (this *ReadMostlyMap) hash(key interface{}) (hash uint32, err error) {
+	if isNil(key) {
+		return 0, NilKeyError
+	}
+	return hashKey(key, this.dirty, false)
+}
+
+/**
+ * Returns the value to which the specified key is mapped, or defaultValue if this map
+ * contains no mapping for the key. The defaultValue is not put into this map.
+ */
+func (this *ReadMostlyMap) GetOrDefault(key interface{}, defaultValue interface{}) (value interface{}, err error) {
+	if value, err = this.Get(key); err == nil && value == nil {
+		value = defaultValue
+	}
+	return
+}
+
+/**
+ * Returns the value to which the specified key is mapped,
+ * or nil if this map contains no mapping for the key.
+ */
+func (this *ReadMostlyMap) Get(key interface{}) (value interface{}, err error) {
+	hash, err := this.hash(key)
+	if err != nil {
+		return nil, err
+	}
+	Printf("ReadMostly Get, %v, %v\n", key, hash)
+
+	t := this.table()
+	if e := t.find(key, hash); e != nil {
+		if value, ok := e.load(); ok {
+			return value, nil
+		}
+	}
+	//if dirty overlay is empty and read table was not swapped,
+	//the key was not in map when read table was loaded
+	if atomic.LoadInt32(&this.dirtySegment().count) == 0 && this.table() == t {
+		return nil, nil
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if e := this.table().find(key, hash); e != nil {
+		value, _ = e.load()
+		return
+	}
+	value = this.dirtySegment().get(key, hash)
+	this.misses++
+	if n := atomic.LoadInt32(&this.dirtySegment().count); n > 0 && this.misses >= n {
+		this.merge()
+	}
+	return
+}
+
+/**
+ * Tests if the specified object is a key in this map.
+ */
+func (this *ReadMostlyMap) ContainsKey(key interface{}) (found bool, err error) {
+	value, err := this.Get(key)
+	return value != nil, err
+}
+
+/**
+ * Maps the specified key to the specified value in this map.
+ * Neither the key nor the value can be nil.
+ *
+ * @return the previous value associated with key, or
+ *         nil if there was no mapping for key
+ */
+func (this *ReadMostlyMap) Put(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	hash, err := this.hash(key)
+	if err != nil {
+		return nil, err
+	}
+	if isNil(value) {
+		return nil, NilValueError
+	}
+
+	Printf("ReadMostly Put, %v, %v\n", key, hash)
+	this.write(key, hash, func(old interface{}) (interface{}, bool) {
+		oldVal = old
+		return value, true
+	}, func(seg *Segment) {
+		oldVal = seg.put(key, hash, value, false, nil)
+	})
+	return
+}
+
+/**
+ * If no mapping exists for the key, then maps the specified key to the specified value.
+ * Neither the key nor the value can be nil.
+ *
+ * @return the previous value associated with the specified key,
+ *         or nil if there was no mapping for the key
+ */
+func (this *ReadMostlyMap) PutIfAbsent(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	hash, err := this.hash(key)
+	if err != nil {
+		return nil, err
+	}
+	if isNil(value) {
+		return nil, NilValueError
+	}
+
+	Printf("ReadMostly PutIfAbsent, %v, %v\n", key, hash)
+	this.write(key, hash, func(old interface{}) (interface{}, bool) {
+		oldVal = old
+		return value, old == nil
+	}, func(seg *Segment) {
+		oldVal = seg.put(key, hash, value, true, nil)
+	})
+	return
+}
+
+/**
+ * Copies all of the mappings from the specified map to this one.
+ */
+func (this *ReadMostlyMap) PutAll(m map[interface{}]interface{}) (err error) {
+	if isNil(m) {
+		return IllegalArgError
+	}
+	for k, v := range m {
+		if _, err = this.Put(k, v); err != nil {
+			return
+		}
+	}
+	return
+}
+
+/**
+ * Maps the specified key to the value that be returned by action, see ConcurrentMap.Update.
+ *
+ * Unlike ConcurrentMap, if the key is in read table, action is called without lock
+ * and may be called more than once if the value is changed concurrently.
+ */
+func (this *ReadMostlyMap) Update(key interface{}, action func(oldVal interface{}) (newVal interface{})) (oldVal interface{}, err error) {
+	hash, err := this.hash(key)
+	if err != nil {
+		return nil, err
+	}
+	if action == nil {
+		return nil, NilActionError
+	}
+
+	Printf("ReadMostly Update, %v, %v\n", key, hash)
+	this.write(key, hash, func(old interface{}) (interface{}, bool) {
+		oldVal = old
+		return action(old), true
+	}, func(seg *Segment) {
+		oldVal = seg.put(key, hash, nil, false, action)
+	})
+	return
+}
+
+/**
+ * Removes the key (and its corresponding value) from this map.
+ *
+ * @return the previous value associated with key, or nil if there was no mapping for key
+ */
+func (this *ReadMostlyMap) Remove(key interface{}) (oldVal interface{}, err error) {
+	hash, err := this.hash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	Printf("ReadMostly Remove, %v, %v\n", key, hash)
+	this.write(key, hash, func(old interface{}) (interface{}, bool) {
+		oldVal = old
+		return nil, old != nil
+	}, func(seg *Segment) {
+		oldVal = seg.remove(key, hash, nil)
+	})
+	return
+}
+
+/**
+ * Removes the mapping for the key and value from this map.
+ *
+ * @return true if mapping be removed, false otherwise
+ */
+func (this *ReadMostlyMap) RemoveEntry(key interface{}, value interface{}) (ok bool, err error) {
+	hash, err := this.hash(key)
+	if err != nil {
+		return false, err
+	}
+	if isNil(value) {
+		return false, NilValueError
+	}
+
+	Printf("ReadMostly RemoveEntry, %v, %v\n", key, hash)
+	this.write(key, hash, func(old interface{}) (interface{}, bool) {
+		ok = old != nil && old == value
+		return nil, ok
+	}, func(seg *Segment) {
+		ok = seg.remove(key, hash, value) != nil
+	})
+	return
+}
+
+/**
+ * Replaces the value if the key is in the map.
+ *
+ * @return the previous value associated with the specified key,
+ *         or nil if there was no mapping for the key
+ */
+func (this *ReadMostlyMap) Replace(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	hash, err := this.hash(key)
+	if err != nil {
+		return nil, err
+	}
+	if isNil(value) {
+		return nil, NilValueError
+	}
+
+	Printf("ReadMostly Replace, %v, %v\n", key, hash)
+	this.write(key, hash, func(old interface{}) (interface{}, bool) {
+		oldVal = old
+		return value, old != nil
+	}, func(seg *Segment) {
+		oldVal = seg.replace(key, hash, value)
+	})
+	return
+}
+
+/**
+ * Replaces the value if the key is mapped to oldVal.
+ *
+ * @return true if value be replaced, false otherwise
+ */
+func (this *ReadMostlyMap) CompareAndReplace(key interface{}, oldVal interface{}, newVal interface{}) (ok bool, err error) {
+	hash, err := this.hash(key)
+	if err != nil {
+		return false, err
+	}
+	if isNil(oldVal) || isNil(newVal) {
+		return false, NilValueError
+	}
+
+	Printf("ReadMostly CompareAndReplace, %v, %v\n", key, hash)
+	this.write(key, hash, func(old interface{}) (interface{}, bool) {
+		ok = old != nil && old == oldVal
+		return newVal, ok
+	}, func(seg *Segment) {
+		ok = seg.compareAndReplace(key, hash, oldVal, newVal)
+	})
+	return
+}
+
+/**
+ * Returns the number of key-value mappings in this map.
+ * The result is weakly consistent while the dirty overlay is being merged.
+ */
+func (this *ReadMostlyMap) Size() int32 {
+	return atomic.LoadInt32(&this.count) + atomic.LoadInt32(&this.dirtySegment().count)
+}
+
+/**
+ * Returns true if this map contains no key-value mappings.
+ */
+func (this *ReadMostlyMap) IsEmpty() bool {
+	return this.Size() == 0
+}
+
+/**
+ * Removes all of the mappings from this map.
+ */
+func (this *ReadMostlyMap) Clear() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	for _, e := range this.table().entries {
+		this.update(e, func(old interface{}) (interface{}, bool) {
+			return nil, old != nil
+		})
+	}
+	this.dirty.Clear()
+	this.merge()
+}
+
+//Iterator returns a iterator for ReadMostlyMap
+func (this *ReadMostlyMap) Iterator() *ReadMostlyIterator {
+	itr := &ReadMostlyIterator{m: this}
+	t := this.table()
+	if atomic.LoadInt32(&this.dirtySegment().count) != 0 || this.table() != t {
+		this.lock.Lock()
+		t = this.table()
+		itr.dirty = this.dirtySegment().lockedEntries()
+		this.lock.Unlock()
+	}
+	itr.entries = t.entries
+	itr.advance()
+	return itr
+}
+
+/**
+ * Returns a slice that includes all mappings of this map.
+ */
+func (this *ReadMostlyMap) ToSlice() (kvs []*Entry) {
+	kvs = make([]*Entry, 0, this.Size())
+	for itr := this.Iterator(); itr.HasNext(); {
+		kvs = append(kvs, itr.nextEntry())
+	}
+	return
+}
+
+/**
+ * ReadMostlyIterator iterates the read table and a copy of dirty overlay
+ * that were loaded when the iterator was created, the values of read table
+ * are loaded while iterating, so the result is weakly consistent like MapIterator.
+ */
+type ReadMostlyIterator struct {
+	m            *ReadMostlyMap
+	entries      []*rcuEntry
+	dirty        []Entry
+	index        int
+	nextE        *Entry
+	lastReturned *Entry
+}
+
+func (this *ReadMostlyIterator) advance() {
+	this.nextE = nil
+	for this.index < len(this.entries) {
+		e := this.entries[this.index]
+		this.index++
+		if v, _ := e.load(); v != nil {
+			this.nextE = &Entry{key: e.key, hash: e.hash, value: unsafe.Pointer(&v)}
+			return
+		}
+	}
+	if i := this.index - len(this.entries); i < len(this.dirty) {
+		this.index++
+		this.nextE = &this.dirty[i]
+	}
+}
+
+func (this *ReadMostlyIterator) HasNext() bool {
+	return this.nextE != nil
+}
+
+func (this *ReadMostlyIterator) Next() (key interface{}, value interface{}, ok bool) {
+	if this.nextE == nil {
+		return nil, nil, false
+	}
+	e := this.nextEntry()
+	return e.key, e.fastValue(), true
+}
+
+func (this *ReadMostlyIterator) Remove() (ok bool) {
+	if this.lastReturned == nil {
+		return false
+	}
+	this.m.Remove(this.lastReturned.key)
+	this.lastReturned = nil
+	return true
+}
+
+/**
+ * Returns the next entry, it is the error-returning variant of nextEntry.
+ *
+ * @return IllegalStateError if the iteration has no more entries
+ */
+func (this *ReadMostlyIterator) NextEntry() (e *Entry, err error) {
+	if this.nextE == nil {
+		return nil, IllegalStateError
+	}
+	return this.nextEntry(), nil
+}
+
+/**
+ * Removes the mapping of the entry that was last returned, it is the error-returning variant of Remove.
+ *
+ * @return IllegalStateError if no entry was returned or it was already removed
+ */
+func (this *ReadMostlyIterator) RemoveLastReturned() (err error) {
+	if this.lastReturned == nil {
+		return IllegalStateError
+	}
+	_, err = this.m.Remove(this.lastReturned.key)
+	this.lastReturned = nil
+	return
+}
+
+func (this *ReadMostlyIterator) nextEntry() *Entry {
+	if this.nextE == nil {
+		panic(IllegalStateError)
+	}
+	this.lastReturned = this.nextE
+	this.advance()
+	return this.lastReturned
+}
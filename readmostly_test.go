@@ -0,0 +1,139 @@
+package concurrent
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestReadMostlyMap(t *testing.T) {
+	m := NewReadMostlyMap()
+	n := 1000
+	for i := 0; i < n; i++ {
+		if v, err := m.Put(i, strconv.Itoa(i)); v != nil || err != nil {
+			t.Errorf("Put %v, return %v, %v, want nil, nil", i, v, err)
+		}
+	}
+	if s := m.Size(); s != int32(n) {
+		t.Errorf("Get size, return %v, want %v", s, n)
+	}
+	if len(m.table().entries) == 0 {
+		t.Errorf("The dirty overlay is never merged into read table")
+	}
+
+	for i := 0; i < n; i++ {
+		if v, err := m.Get(i); v != strconv.Itoa(i) || err != nil {
+			t.Errorf("Get %v, return %v, %v, want %v, nil", i, v, err, strconv.Itoa(i))
+		}
+	}
+	if v, err := m.Get(n); v != nil || err != nil {
+		t.Errorf("Get %v, return %v, %v, want nil, nil", n, v, err)
+	}
+	if _, err := m.Get(nil); err != NilKeyError {
+		t.Errorf("Get nil, return %v, want NilKeyError", err)
+	}
+
+	if v, _ := m.PutIfAbsent(1, "x"); v != "1" {
+		t.Errorf("PutIfAbsent 1, return %v, want 1", v)
+	}
+	if ok, _ := m.CompareAndReplace(1, "1", "one"); !ok {
+		t.Errorf("CompareAndReplace 1, return false, want true")
+	}
+	if v, _ := m.Replace(1, "uno"); v != "one" {
+		t.Errorf("Replace 1, return %v, want one", v)
+	}
+	if ok, _ := m.RemoveEntry(1, "one"); ok {
+		t.Errorf("RemoveEntry 1 with wrong value, return true, want false")
+	}
+	if v, _ := m.Remove(1); v != "uno" {
+		t.Errorf("Remove 1, return %v, want uno", v)
+	}
+	if ok, _ := m.ContainsKey(1); ok {
+		t.Errorf("ContainsKey 1 after Remove, return true, want false")
+	}
+	if v, _ := m.PutIfAbsent(1, "1"); v != nil {
+		t.Errorf("PutIfAbsent 1 after Remove, return %v, want nil", v)
+	}
+	m.Update(2, func(old interface{}) interface{} {
+		return old.(string) + "!"
+	})
+	if v, _ := m.Get(2); v != "2!" {
+		t.Errorf("Get 2 after Update, return %v, want 2!", v)
+	}
+
+	count := 0
+	for itr := m.Iterator(); itr.HasNext(); {
+		itr.Next()
+		count++
+	}
+	if count != n || len(m.ToSlice()) != n {
+		t.Errorf("Iterate map, return %v mappings, want %v", count, n)
+	}
+
+	m.Clear()
+	if s := m.Size(); s != 0 || len(m.table().entries) != 0 {
+		t.Errorf("Get size after Clear, return %v, want 0", s)
+	}
+	if v, _ := m.Get(2); v != nil {
+		t.Errorf("Get 2 after Clear, return %v, want nil", v)
+	}
+}
+
+func TestReadMostlyMapConcurrent(t *testing.T) {
+	m := NewReadMostlyMap()
+	n := 1000
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				m.Put(i, i)
+				m.Get(i)
+				if i%2 == g%2 {
+					m.Remove(i)
+				}
+				m.PutIfAbsent(i, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if s := m.Size(); s != int32(n) {
+		t.Errorf("Get size, return %v, want %v", s, n)
+	}
+	for i := 0; i < n; i++ {
+		if v, _ := m.Get(i); v != i {
+			t.Errorf("Get %v, return %v, want %v", i, v, i)
+		}
+	}
+}
+
+func TestReadMostlyIteratorErrors(t *testing.T) {
+	m := NewReadMostlyMap()
+	m.Put(1, 10)
+	itr := m.Iterator()
+	if e, err := itr.NextEntry(); err != nil || e.Key() != 1 {
+		t.Errorf("NextEntry, return %v, %v, want entry of 1", e, err)
+	}
+	if _, err := itr.NextEntry(); err != IllegalStateError {
+		t.Errorf("NextEntry after the end, return %v, want IllegalStateError", err)
+	}
+	if err := itr.RemoveLastReturned(); err != nil || m.Size() != 0 {
+		t.Errorf("RemoveLastReturned, return %v, Size is %v, want nil, 0", err, m.Size())
+	}
+	if err := itr.RemoveLastReturned(); err != IllegalStateError {
+		t.Errorf("RemoveLastReturned twice, return %v, want IllegalStateError", err)
+	}
+}
+
+func TestReadMostlyGetOrDefault(t *testing.T) {
+	m := NewReadMostlyMap()
+	m.Put(1, 10)
+	if v, err := m.GetOrDefault(1, 0); v != 10 || err != nil {
+		t.Errorf("GetOrDefault present key, return %v, %v, want 10, nil", v, err)
+	}
+	if v, _ := m.GetOrDefault(2, -1); v != -1 || m.Size() != 1 {
+		t.Errorf("GetOrDefault absent key, return %v, Size is %v, want -1, 1", v, m.Size())
+	}
+}
@@ -0,0 +1,108 @@
+package concurrent
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	DuplicateNameError = errors.New("DuplicateNameException")
+	NameMismatchError  = errors.New("NameMismatchException")
+)
+
+//registry is the package-level registry of named maps, see Register
+var registry = struct {
+	lock sync.Mutex
+	maps map[string]*ConcurrentMap
+}{maps: make(map[string]*ConcurrentMap)}
+
+/**
+ * Registers the map by name in the package-level registry, so the maps of a process
+ * can be looked up by Lookup and be monitored by RegisteredStats.
+ * The registry is optional, the maps that are not registered work as usual.
+ *
+ * @param name the name to register the map by, "" means the name set by WithName
+ * @return DuplicateNameError if a map has been registered by the name,
+ *         NameMismatchError if the map is named by WithName and the name is different
+ */
+func Register(name string, m *ConcurrentMap) (err error) {
+	if m == nil {
+		panic(IllegalArgError)
+	}
+	if name == "" {
+		name = m.Name()
+	} else if m.Name() != "" && m.Name() != name {
+		return NameMismatchError
+	}
+	if name == "" {
+		panic(IllegalArgError)
+	}
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if _, ok := registry.maps[name]; ok {
+		return DuplicateNameError
+	}
+	registry.maps[name] = m
+	return
+}
+
+/**
+ * Removes the map registered by name, e.g. when the map is closed.
+ *
+ * @return true if a map was registered by name
+ */
+func Unregister(name string) (ok bool) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if _, ok = registry.maps[name]; ok {
+		delete(registry.maps, name)
+	}
+	return
+}
+
+/**
+ * Returns the map registered by name, or nil if no map is registered by name.
+ */
+func Lookup(name string) *ConcurrentMap {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	return registry.maps[name]
+}
+
+/**
+ * MapStats is the stats of a registered map.
+ */
+type MapStats struct {
+	//the name that the map was registered by
+	Name string
+	Size int32
+	//the latency histograms, nil if WithLatencyStats isn't enabled for the map
+	Latency *Stats
+}
+
+/**
+ * Returns the stats of all registered maps in the order of names.
+ */
+func RegisteredStats() []MapStats {
+	registry.lock.Lock()
+	all := make([]MapStats, 0, len(registry.maps))
+	maps := make([]*ConcurrentMap, 0, len(registry.maps))
+	for name, m := range registry.maps {
+		all = append(all, MapStats{Name: name})
+		maps = append(maps, m)
+	}
+	registry.lock.Unlock()
+
+	//the maps are read without the registry lock
+	for i, m := range maps {
+		all[i].Size = m.Size()
+		if stats, err := m.Stats(); err == nil {
+			all[i].Latency = &stats
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
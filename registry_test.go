@@ -0,0 +1,59 @@
+package concurrent
+
+import (
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	sessions, users := NewConcurrentMap(WithLatencyStats()), NewConcurrentMap()
+	defer Unregister("test.sessions")
+	defer Unregister("test.users")
+
+	if err := Register("test.sessions", sessions); err != nil {
+		t.Errorf("Register, return %v, want nil", err)
+	}
+	if err := Register("test.sessions", users); err != DuplicateNameError {
+		t.Errorf("Register duplicate name, return %v, want DuplicateNameError", err)
+	}
+	Register("test.users", users)
+	if Lookup("test.sessions") != sessions || Lookup("test.none") != nil {
+		t.Errorf("Lookup, return the wrong map")
+	}
+
+	sessions.Put(1, 1)
+	sessions.Get(1)
+	users.Put(1, 1)
+	users.Put(2, 2)
+	found := 0
+	for _, s := range RegisteredStats() {
+		switch s.Name {
+		case "test.sessions":
+			found++
+			if s.Size != 1 || s.Latency == nil || s.Latency.Get.Count != 1 {
+				t.Errorf("RegisteredStats of sessions, return %v, want Size 1 and 1 Get", s)
+			}
+		case "test.users":
+			found++
+			if s.Size != 2 || s.Latency != nil {
+				t.Errorf("RegisteredStats of users, return %v, want Size 2 and nil Latency", s)
+			}
+		}
+	}
+	if found != 2 {
+		t.Errorf("RegisteredStats, return %v registered maps, want 2", found)
+	}
+
+	if !Unregister("test.users") || Unregister("test.users") || Lookup("test.users") != nil {
+		t.Errorf("Unregister, the map is still registered")
+	}
+
+	//the name of WithName is used by default
+	orders := NewConcurrentMap(WithName("test.orders"))
+	defer Unregister("test.orders")
+	if err := Register("", orders); err != nil || Lookup("test.orders") != orders {
+		t.Errorf("Register by the name of map, return %v, want nil", err)
+	}
+	if err := Register("test.others", orders); err != NameMismatchError {
+		t.Errorf("Register by other name, return %v, want NameMismatchError", err)
+	}
+}
@@ -0,0 +1,122 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ * RemoteError is returned by Client if the service returns a failure.
+ */
+type RemoteError struct {
+	StatusCode int
+	Message    string
+}
+
+func (this *RemoteError) Error() string {
+	return fmt.Sprintf("remote map: %v %v", this.StatusCode, this.Message)
+}
+
+/**
+ * Client calls the service of a Handler.
+ */
+type Client struct {
+	baseURL string
+	hc      *http.Client
+}
+
+/**
+ * Creates a Client for the service at baseURL, e.g. "http://127.0.0.1:8080".
+ * If hc is nil, http.DefaultClient is used.
+ */
+func NewClient(baseURL string, hc *http.Client) *Client {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Client{strings.TrimRight(baseURL, "/"), hc}
+}
+
+//do sends the request and decodes the response into out, out isn't changed if no mapping for key
+func (this *Client) do(method string, path string, in interface{}, out interface{}) (err error) {
+	var body bytes.Buffer
+	if in != nil {
+		if err = json.NewEncoder(&body).Encode(in); err != nil {
+			return
+		}
+	}
+	req, err := http.NewRequest(method, this.baseURL+path, &body)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := this.hc.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && strings.HasPrefix(path, entriesPath) {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e errorBody
+		json.NewDecoder(resp.Body).Decode(&e)
+		return &RemoteError{resp.StatusCode, e.Error}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+/**
+ * Returns the value of key, or nil if no mapping for key.
+ */
+func (this *Client) Get(key string) (value interface{}, err error) {
+	var body valueBody
+	err = this.do(http.MethodGet, entriesPath+url.PathEscape(key), nil, &body)
+	return body.Value, err
+}
+
+/**
+ * Maps key to value, the value is encoded by json.Marshal.
+ *
+ * @return the previous value, or nil if no mapping for key
+ */
+func (this *Client) Put(key string, value interface{}) (oldVal interface{}, err error) {
+	return this.PutWithTTL(key, value, 0)
+}
+
+/**
+ * Maps key to value like Put, and the mapping expires after ttl if ttl isn't less than 1 millisecond.
+ */
+func (this *Client) PutWithTTL(key string, value interface{}, ttl time.Duration) (oldVal interface{}, err error) {
+	var body valueBody
+	err = this.do(http.MethodPut, entriesPath+url.PathEscape(key), valueBody{value, int64(ttl / time.Millisecond)}, &body)
+	return body.Value, err
+}
+
+/**
+ * Removes the mapping for key.
+ *
+ * @return the previous value, or nil if no mapping for key
+ */
+func (this *Client) Remove(key string) (oldVal interface{}, err error) {
+	var body valueBody
+	err = this.do(http.MethodDelete, entriesPath+url.PathEscape(key), nil, &body)
+	return body.Value, err
+}
+
+/**
+ * Returns at most count mappings whose keys are after cursor in the order of keys.
+ * Scans from the start if cursor is empty, and the scan is finished if next is empty.
+ */
+func (this *Client) Scan(cursor string, count int) (entries []ScanEntry, next string, err error) {
+	query := url.Values{"cursor": {cursor}, "count": {strconv.Itoa(count)}}
+	var body scanBody
+	err = this.do(http.MethodGet, scanPath+"?"+query.Encode(), nil, &body)
+	return body.Entries, body.Cursor, err
+}
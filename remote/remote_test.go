@@ -0,0 +1,106 @@
+package remote
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	concurrent "github.com/fanliao/go-concurrentMap"
+)
+
+func TestRemote(t *testing.T) {
+	m := concurrent.NewConcurrentMap()
+	s := httptest.NewServer(NewHandler(m))
+	defer s.Close()
+	c := NewClient(s.URL+"/", nil)
+
+	if v, err := c.Get("a/b c"); v != nil || err != nil {
+		t.Errorf("Get absent key, return %v, %v, want nil, nil", v, err)
+	}
+	if old, err := c.Put("a/b c", map[string]interface{}{"n": 1}); old != nil || err != nil {
+		t.Errorf("Put, return %v, %v, want nil, nil", old, err)
+	}
+	if v, _ := m.Get("a/b c"); v == nil || v.(map[string]interface{})["n"] != 1.0 {
+		t.Errorf("Get from served map, return %v, want {n: 1}", v)
+	}
+	if old, _ := c.Put("a/b c", "v"); old == nil {
+		t.Errorf("Put existing key, return nil, want the previous value")
+	}
+	if v, err := c.Get("a/b c"); v != "v" || err != nil {
+		t.Errorf("Get, return %v, %v, want v", v, err)
+	}
+	if old, err := c.Remove("a/b c"); old != "v" || err != nil {
+		t.Errorf("Remove, return %v, %v, want v", old, err)
+	}
+
+	c.PutWithTTL("ttl", 1, 10*time.Second)
+	if remaining, ok, _ := m.TTL("ttl"); !ok || remaining <= 0 || remaining > 10*time.Second {
+		t.Errorf("TTL after PutWithTTL, return %v, %v, want about 10s", remaining, ok)
+	}
+
+	if _, err := c.Put("nil", nil); err == nil {
+		t.Errorf("Put nil value, return nil error, want RemoteError")
+	} else if re, ok := err.(*RemoteError); !ok || re.StatusCode != 400 {
+		t.Errorf("Put nil value, return %v, want RemoteError with 400", err)
+	}
+
+	m.Clear()
+	for i := 0; i < 25; i++ {
+		m.Put(string(rune('a'+i)), i)
+	}
+	m.Put(1, "non-string keys are not scanned")
+	seen := map[string]bool{}
+	cursor := ""
+	for {
+		entries, next, err := c.Scan(cursor, 10)
+		if err != nil {
+			t.Fatalf("Scan, return error %v", err)
+		}
+		for _, e := range entries {
+			if seen[e.Key] {
+				t.Errorf("Scan, return %v twice", e.Key)
+			}
+			seen[e.Key] = true
+		}
+		if cursor = next; cursor == "" {
+			break
+		}
+	}
+	if len(seen) != 25 {
+		t.Errorf("Scan, return %v keys, want 25", len(seen))
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	m := concurrent.NewConcurrentMap(concurrent.WithLatencyStats())
+	concurrent.Register("remote.test", m)
+	defer concurrent.Unregister("remote.test")
+	m.Put("a", 1)
+	m.Get("a")
+
+	s := httptest.NewServer(NewStatsHandler())
+	defer s.Close()
+	resp, err := http.Get(s.URL)
+	if err != nil {
+		t.Fatalf("GET stats, return %v", err)
+	}
+	defer resp.Body.Close()
+	var bodies []mapStatsBody
+	if err := json.NewDecoder(resp.Body).Decode(&bodies); err != nil {
+		t.Fatalf("decode stats, return %v", err)
+	}
+	found := false
+	for _, b := range bodies {
+		if b.Name == "remote.test" {
+			found = true
+			if b.Size != 1 || b.Get == nil || b.Get.Count != 1 || b.Put.Count != 1 {
+				t.Errorf("stats of remote.test, return %+v, want size 1, 1 get and 1 put", b)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("stats, return %+v, want remote.test", bodies)
+	}
+}
@@ -0,0 +1,184 @@
+/**
+ * Package remote exposes Get, Put, Remove and Scan of a ConcurrentMap over HTTP with JSON bodies,
+ * so a sidecar-style shared cache can be built purely from this package.
+ *
+ * The service is defined as:
+ *
+ *	GET    /v1/entries/{key}                  -> 200 {"value": v}, or 404 if no mapping
+ *	PUT    /v1/entries/{key}  {"value": v, "ttl_ms": n} -> 200 {"value": old}
+ *	DELETE /v1/entries/{key}                  -> 200 {"value": old}
+ *	GET    /v1/scan?cursor=c&count=n          -> 200 {"entries": [{"key": k, "value": v}], "cursor": next}
+ *
+ * The key is escaped in path, the value is any JSON value, and ttl_ms is optional.
+ * The failures return a non-2xx status with {"error": message}.
+ * The keys are the string keys of map, and the values are stored as decoded by
+ * json.Unmarshal into interface{}, e.g. a number is float64.
+ *
+ * StatsHandler serves the aggregate stats of the maps registered by concurrent.Register.
+ */
+package remote
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	concurrent "github.com/fanliao/go-concurrentMap"
+)
+
+const (
+	entriesPath = "/v1/entries/"
+	scanPath    = "/v1/scan"
+	//the default and max number of entries returned by a scan
+	defaultScanCount = 100
+	maxScanCount     = 10000
+	//the max size of request body
+	maxBodySize = 32 << 20
+)
+
+//valueBody is the body of Get, Put and Remove
+type valueBody struct {
+	Value interface{} `json:"value"`
+	TTL   int64       `json:"ttl_ms,omitempty"`
+}
+
+/**
+ * ScanEntry is a mapping returned by scan.
+ */
+type ScanEntry struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+type scanBody struct {
+	Entries []ScanEntry `json:"entries"`
+	Cursor  string      `json:"cursor"`
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+/**
+ * Handler serves a ConcurrentMap by the service of package doc.
+ */
+type Handler struct {
+	m *concurrent.ConcurrentMap
+}
+
+/**
+ * Creates a Handler that serves m, it can be mounted on any http.ServeMux or http.Server.
+ */
+func NewHandler(m *concurrent.ConcurrentMap) *Handler {
+	if m == nil {
+		panic(concurrent.IllegalArgError)
+	}
+	return &Handler{m}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorBody{err.Error()})
+}
+
+func (this *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+	switch {
+	case strings.HasPrefix(path, entriesPath):
+		key, err := url.PathUnescape(path[len(entriesPath):])
+		if err != nil || key == "" {
+			writeError(w, http.StatusBadRequest, concurrent.IllegalArgError)
+			return
+		}
+		this.serveEntry(w, r, key)
+	case path == scanPath && r.Method == http.MethodGet:
+		this.serveScan(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (this *Handler) serveEntry(w http.ResponseWriter, r *http.Request, key string) {
+	var v interface{}
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		if v, err = this.m.Get(key); err == nil && v == nil {
+			writeJSON(w, http.StatusNotFound, errorBody{"no mapping for key"})
+			return
+		}
+	case http.MethodPut:
+		var body valueBody
+		if err = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if body.Value == nil {
+			writeError(w, http.StatusBadRequest, concurrent.NilValueError)
+			return
+		}
+		if body.TTL > 0 {
+			v, err = this.m.PutWithTTL(key, body.Value, time.Duration(body.TTL)*time.Millisecond)
+		} else {
+			v, err = this.m.Put(key, body.Value)
+		}
+	case http.MethodDelete:
+		v, err = this.m.Remove(key)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, errorBody{"method not allowed"})
+		return
+	}
+
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, valueBody{Value: v})
+}
+
+/**
+ * Returns the mappings in the order of keys, the cursor is the last returned key,
+ * so every key that is in map during the whole scan is returned once.
+ */
+func (this *Handler) serveScan(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	cursor := query.Get("cursor")
+	count := defaultScanCount
+	if s := query.Get("count"); s != "" {
+		var err error
+		if count, err = strconv.Atoi(s); err != nil || count <= 0 {
+			writeError(w, http.StatusBadRequest, concurrent.IllegalArgError)
+			return
+		}
+		if count > maxScanCount {
+			count = maxScanCount
+		}
+	}
+
+	entries := make([]ScanEntry, 0)
+	for _, e := range this.m.ToSlice() {
+		if key, ok := e.Key().(string); ok && key > cursor {
+			entries = append(entries, ScanEntry{key, e.Value()})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	body := scanBody{Entries: entries}
+	if len(entries) > count {
+		body.Entries = entries[:count]
+		body.Cursor = entries[count-1].Key
+	}
+	writeJSON(w, http.StatusOK, body)
+}
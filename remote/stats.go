@@ -0,0 +1,61 @@
+package remote
+
+import (
+	"net/http"
+
+	concurrent "github.com/fanliao/go-concurrentMap"
+)
+
+//latencyBody is the summary of a concurrent.LatencyStats
+type latencyBody struct {
+	Count int64 `json:"count"`
+	Mean  int64 `json:"mean_ns"`
+	P50   int64 `json:"p50_ns"`
+	P99   int64 `json:"p99_ns"`
+}
+
+type mapStatsBody struct {
+	Name   string       `json:"name"`
+	Size   int32        `json:"size"`
+	Get    *latencyBody `json:"get,omitempty"`
+	Put    *latencyBody `json:"put,omitempty"`
+	Remove *latencyBody `json:"remove,omitempty"`
+}
+
+func summarize(s *concurrent.LatencyStats) *latencyBody {
+	return &latencyBody{s.Count, int64(s.Mean()), int64(s.Quantile(0.5)), int64(s.Quantile(0.99))}
+}
+
+/**
+ * StatsHandler serves the aggregate stats of all maps in the registry of package concurrent,
+ * see concurrent.Register. GET returns 200 with a JSON array in the order of names:
+ *
+ *	[{"name": n, "size": s, "get": {"count": c, "mean_ns": m, "p50_ns": p, "p99_ns": p}, "put": ..., "remove": ...}]
+ *
+ * The latencies are omitted for the maps that don't enable WithLatencyStats.
+ */
+type StatsHandler struct{}
+
+/**
+ * Creates a StatsHandler, it can be mounted on any http.ServeMux, e.g. at /debug/maps.
+ */
+func NewStatsHandler() *StatsHandler {
+	return &StatsHandler{}
+}
+
+func (this *StatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeJSON(w, http.StatusMethodNotAllowed, errorBody{"method not allowed"})
+		return
+	}
+	all := concurrent.RegisteredStats()
+	bodies := make([]mapStatsBody, len(all))
+	for i, s := range all {
+		bodies[i] = mapStatsBody{Name: s.Name, Size: s.Size}
+		if l := s.Latency; l != nil {
+			bodies[i].Get, bodies[i].Put, bodies[i].Remove = summarize(&l.Get), summarize(&l.Put), summarize(&l.Remove)
+		}
+	}
+	writeJSON(w, http.StatusOK, bodies)
+}
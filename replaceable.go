@@ -0,0 +1,106 @@
+package concurrent
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+/**
+ * Replaceable is a Map that delegates to a map that can be swapped atomically,
+ * so a freshly built map can replace the live one without copying the mappings,
+ * and the readers never see an empty or partially built map.
+ *
+ * Every operation is delegated to the map that is current when it starts,
+ * so the operations that started before Swap may complete on the old map after Swap returns.
+ * The users must not write the old map after swapped if the writes should not be lost.
+ */
+type Replaceable struct {
+	m unsafe.Pointer //*Map, atomic
+}
+
+/**
+ * Creates a Replaceable that delegates to m.
+ */
+func NewReplaceable(m Map) *Replaceable {
+	if m == nil {
+		panic(IllegalArgError)
+	}
+	return &Replaceable{m: unsafe.Pointer(&m)}
+}
+
+var _ Map = (*Replaceable)(nil)
+
+/**
+ * Returns the current map.
+ */
+func (this *Replaceable) Load() Map {
+	return *(*Map)(atomic.LoadPointer(&this.m))
+}
+
+/**
+ * Atomically replaces the current map by m.
+ *
+ * @return the previous map
+ */
+func (this *Replaceable) Swap(m Map) (old Map) {
+	if m == nil {
+		panic(IllegalArgError)
+	}
+	return *(*Map)(atomic.SwapPointer(&this.m, unsafe.Pointer(&m)))
+}
+
+func (this *Replaceable) Get(key interface{}) (value interface{}, err error) {
+	return this.Load().Get(key)
+}
+
+func (this *Replaceable) ContainsKey(key interface{}) (found bool, err error) {
+	return this.Load().ContainsKey(key)
+}
+
+func (this *Replaceable) Put(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	return this.Load().Put(key, value)
+}
+
+func (this *Replaceable) PutIfAbsent(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	return this.Load().PutIfAbsent(key, value)
+}
+
+func (this *Replaceable) PutAll(m map[interface{}]interface{}) (err error) {
+	return this.Load().PutAll(m)
+}
+
+func (this *Replaceable) Update(key interface{}, action func(oldVal interface{}) (newVal interface{})) (oldVal interface{}, err error) {
+	return this.Load().Update(key, action)
+}
+
+func (this *Replaceable) Remove(key interface{}) (oldVal interface{}, err error) {
+	return this.Load().Remove(key)
+}
+
+func (this *Replaceable) RemoveEntry(key interface{}, value interface{}) (ok bool, err error) {
+	return this.Load().RemoveEntry(key, value)
+}
+
+func (this *Replaceable) Replace(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	return this.Load().Replace(key, value)
+}
+
+func (this *Replaceable) CompareAndReplace(key interface{}, oldVal interface{}, newVal interface{}) (ok bool, err error) {
+	return this.Load().CompareAndReplace(key, oldVal, newVal)
+}
+
+func (this *Replaceable) Size() int32 {
+	return this.Load().Size()
+}
+
+func (this *Replaceable) IsEmpty() bool {
+	return this.Load().IsEmpty()
+}
+
+func (this *Replaceable) Clear() {
+	this.Load().Clear()
+}
+
+func (this *Replaceable) ToSlice() (kvs []*Entry) {
+	return this.Load().ToSlice()
+}
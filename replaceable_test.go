@@ -0,0 +1,52 @@
+package concurrent
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReplaceable(t *testing.T) {
+	live := NewConcurrentMap()
+	for i := 0; i < 100; i++ {
+		live.Put(i, "old")
+	}
+	r := NewReplaceable(live)
+
+	var stopped, failed int32
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&stopped) == 0 {
+				for i := 0; i < 100; i++ {
+					if v, _ := r.Get(i); v == nil {
+						atomic.StoreInt32(&failed, 1)
+					}
+				}
+			}
+		}()
+	}
+
+	fresh := NewConcurrentMap()
+	for i := 0; i < 100; i++ {
+		fresh.Put(i, "new")
+	}
+	if old := r.Swap(fresh); old != Map(live) {
+		t.Errorf("Swap, return %v, want the live map", old)
+	}
+	atomic.StoreInt32(&stopped, 1)
+	wg.Wait()
+
+	if atomic.LoadInt32(&failed) != 0 {
+		t.Errorf("Get while swapping, return nil, want a value of old or new map")
+	}
+	if v, _ := r.Get(1); v != "new" || r.Load() != Map(fresh) || r.Size() != 100 {
+		t.Errorf("Get after Swap, return %v, want new", v)
+	}
+	r.Put(100, "new")
+	if v, _ := fresh.Get(100); v != "new" {
+		t.Errorf("Get from fresh map after Put, return %v, want new", v)
+	}
+}
@@ -0,0 +1,60 @@
+package concurrent
+
+/**
+ * ReplaceTuple is an operation of CompareAndReplaceAll,
+ * the value of Key is replaced by NewVal if it is mapped to OldVal.
+ */
+type ReplaceTuple struct {
+	Key    interface{}
+	OldVal interface{}
+	NewVal interface{}
+}
+
+/**
+ * Executes the compare-and-replace operation for every tuple,
+ * it is used by the reconciliation loops that sync this map against an external source.
+ *
+ * The tuples are grouped by segment and every segment is locked only once,
+ * the tuples in a segment are applied in their order, so a later tuple can
+ * compare with the NewVal of an earlier tuple of same key.
+ * The tuples in different segments are not applied atomically.
+ *
+ * @return the results in the order of tuples, true if the value of tuple was replaced.
+ * Nothing is replaced if any key or value is nil, any key is not supported,
+ * or any new value is rejected by the validator.
+ */
+func (this *ConcurrentMap) CompareAndReplaceAll(tuples []ReplaceTuple) (replaced []bool, err error) {
+	keys := make([]interface{}, len(tuples))
+	for i, t := range tuples {
+		if isNil(t.OldVal) || isNil(t.NewVal) {
+			return nil, NilValueError
+		}
+		if err = this.validate(t.Key, t.NewVal); err != nil {
+			return nil, err
+		}
+		keys[i] = t.Key
+	}
+	hashes, groups, err := this.groupBySegment(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	replaced = make([]bool, len(tuples))
+	for i, group := range groups {
+		if len(group) > 0 {
+			this.segments[i].compareAndReplaceAll(tuples, hashes, group, replaced)
+		}
+	}
+	return
+}
+
+func (this *Segment) compareAndReplaceAll(tuples []ReplaceTuple, hashes []uint32, indexes []int, replaced []bool) {
+	this.acquire()
+	defer this.lock.Unlock()
+
+	for _, i := range indexes {
+		t := tuples[i]
+		Printf("CompareAndReplaceAll, %v, %v\n", t.Key, hashes[i])
+		replaced[i] = this.compareAndReplaceUnderLock(t.Key, hashes[i], t.OldVal, t.NewVal)
+	}
+}
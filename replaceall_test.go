@@ -0,0 +1,56 @@
+package concurrent
+
+import (
+	"testing"
+)
+
+func TestCompareAndReplaceAll(t *testing.T) {
+	cm := NewConcurrentMap()
+	for i := 0; i < 100; i++ {
+		cm.Put(i, i)
+	}
+
+	tuples := make([]ReplaceTuple, 0, 102)
+	for i := 0; i < 100; i++ {
+		old := i
+		if i%2 == 1 {
+			old = -1
+		}
+		tuples = append(tuples, ReplaceTuple{i, old, i * 10})
+	}
+	//compares with the new value of earlier tuple
+	tuples = append(tuples, ReplaceTuple{2, 20, 21}, ReplaceTuple{200, 0, 1})
+
+	replaced, err := cm.CompareAndReplaceAll(tuples)
+	if err != nil {
+		t.Errorf("CompareAndReplaceAll, return %v, want nil", err)
+	}
+	for i := 0; i < 100; i++ {
+		want, wantVal := i%2 == 0, i
+		if want {
+			wantVal = i * 10
+		}
+		if i == 2 {
+			wantVal = 21
+		}
+		if replaced[i] != want {
+			t.Errorf("CompareAndReplaceAll %v, return %v, want %v", i, replaced[i], want)
+		}
+		if v, _ := cm.Get(i); v != wantVal {
+			t.Errorf("Get %v after CompareAndReplaceAll, return %v, want %v", i, v, wantVal)
+		}
+	}
+	if !replaced[100] || replaced[101] {
+		t.Errorf("CompareAndReplaceAll last tuples, return %v, want [true false]", replaced[100:])
+	}
+	if v, _ := cm.Get(200); v != nil {
+		t.Errorf("Get 200 after CompareAndReplaceAll, return %v, want nil", v)
+	}
+
+	if _, err := cm.CompareAndReplaceAll([]ReplaceTuple{{1, 1, 2}, {nil, 1, 2}}); err != NilKeyError {
+		t.Errorf("CompareAndReplaceAll with nil key, return %v, want NilKeyError", err)
+	}
+	if _, err := cm.CompareAndReplaceAll([]ReplaceTuple{{1, 10, nil}}); err != NilValueError {
+		t.Errorf("CompareAndReplaceAll with nil value, return %v, want NilValueError", err)
+	}
+}
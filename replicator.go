@@ -0,0 +1,242 @@
+package concurrent
+
+import (
+	"time"
+)
+
+/**
+ * MutationOp is the operation of a Mutation.
+ */
+type MutationOp int
+
+const (
+	MUTATION_PUT MutationOp = iota
+	MUTATION_PUT_IF_ABSENT
+	MUTATION_REMOVE
+	MUTATION_REMOVE_ENTRY
+	MUTATION_REPLACE
+	MUTATION_COMPARE_AND_REPLACE
+	MUTATION_PUT_ALL
+	MUTATION_CLEAR
+)
+
+/**
+ * Mutation is a write operation of ReplicatedMap, it is proposed to the consensus layer
+ * and applied to every replica in the committed order. The keys and values must be
+ * serializable by the consensus layer.
+ */
+type Mutation struct {
+	Op    MutationOp
+	Key   interface{}
+	Value interface{}
+	//the expected value of MUTATION_COMPARE_AND_REPLACE
+	OldValue interface{}
+	//the unix time in nanoseconds that the mapping put by MUTATION_PUT expires, 0 means never expire,
+	//it is absolute so the replicas expire the mapping at the same time
+	ExpireAt int64
+	//the mappings of MUTATION_PUT_ALL
+	All map[interface{}]interface{}
+}
+
+/**
+ * Replicator places the map behind the consensus layer of users, e.g. Raft.
+ *
+ * Propose submits the mutation to the consensus layer and waits until it is committed,
+ * then the consensus layer must call ReplicatedMap.Apply with the committed mutations
+ * in the same order on every replica. Propose returns the result of Apply on this replica,
+ * e.g. by the response of apply future of Raft.
+ */
+type Replicator interface {
+	Propose(mutation *Mutation) (result interface{}, err error)
+}
+
+/**
+ * ReplicatedMap is a Map whose writes are proposed to a Replicator,
+ * so the replicas are kept identical. The reads are served by the local map.
+ *
+ * The local map must not be written except by Apply, and the expirations and evictions are
+ * local policies, they should be disabled or be deterministic on all replicas.
+ * Update cannot be replicated because its action cannot be serialized.
+ */
+type ReplicatedMap struct {
+	m *ConcurrentMap
+	r Replicator
+}
+
+/**
+ * Creates a ReplicatedMap that proposes the writes to r and reads m.
+ */
+func NewReplicatedMap(m *ConcurrentMap, r Replicator) *ReplicatedMap {
+	if m == nil || r == nil {
+		panic(IllegalArgError)
+	}
+	return &ReplicatedMap{m, r}
+}
+
+var _ Map = (*ReplicatedMap)(nil)
+
+/**
+ * Applies a committed mutation to the local map, it must be called by the consensus layer
+ * in the committed order.
+ *
+ * @return the result of the operation, i.e. the previous value, or the bool of
+ *         MUTATION_REMOVE_ENTRY and MUTATION_COMPARE_AND_REPLACE
+ */
+func (this *ReplicatedMap) Apply(mutation *Mutation) (result interface{}, err error) {
+	m := this.m
+	switch mutation.Op {
+	case MUTATION_PUT:
+		if mutation.ExpireAt == 0 {
+			return m.Put(mutation.Key, mutation.Value)
+		}
+		return m.putWithExpireAt(mutation.Key, mutation.Value, mutation.ExpireAt)
+	case MUTATION_PUT_IF_ABSENT:
+		return m.PutIfAbsent(mutation.Key, mutation.Value)
+	case MUTATION_REMOVE:
+		return m.Remove(mutation.Key)
+	case MUTATION_REMOVE_ENTRY:
+		return m.RemoveEntry(mutation.Key, mutation.Value)
+	case MUTATION_REPLACE:
+		return m.Replace(mutation.Key, mutation.Value)
+	case MUTATION_COMPARE_AND_REPLACE:
+		return m.CompareAndReplace(mutation.Key, mutation.OldValue, mutation.Value)
+	case MUTATION_PUT_ALL:
+		return nil, m.PutAll(mutation.All)
+	case MUTATION_CLEAR:
+		m.Clear()
+		return nil, nil
+	}
+	return nil, IllegalArgError
+}
+
+//putWithExpireAt is same as PutWithTTL, but the expiration time is absolute
+func (this *ConcurrentMap) putWithExpireAt(key interface{}, value interface{}, expireAt int64) (oldVal interface{}, err error) {
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+	if isNil(value) {
+		return nil, NilValueError
+	}
+	if e := this.validate(key, value); e != nil {
+		return nil, e
+	}
+
+	if hash, e := hashKey(key, this, false); e != nil {
+		err = e
+	} else {
+		Printf("putWithExpireAt, %v, %v, %v\n", key, hash, expireAt)
+		oldVal = this.segmentFor(hash).putWithExpiration(key, hash, value, false, nil, expireAt)
+		this.scheduleExpiration(key, hash, expireAt)
+	}
+	return
+}
+
+//propose validates the key and value like the local map, so the invalid mutations are not replicated
+func (this *ReplicatedMap) propose(mutation *Mutation, checkValue bool) (result interface{}, err error) {
+	if isNil(mutation.Key) {
+		return nil, NilKeyError
+	}
+	if checkValue && isNil(mutation.Value) {
+		return nil, NilValueError
+	}
+	if _, err = hashKey(mutation.Key, this.m, false); err != nil {
+		return
+	}
+	return this.r.Propose(mutation)
+}
+
+func (this *ReplicatedMap) proposeOk(mutation *Mutation, checkValue bool) (ok bool, err error) {
+	result, err := this.propose(mutation, checkValue)
+	if err == nil {
+		ok, _ = result.(bool)
+	}
+	return
+}
+
+func (this *ReplicatedMap) Get(key interface{}) (value interface{}, err error) {
+	return this.m.Get(key)
+}
+
+func (this *ReplicatedMap) ContainsKey(key interface{}) (found bool, err error) {
+	return this.m.ContainsKey(key)
+}
+
+func (this *ReplicatedMap) Put(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	return this.propose(&Mutation{Op: MUTATION_PUT, Key: key, Value: value}, true)
+}
+
+/**
+ * Same as Put, and the mapping expires after ttl on every replica, see PutWithTTL of ConcurrentMap.
+ */
+func (this *ReplicatedMap) PutWithTTL(key interface{}, value interface{}, ttl time.Duration) (oldVal interface{}, err error) {
+	var expireAt int64
+	if ttl > 0 {
+		expireAt = time.Now().Add(this.m.jitter(ttl)).UnixNano()
+	}
+	return this.propose(&Mutation{Op: MUTATION_PUT, Key: key, Value: value, ExpireAt: expireAt}, true)
+}
+
+func (this *ReplicatedMap) PutIfAbsent(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	return this.propose(&Mutation{Op: MUTATION_PUT_IF_ABSENT, Key: key, Value: value}, true)
+}
+
+func (this *ReplicatedMap) PutAll(m map[interface{}]interface{}) (err error) {
+	for k, v := range m {
+		if isNil(k) {
+			return NilKeyError
+		}
+		if isNil(v) {
+			return NilValueError
+		}
+		if _, err = hashKey(k, this.m, false); err != nil {
+			return
+		}
+	}
+	_, err = this.r.Propose(&Mutation{Op: MUTATION_PUT_ALL, All: m})
+	return
+}
+
+/**
+ * Update cannot be replicated, it always returns IllegalStateError.
+ */
+func (this *ReplicatedMap) Update(key interface{}, action func(oldVal interface{}) (newVal interface{})) (oldVal interface{}, err error) {
+	return nil, IllegalStateError
+}
+
+func (this *ReplicatedMap) Remove(key interface{}) (oldVal interface{}, err error) {
+	return this.propose(&Mutation{Op: MUTATION_REMOVE, Key: key}, false)
+}
+
+func (this *ReplicatedMap) RemoveEntry(key interface{}, value interface{}) (ok bool, err error) {
+	return this.proposeOk(&Mutation{Op: MUTATION_REMOVE_ENTRY, Key: key, Value: value}, true)
+}
+
+func (this *ReplicatedMap) Replace(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	return this.propose(&Mutation{Op: MUTATION_REPLACE, Key: key, Value: value}, true)
+}
+
+func (this *ReplicatedMap) CompareAndReplace(key interface{}, oldVal interface{}, newVal interface{}) (ok bool, err error) {
+	if isNil(oldVal) {
+		return false, NilValueError
+	}
+	return this.proposeOk(&Mutation{Op: MUTATION_COMPARE_AND_REPLACE, Key: key, Value: newVal, OldValue: oldVal}, true)
+}
+
+func (this *ReplicatedMap) Size() int32 {
+	return this.m.Size()
+}
+
+func (this *ReplicatedMap) IsEmpty() bool {
+	return this.m.IsEmpty()
+}
+
+/**
+ * Proposes a MUTATION_CLEAR, the error of Replicator is ignored because Clear of Map has no result.
+ */
+func (this *ReplicatedMap) Clear() {
+	this.r.Propose(&Mutation{Op: MUTATION_CLEAR})
+}
+
+func (this *ReplicatedMap) ToSlice() (kvs []*Entry) {
+	return this.m.ToSlice()
+}
@@ -0,0 +1,89 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+//logReplicator commits the mutations to a log and applies them to all replicas synchronously
+type logReplicator struct {
+	lock     sync.Mutex
+	log      []*Mutation
+	replicas []*ReplicatedMap
+	self     int
+}
+
+func (this *logReplicator) Propose(mutation *Mutation) (result interface{}, err error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.log = append(this.log, mutation)
+	for i, r := range this.replicas {
+		res, e := r.Apply(mutation)
+		if i == this.self {
+			result, err = res, e
+		}
+	}
+	return
+}
+
+func TestReplicatedMap(t *testing.T) {
+	r := new(logReplicator)
+	for i := 0; i < 3; i++ {
+		r.replicas = append(r.replicas, NewReplicatedMap(NewConcurrentMap(), r))
+	}
+	rm := r.replicas[0]
+
+	if old, err := rm.Put(1, "a"); old != nil || err != nil {
+		t.Errorf("Put, return %v, %v, want nil, nil", old, err)
+	}
+	if old, _ := rm.Put(1, "b"); old != "a" {
+		t.Errorf("Put existing key, return %v, want a", old)
+	}
+	if old, _ := rm.PutIfAbsent(1, "c"); old != "b" {
+		t.Errorf("PutIfAbsent existing key, return %v, want b", old)
+	}
+	if ok, _ := rm.CompareAndReplace(1, "b", "d"); !ok {
+		t.Errorf("CompareAndReplace, return false, want true")
+	}
+	if ok, _ := rm.RemoveEntry(1, "b"); ok {
+		t.Errorf("RemoveEntry with other value, return true, want false")
+	}
+	rm.PutAll(map[interface{}]interface{}{2: "x", 3: "y"})
+	rm.Remove(3)
+	rm.PutWithTTL(4, "ttl", time.Hour)
+
+	if _, err := rm.Put(nil, 1); err != NilKeyError {
+		t.Errorf("Put nil key, return %v, want NilKeyError", err)
+	}
+	if _, err := rm.Update(1, func(oldVal interface{}) interface{} { return oldVal }); err != IllegalStateError {
+		t.Errorf("Update, return %v, want IllegalStateError", err)
+	}
+	if len(r.log) != 8 {
+		t.Errorf("Proposed mutations, return %v, want 8", len(r.log))
+	}
+
+	for i, replica := range r.replicas {
+		if v, _ := replica.Get(1); v != "d" || replica.Size() != 3 {
+			t.Errorf("Get from replica %v, return %v and size %v, want d and 3", i, v, replica.Size())
+		}
+		if remaining, ok, _ := replica.m.TTL(4); !ok || remaining <= 0 {
+			t.Errorf("TTL from replica %v, return %v, %v, want positive", i, remaining, ok)
+		}
+	}
+	//the replicas expire the mapping at the same time
+	hash, _ := hashKey(4, rm.m, false)
+	expireAt, _ := rm.m.segmentFor(hash).getExpiration(4, hash)
+	for i, replica := range r.replicas {
+		if e, _ := replica.m.segmentFor(hash).getExpiration(4, hash); e != expireAt {
+			t.Errorf("Expiration time of replica %v, return %v, want %v", i, e, expireAt)
+		}
+	}
+
+	rm.Clear()
+	for i, replica := range r.replicas {
+		if !replica.IsEmpty() {
+			t.Errorf("IsEmpty of replica %v after Clear, return false, want true", i)
+		}
+	}
+}
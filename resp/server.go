@@ -0,0 +1,468 @@
+/**
+ * Package resp exposes a ConcurrentMap over a minimal RESP (REdis Serialization Protocol) listener,
+ * so other processes and existing Redis tooling, e.g. redis-cli, can inspect or share
+ * an in-process cache during debugging.
+ *
+ * The supported commands are PING, GET, SET (with EX, PX and NX), DEL, EXISTS, EXPIRE, TTL,
+ * DBSIZE and SCAN (with MATCH and COUNT). The RESP keys are the string keys of map,
+ * the values of SET are stored as string, and GET returns the other values by fmt.Sprint.
+ */
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	concurrent "github.com/fanliao/go-concurrentMap"
+)
+
+const (
+	//the max length of a bulk string and the max number of arguments of a command
+	maxBulkLen = 512 << 20
+	maxArgs    = 1 << 20
+	//the default number of keys returned by SCAN
+	defaultScanCount = 10
+)
+
+var (
+	ServerClosedError = errors.New("ServerClosedException")
+	protocolError     = errors.New("Protocol error")
+)
+
+/**
+ * Server serves a ConcurrentMap to the RESP clients.
+ */
+type Server struct {
+	m *concurrent.ConcurrentMap
+
+	lock      sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+/**
+ * Creates a Server that serves m.
+ */
+func NewServer(m *concurrent.ConcurrentMap) *Server {
+	if m == nil {
+		panic(concurrent.IllegalArgError)
+	}
+	return &Server{
+		m:         m,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+/**
+ * Listens on the TCP address and serves the connections, see Serve.
+ */
+func (this *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return this.Serve(l)
+}
+
+/**
+ * Accepts the connections on l and serves every connection in its own goroutine,
+ * it blocks until l fails or the server is closed.
+ *
+ * @return ServerClosedError if the server is closed, otherwise the error of Accept
+ */
+func (this *Server) Serve(l net.Listener) error {
+	this.lock.Lock()
+	if this.closed {
+		this.lock.Unlock()
+		l.Close()
+		return ServerClosedError
+	}
+	this.listeners[l] = struct{}{}
+	this.lock.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			this.lock.Lock()
+			closed := this.closed
+			delete(this.listeners, l)
+			this.lock.Unlock()
+			if closed {
+				return ServerClosedError
+			}
+			return err
+		}
+
+		this.lock.Lock()
+		if this.closed {
+			this.lock.Unlock()
+			conn.Close()
+			continue
+		}
+		this.conns[conn] = struct{}{}
+		this.wg.Add(1)
+		this.lock.Unlock()
+		go this.serveConn(conn)
+	}
+}
+
+/**
+ * Closes the listeners and connections, and waits for the goroutines of connections to exit.
+ */
+func (this *Server) Close() error {
+	this.lock.Lock()
+	this.closed = true
+	for l := range this.listeners {
+		l.Close()
+	}
+	for conn := range this.conns {
+		conn.Close()
+	}
+	this.lock.Unlock()
+	this.wg.Wait()
+	return nil
+}
+
+func (this *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		this.lock.Lock()
+		delete(this.conns, conn)
+		this.lock.Unlock()
+		this.wg.Done()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if err == protocolError {
+				writeError(w, err.Error())
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if quit := this.execute(w, args); quit {
+			w.Flush()
+			return
+		}
+		//flushes after the pipelined commands are executed
+		if r.Buffered() == 0 {
+			if err = w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+/**
+ * Reads a command that is either an array of bulk strings or an inline command.
+ */
+func readCommand(r *bufio.Reader) (args []string, err error) {
+	line, err := readLine(r)
+	if err != nil {
+		return
+	}
+	if len(line) == 0 || line[0] != '*' {
+		//inline command, e.g. sent by telnet
+		return strings.Fields(line), nil
+	}
+
+	//an empty array is skipped like an empty inline command, but a null array is not a command
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 || n > maxArgs {
+		return nil, protocolError
+	}
+	args = make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		if line, err = readLine(r); err != nil {
+			return
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, protocolError
+		}
+		size, e := strconv.Atoi(line[1:])
+		if e != nil || size < 0 || size > maxBulkLen {
+			return nil, protocolError
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, protocolError
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func writeSimple(w *bufio.Writer, s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func writeError(w *bufio.Writer, msg string) {
+	w.WriteString("-ERR " + msg + "\r\n")
+}
+
+func writeInt(w *bufio.Writer, n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func writeBulk(w *bufio.Writer, s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func writeNil(w *bufio.Writer) {
+	w.WriteString("$-1\r\n")
+}
+
+func writeArrayLen(w *bufio.Writer, n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+//toString converts the value of map to the bulk string
+func toString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	}
+	return fmt.Sprint(v)
+}
+
+//arity is the number of arguments of commands includes the name,
+//negative arity means at least -n arguments like Redis
+var arity = map[string]int{
+	"PING": -1, "QUIT": 1, "GET": 2, "SET": -3, "DEL": -2, "EXISTS": -2,
+	"EXPIRE": 3, "TTL": 2, "DBSIZE": 1, "SCAN": -2, "COMMAND": -1,
+}
+
+/**
+ * Executes a command and writes the reply.
+ *
+ * @return true if the connection should be closed
+ */
+func (this *Server) execute(w *bufio.Writer, args []string) (quit bool) {
+	cmd := strings.ToUpper(args[0])
+	n, ok := arity[cmd]
+	if !ok {
+		writeError(w, fmt.Sprintf("unknown command '%s'", args[0]))
+		return
+	}
+	if (n > 0 && len(args) != n) || (n < 0 && len(args) < -n) {
+		writeError(w, fmt.Sprintf("wrong number of arguments for '%s' command", strings.ToLower(cmd)))
+		return
+	}
+
+	switch cmd {
+	case "PING":
+		if len(args) > 1 {
+			writeBulk(w, args[1])
+		} else {
+			writeSimple(w, "PONG")
+		}
+	case "QUIT":
+		writeSimple(w, "OK")
+		return true
+	case "COMMAND":
+		//redis-cli sends COMMAND DOCS when connected, an empty reply disables its hints
+		writeArrayLen(w, 0)
+	case "GET":
+		if v, err := this.m.Get(args[1]); err != nil {
+			writeError(w, err.Error())
+		} else if v == nil {
+			writeNil(w)
+		} else {
+			writeBulk(w, toString(v))
+		}
+	case "SET":
+		this.set(w, args)
+	case "DEL", "EXISTS":
+		var count int64
+		for _, key := range args[1:] {
+			var v interface{}
+			if cmd == "DEL" {
+				v, _ = this.m.Remove(key)
+			} else {
+				v, _ = this.m.Get(key)
+			}
+			if v != nil {
+				count++
+			}
+		}
+		writeInt(w, count)
+	case "EXPIRE":
+		seconds, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			writeError(w, "value is not an integer or out of range")
+			return
+		}
+		if ok, _ := this.m.ExpireAt(args[1], time.Now().Add(time.Duration(seconds)*time.Second)); ok {
+			writeInt(w, 1)
+		} else {
+			writeInt(w, 0)
+		}
+	case "TTL":
+		remaining, ok, _ := this.m.TTL(args[1])
+		if !ok {
+			writeInt(w, -2)
+		} else if remaining < 0 {
+			writeInt(w, -1)
+		} else {
+			writeInt(w, int64((remaining+time.Second/2)/time.Second))
+		}
+	case "DBSIZE":
+		writeInt(w, int64(this.m.Size()))
+	case "SCAN":
+		this.scan(w, args)
+	}
+	return
+}
+
+//set executes SET key value [EX seconds|PX milliseconds] [NX]
+func (this *Server) set(w *bufio.Writer, args []string) {
+	var ttl time.Duration
+	nx := false
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "NX":
+			nx = true
+		case (opt == "EX" || opt == "PX") && i+1 < len(args):
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				writeError(w, "invalid expire time in 'set' command")
+				return
+			}
+			if ttl = time.Duration(n) * time.Millisecond; opt == "EX" {
+				ttl = time.Duration(n) * time.Second
+			}
+			i++
+		default:
+			writeError(w, "syntax error")
+			return
+		}
+	}
+
+	key, value := args[1], args[2]
+	var err error
+	switch {
+	case nx:
+		var old interface{}
+		if old, err = this.m.PutIfAbsent(key, value); err == nil && old != nil {
+			writeNil(w)
+			return
+		}
+		if err == nil && ttl > 0 {
+			this.m.ExpireAt(key, time.Now().Add(ttl))
+		}
+	case ttl > 0:
+		_, err = this.m.PutWithTTL(key, value, ttl)
+	default:
+		_, err = this.m.Put(key, value)
+	}
+	if err != nil {
+		writeError(w, err.Error())
+	} else {
+		writeSimple(w, "OK")
+	}
+}
+
+func cursorOf(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+/**
+ * Executes SCAN cursor [MATCH pattern] [COUNT count].
+ * The cursor is the hash of the last returned key, and the keys are returned in the order of hash,
+ * so every key that is in map during the whole iteration is returned, like the SCAN of Redis.
+ */
+func (this *Server) scan(w *bufio.Writer, args []string) {
+	cursor, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		writeError(w, "invalid cursor")
+		return
+	}
+	pattern, count := "*", defaultScanCount
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			writeError(w, "syntax error")
+			return
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count <= 0 {
+				writeError(w, "value is not an integer or out of range")
+				return
+			}
+		default:
+			writeError(w, "syntax error")
+			return
+		}
+	}
+
+	type scanKey struct {
+		key    string
+		cursor uint64
+	}
+	keys := make([]scanKey, 0)
+	for _, e := range this.m.ToSlice() {
+		if key, ok := e.Key().(string); ok {
+			if c := cursorOf(key); c > cursor {
+				keys = append(keys, scanKey{key, c})
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].cursor < keys[j].cursor
+	})
+
+	next := uint64(0)
+	if len(keys) > count {
+		keys = keys[:count]
+		next = keys[count-1].cursor
+	}
+	matched := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if ok, _ := path.Match(pattern, k.key); ok {
+			matched = append(matched, k.key)
+		}
+	}
+
+	writeArrayLen(w, 2)
+	writeBulk(w, strconv.FormatUint(next, 10))
+	writeArrayLen(w, len(matched))
+	for _, key := range matched {
+		writeBulk(w, key)
+	}
+}
@@ -0,0 +1,158 @@
+package resp
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	concurrent "github.com/fanliao/go-concurrentMap"
+)
+
+//client sends the commands and reads the replies as strings, the arrays are flattened
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (this *client) do(args ...string) []string {
+	cmd := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		cmd += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	this.conn.Write([]byte(cmd))
+	return this.read()
+}
+
+func (this *client) read() []string {
+	line, _ := this.r.ReadString('\n')
+	line = strings.TrimRight(line, "\r\n")
+	switch line[0] {
+	case '$':
+		if line == "$-1" {
+			return []string{"nil"}
+		}
+		data, _ := this.r.ReadString('\n')
+		return []string{strings.TrimRight(data, "\r\n")}
+	case '*':
+		var n int
+		fmt.Sscanf(line, "*%d", &n)
+		replies := []string{}
+		for i := 0; i < n; i++ {
+			replies = append(replies, this.read()...)
+		}
+		return replies
+	}
+	return []string{line}
+}
+
+func TestServer(t *testing.T) {
+	m := concurrent.NewConcurrentMap()
+	m.Put("shared", 1)
+	s := NewServer(m)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen, return error %v", err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(l)
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial, return error %v", err)
+	}
+	c := &client{conn, bufio.NewReader(conn)}
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"PING"}, "+PONG"},
+		{[]string{"GET", "shared"}, "1"},
+		{[]string{"SET", "k", "v"}, "+OK"},
+		{[]string{"get", "k"}, "v"},
+		{[]string{"SET", "k", "v2", "NX"}, "nil"},
+		{[]string{"SET", "t", "v", "EX", "100"}, "+OK"},
+		{[]string{"TTL", "t"}, ":100"},
+		{[]string{"DBSIZE"}, ":3"},
+		{[]string{"TTL", "k"}, ":-1"},
+		{[]string{"TTL", "absent"}, ":-2"},
+		{[]string{"EXPIRE", "k", "0"}, ":1"},
+		{[]string{"GET", "k"}, "nil"},
+		{[]string{"EXISTS", "t", "k"}, ":1"},
+		{[]string{"DEL", "t", "absent"}, ":1"},
+		{[]string{"SET", "k"}, "-ERR wrong number of arguments for 'set' command"},
+		{[]string{"FLUSHALL"}, "-ERR unknown command 'FLUSHALL'"},
+	}
+	for _, c0 := range cases {
+		if got := strings.Join(c.do(c0.args...), " "); got != c0.want {
+			t.Errorf("%v, reply %v, want %v", c0.args, got, c0.want)
+		}
+	}
+	if v, _ := m.Get("shared"); v != 1 {
+		t.Errorf("Get from map after commands, return %v, want 1", v)
+	}
+
+	//inline command
+	conn.Write([]byte("GET shared\r\n"))
+	if got := c.read(); got[0] != "1" {
+		t.Errorf("inline GET, reply %v, want 1", got)
+	}
+
+	for i := 0; i < 25; i++ {
+		m.Put(fmt.Sprintf("scan:%d", i), i)
+	}
+	seen := map[string]bool{}
+	cursor := "0"
+	for {
+		reply := c.do("SCAN", cursor, "MATCH", "scan:*", "COUNT", "7")
+		for _, key := range reply[1:] {
+			if seen[key] {
+				t.Errorf("SCAN, return %v twice", key)
+			}
+			seen[key] = true
+		}
+		if cursor = reply[0]; cursor == "0" {
+			break
+		}
+	}
+	if len(seen) != 25 {
+		t.Errorf("SCAN, return %v keys, want 25", len(seen))
+	}
+
+	s.Close()
+	if err := <-served; err != ServerClosedError {
+		t.Errorf("Serve after Close, return %v, want ServerClosedError", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c.r.ReadByte(); err == nil {
+		t.Errorf("Read after Close, return nil error, want connection closed")
+	}
+}
+
+func TestReadCommand(t *testing.T) {
+	cases := []struct {
+		input string
+		args  []string
+		err   error
+	}{
+		{"*2\r\n$3\r\nGET\r\n$1\r\nk\r\n", []string{"GET", "k"}, nil},
+		{"PING\r\n", []string{"PING"}, nil},
+		{"*0\r\n", []string{}, nil},
+		{"*-1\r\n", nil, protocolError},
+		{"*-100\r\n", nil, protocolError},
+		{"*1\r\n$-1\r\n", nil, protocolError},
+		{"*1\r\n$-5\r\n", nil, protocolError},
+		{"*x\r\n", nil, protocolError},
+	}
+	for _, c := range cases {
+		args, err := readCommand(bufio.NewReader(strings.NewReader(c.input)))
+		if err != c.err || fmt.Sprint(args) != fmt.Sprint(c.args) {
+			t.Errorf("readCommand %q, return %v, %v, want %v, %v", c.input, args, err, c.args, c.err)
+		}
+	}
+}
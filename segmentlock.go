@@ -0,0 +1,177 @@
+package concurrent
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+/**
+ * Unlocker releases the locks that were acquired.
+ */
+type Unlocker interface {
+	Unlock()
+}
+
+/**
+ * LockedSegments holds the locks of one or more segments, it is returned by
+ * LockSegmentOf and LockSegmentsOf. The operations of LockedSegments run under the held locks,
+ * so several operations on the keys of these segments are atomic to other goroutines.
+ *
+ * The methods of ConcurrentMap must not be called for the keys of locked segments
+ * before Unlock, otherwise the goroutine will deadlock, because the locks are not reentrant.
+ * A LockedSegments must be used by the goroutine that locked it only.
+ */
+type LockedSegments struct {
+	m        *ConcurrentMap
+	segs     []*Segment //in index order
+	unlocked bool
+}
+
+var _ Unlocker = (*LockedSegments)(nil)
+
+/**
+ * Returns the index of segment that the specified key belongs to.
+ * The keys that have same segment index can be locked by LockSegmentOf once.
+ */
+func (this *ConcurrentMap) SegmentIndexOf(key interface{}) (index int, err error) {
+	if isNil(key) {
+		return 0, NilKeyError
+	}
+	hash, err := hashKey(key, this, false)
+	if err != nil {
+		return
+	}
+	return this.segmentIndex(hash), nil
+}
+
+/**
+ * Returns the number of mappings in every segment, in the order of segment index.
+ * The counts are read without lock, and may include the expired mappings that have not been removed.
+ * It is used by the systems that co-locate work with segments to monitor the balance, see SegmentIndexOf.
+ */
+func (this *ConcurrentMap) SegmentCounts() (counts []int32) {
+	counts = make([]int32, len(this.segments))
+	for i, seg := range this.segments {
+		counts[i] = atomic.LoadInt32(&seg.count)
+	}
+	return
+}
+
+/**
+ * Locks the segment that the specified key belongs to.
+ * It is for the advanced users who need to perform several operations atomically
+ * on the keys known to share a segment, see SegmentIndexOf.
+ *
+ * The caller must call Unlock of result, and must not lock other segments
+ * before Unlock, use LockSegmentsOf to lock multiple segments.
+ */
+func (this *ConcurrentMap) LockSegmentOf(key interface{}) (locked *LockedSegments, err error) {
+	return this.LockSegmentsOf([]interface{}{key})
+}
+
+/**
+ * Locks all segments that the specified keys belong to.
+ * The segments are always locked in index order, so the goroutines that lock
+ * multiple segments by this method can't deadlock.
+ */
+func (this *ConcurrentMap) LockSegmentsOf(keys []interface{}) (locked *LockedSegments, err error) {
+	indexes := make([]int, len(keys))
+	for i, key := range keys {
+		if indexes[i], err = this.SegmentIndexOf(key); err != nil {
+			return
+		}
+	}
+	return &LockedSegments{m: this, segs: this.lockIndexes(indexes)}, nil
+}
+
+//lockIndexes locks the segments at the specified indexes in index order,
+//every segment is locked once even if its index is repeated
+func (this *ConcurrentMap) lockIndexes(indexes []int) (segs []*Segment) {
+	sort.Ints(indexes)
+	segs = make([]*Segment, 0, len(indexes))
+	for i, idx := range indexes {
+		if i == 0 || idx != indexes[i-1] {
+			seg := this.segments[idx]
+			seg.acquire()
+			segs = append(segs, seg)
+		}
+	}
+	return
+}
+
+/**
+ * Releases the locks, it does nothing if the locks have been released.
+ */
+func (this *LockedSegments) Unlock() {
+	if this.unlocked {
+		return
+	}
+	this.unlocked = true
+	for i := len(this.segs) - 1; i >= 0; i-- {
+		this.segs[i].lock.Unlock()
+	}
+}
+
+//segmentOf returns the locked segment of key and hash code of key,
+//returns IllegalArgError if the segment isn't locked, or IllegalStateError if it has been unlocked
+func (this *LockedSegments) segmentOf(key interface{}) (seg *Segment, hash uint32, err error) {
+	if this.unlocked {
+		return nil, 0, IllegalStateError
+	}
+	if isNil(key) {
+		return nil, 0, NilKeyError
+	}
+	if hash, err = hashKey(key, this.m, false); err != nil {
+		return
+	}
+	seg = this.m.segmentFor(hash)
+	for _, s := range this.segs {
+		if s == seg {
+			return
+		}
+	}
+	return nil, 0, IllegalArgError
+}
+
+/**
+ * Returns the value to which the specified key is mapped,
+ * or nil if this map contains no mapping for the key.
+ */
+func (this *LockedSegments) Get(key interface{}) (value interface{}, err error) {
+	seg, hash, err := this.segmentOf(key)
+	if err != nil {
+		return
+	}
+	if e := seg.findUnderLock(key, hash); e != nil {
+		value = e.fastValue()
+	}
+	return
+}
+
+/**
+ * Maps the specified key to the specified value, see ConcurrentMap.Put.
+ */
+func (this *LockedSegments) Put(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	if isNil(value) {
+		return nil, NilValueError
+	}
+	seg, hash, err := this.segmentOf(key)
+	if err != nil {
+		return
+	}
+	if err = this.m.validate(key, value); err != nil {
+		return
+	}
+	return seg.putUnderLock(key, hash, value, false, nil, 0), nil
+}
+
+/**
+ * Removes the key from this map, see ConcurrentMap.Remove.
+ */
+func (this *LockedSegments) Remove(key interface{}) (oldVal interface{}, err error) {
+	seg, hash, err := this.segmentOf(key)
+	if err != nil {
+		return
+	}
+	return seg.removeUnderLock(key, hash, nil), nil
+}
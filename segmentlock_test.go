@@ -0,0 +1,73 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLockSegmentOf(t *testing.T) {
+	cm := NewConcurrentMap()
+	cm.Put(1, 0)
+
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				locked, _ := cm.LockSegmentOf(1)
+				v, _ := locked.Get(1)
+				locked.Put(1, v.(int)+1)
+				locked.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := cm.Get(1); v != 400 {
+		t.Errorf("Get 1 after locked increments, return %v, want 400", v)
+	}
+
+	//finds a key in other segment
+	idx, _ := cm.SegmentIndexOf(1)
+	other := 2
+	for i, _ := cm.SegmentIndexOf(other); i == idx; i, _ = cm.SegmentIndexOf(other) {
+		other++
+	}
+	locked, _ := cm.LockSegmentOf(1)
+	if _, err := locked.Put(other, 1); err != IllegalArgError {
+		t.Errorf("Put the key of unlocked segment, return %v, want IllegalArgError", err)
+	}
+	locked.Unlock()
+	locked.Unlock()
+	if _, err := locked.Get(1); err != IllegalStateError {
+		t.Errorf("Get after Unlock, return %v, want IllegalStateError", err)
+	}
+
+	locked, _ = cm.LockSegmentsOf([]interface{}{other, 1})
+	locked.Put(other, 1)
+	locked.Remove(1)
+	locked.Unlock()
+	if v, _ := cm.Get(other); v != 1 || cm.Size() != 1 {
+		t.Errorf("Get %v after LockSegmentsOf, return %v, want 1", other, v)
+	}
+}
+
+func TestSegmentCounts(t *testing.T) {
+	cm := NewConcurrentMap()
+	expected := make([]int32, len(cm.segments))
+	for i := 0; i < 1000; i++ {
+		cm.Put(i, i)
+		idx, _ := cm.SegmentIndexOf(i)
+		expected[idx]++
+	}
+
+	counts := cm.SegmentCounts()
+	if len(counts) != len(cm.segments) {
+		t.Errorf("SegmentCounts, return %v counts, want %v", len(counts), len(cm.segments))
+	}
+	for i := range counts {
+		if counts[i] != expected[i] {
+			t.Errorf("SegmentCounts of segment %v, return %v, want %v", i, counts[i], expected[i])
+		}
+	}
+}
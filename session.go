@@ -0,0 +1,129 @@
+package concurrent
+
+/**
+ * Session is a read-your-writes view over the map for request-scoped staging.
+ * The writes are buffered in the session and invisible to others until Commit,
+ * the reads of session check the buffered writes first, then the map.
+ *
+ * Unlike Tx, the reads are not validated when committing, the buffered writes
+ * just overwrite the map like a batch of Put and Remove.
+ * A Session is intended to be owned by one goroutine, it is not safe to be used concurrently.
+ */
+type Session struct {
+	m      *ConcurrentMap
+	writes writeSet
+}
+
+/**
+ * Creates a Session for this map.
+ */
+func (this *ConcurrentMap) NewSession() *Session {
+	return &Session{m: this}
+}
+
+/**
+ * Returns the value that the session wrote for the specified key,
+ * or the value to which the key is mapped in the map if the session did not write it.
+ * Returns nil if the session removed the key.
+ */
+func (this *Session) Get(key interface{}) (value interface{}, err error) {
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+	hash, err := hashKey(key, this.m, false)
+	if err != nil {
+		return
+	}
+	if w := this.writes.find(key, hash); w != nil {
+		//the buffered value is decoded like the committed value
+		return this.m.decode(w.value), nil
+	}
+	return this.m.decode(this.m.segmentFor(hash).get(key, hash)), nil
+}
+
+/**
+ * Buffers a Put of the specified key and value, neither the key nor the value can be nil.
+ */
+func (this *Session) Put(key interface{}, value interface{}) (err error) {
+	if isNil(key) {
+		return NilKeyError
+	}
+	if isNil(value) {
+		return NilValueError
+	}
+	if e := this.m.validate(key, value); e != nil {
+		return e
+	}
+	return this.write(key, value)
+}
+
+/**
+ * Buffers a Remove of the specified key.
+ */
+func (this *Session) Remove(key interface{}) (err error) {
+	if isNil(key) {
+		return NilKeyError
+	}
+	return this.write(key, nil)
+}
+
+func (this *Session) write(key interface{}, value interface{}) (err error) {
+	hash, err := hashKey(key, this.m, false)
+	if err != nil {
+		return
+	}
+	Printf("Session write, %v, %v\n", key, hash)
+	this.writes.set(key, hash, value)
+	return
+}
+
+/**
+ * Returns the number of keys that the session wrote and did not commit.
+ */
+func (this *Session) Len() int {
+	return len(this.writes)
+}
+
+/**
+ * Applies the buffered writes to the map and clears the session.
+ * The writes are grouped by segment and every segment is locked only once,
+ * so the writes in a segment are visible to others at the same time,
+ * but the writes in different segments are not applied atomically.
+ * A Put in session clears the expiration time of key like Put.
+ */
+func (this *Session) Commit() {
+	groups := make([]writeSet, len(this.m.segments))
+	for _, w := range this.writes {
+		i := this.m.segmentIndex(w.hash)
+		groups[i] = append(groups[i], w)
+	}
+	for i, group := range groups {
+		if len(group) > 0 {
+			this.m.segments[i].writeAll(group)
+		}
+	}
+	this.writes = nil
+}
+
+/**
+ * Discards the buffered writes.
+ */
+func (this *Session) Discard() {
+	this.writes = nil
+}
+
+/**
+ * Applies the writes under a single lock acquisition.
+ */
+func (this *Segment) writeAll(writes writeSet) {
+	this.acquire()
+	defer this.lock.Unlock()
+
+	for _, w := range writes {
+		if w.value == nil {
+			this.removeUnderLock(w.key, w.hash, nil)
+		} else {
+			this.storeUnderLock(w.key, w.hash, w.value, 0)
+		}
+	}
+}
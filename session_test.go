@@ -0,0 +1,66 @@
+package concurrent
+
+import (
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	cm := NewConcurrentMap()
+	for i := 0; i < 10; i++ {
+		cm.Put(i, i)
+	}
+
+	s := cm.NewSession()
+	for i := 0; i < 5; i++ {
+		s.Put(i, i*10)
+	}
+	s.Remove(9)
+	s.Put(100, 100)
+	s.Put(100, 200)
+
+	if v, _ := s.Get(1); v != 10 {
+		t.Errorf("Get 1 from session, return %v, want 10", v)
+	}
+	if v, _ := s.Get(9); v != nil {
+		t.Errorf("Get 9 from session after Remove, return %v, want nil", v)
+	}
+	if v, _ := s.Get(7); v != 7 {
+		t.Errorf("Get 7 from session, return %v, want 7", v)
+	}
+	if v, _ := cm.Get(1); v != 1 {
+		t.Errorf("Get 1 before Commit, return %v, want 1", v)
+	}
+	if l := s.Len(); l != 7 {
+		t.Errorf("Len of session, return %v, want 7", l)
+	}
+	if err := s.Put(nil, 1); err != NilKeyError {
+		t.Errorf("Put nil key to session, return %v, want NilKeyError", err)
+	}
+	if err := s.Put(1, nil); err != NilValueError {
+		t.Errorf("Put nil value to session, return %v, want NilValueError", err)
+	}
+
+	s.Commit()
+	if v, _ := cm.Get(1); v != 10 {
+		t.Errorf("Get 1 after Commit, return %v, want 10", v)
+	}
+	if v, _ := cm.Get(9); v != nil {
+		t.Errorf("Get 9 after Commit, return %v, want nil", v)
+	}
+	if v, _ := cm.Get(100); v != 200 {
+		t.Errorf("Get 100 after Commit, return %v, want 200", v)
+	}
+	if s := cm.Size(); s != 10 {
+		t.Errorf("Get size after Commit, return %v, want 10", s)
+	}
+	if l := s.Len(); l != 0 {
+		t.Errorf("Len of session after Commit, return %v, want 0", l)
+	}
+
+	s.Put(1, 1000)
+	s.Discard()
+	s.Commit()
+	if v, _ := cm.Get(1); v != 10 {
+		t.Errorf("Get 1 after Discard, return %v, want 10", v)
+	}
+}
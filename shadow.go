@@ -0,0 +1,195 @@
+package concurrent
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	//the max number of reads that are waiting to be compared, the later reads are not compared
+	shadowQueueSize = 1024
+)
+
+//shadowRead is a read of primary map that will be compared with the shadow map
+type shadowRead struct {
+	key   interface{}
+	value interface{}
+}
+
+/**
+ * ShadowMap is a Map that serves the primary map and mirrors all writes to a shadow map,
+ * the reads are compared with the shadow map asynchronously and the divergences are reported.
+ * It is used to migrate from another cache implementation in dry-run, e.g. the old cache is
+ * the primary map and a ConcurrentMap is the shadow map, until no divergence is reported.
+ *
+ * The writes are mirrored synchronously after the primary map is written, the conditional writes,
+ * e.g. PutIfAbsent and Update, are mirrored by copying the result of primary map, so the actions
+ * are never called twice. The errors of shadow map are ignored. The concurrent writes of same key
+ * may be mirrored in a different order, and a write may happen between a read and its comparison,
+ * so a few divergences of the keys written concurrently are expected.
+ */
+type ShadowMap struct {
+	primary      Map
+	shadow       Map
+	onDivergence func(key interface{}, primary interface{}, shadow interface{})
+	reads        chan shadowRead
+	compared     int64 //atomic
+	diverged     int64 //atomic
+	closeOnce    sync.Once
+	done         chan struct{}
+}
+
+/**
+ * Creates a ShadowMap that serves primary and mirrors the writes to shadow,
+ * onDivergence is called by a background goroutine if a read of primary is different
+ * from shadow, the values are compared by reflect.DeepEqual. Close stops the goroutine.
+ */
+func NewShadowMap(primary Map, shadow Map, onDivergence func(key interface{}, primary interface{}, shadow interface{})) *ShadowMap {
+	if primary == nil || shadow == nil || onDivergence == nil {
+		panic(IllegalArgError)
+	}
+	m := &ShadowMap{primary: primary, shadow: shadow, onDivergence: onDivergence,
+		reads: make(chan shadowRead, shadowQueueSize), done: make(chan struct{})}
+	go m.compare()
+	return m
+}
+
+var _ Map = (*ShadowMap)(nil)
+
+//compare compares the queued reads with shadow map until Close is called
+func (this *ShadowMap) compare() {
+	for {
+		select {
+		case r := <-this.reads:
+			v, _ := this.shadow.Get(r.key)
+			atomic.AddInt64(&this.compared, 1)
+			if !reflect.DeepEqual(r.value, v) {
+				atomic.AddInt64(&this.diverged, 1)
+				this.onDivergence(r.key, r.value, v)
+			}
+		case <-this.done:
+			return
+		}
+	}
+}
+
+//check queues the read to be compared, it is dropped if the queue is full
+func (this *ShadowMap) check(key interface{}, value interface{}) {
+	select {
+	case this.reads <- shadowRead{key, value}:
+	default:
+	}
+}
+
+//mirror copies the current mapping of key from primary map to shadow map
+func (this *ShadowMap) mirror(key interface{}) {
+	if v, err := this.primary.Get(key); err == nil {
+		if v == nil {
+			this.shadow.Remove(key)
+		} else {
+			this.shadow.Put(key, v)
+		}
+	}
+}
+
+/**
+ * Returns the number of reads that have been compared and the number of divergences.
+ */
+func (this *ShadowMap) Divergences() (compared int64, diverged int64) {
+	return atomic.LoadInt64(&this.compared), atomic.LoadInt64(&this.diverged)
+}
+
+/**
+ * Stops comparing the reads, the writes are still mirrored.
+ */
+func (this *ShadowMap) Close() error {
+	this.closeOnce.Do(func() {
+		close(this.done)
+	})
+	return nil
+}
+
+func (this *ShadowMap) Get(key interface{}) (value interface{}, err error) {
+	if value, err = this.primary.Get(key); err == nil {
+		this.check(key, value)
+	}
+	return
+}
+
+func (this *ShadowMap) ContainsKey(key interface{}) (found bool, err error) {
+	return this.primary.ContainsKey(key)
+}
+
+func (this *ShadowMap) Put(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	if oldVal, err = this.primary.Put(key, value); err == nil {
+		this.shadow.Put(key, value)
+	}
+	return
+}
+
+func (this *ShadowMap) PutIfAbsent(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	if oldVal, err = this.primary.PutIfAbsent(key, value); err == nil {
+		this.mirror(key)
+	}
+	return
+}
+
+func (this *ShadowMap) PutAll(m map[interface{}]interface{}) (err error) {
+	if err = this.primary.PutAll(m); err == nil {
+		this.shadow.PutAll(m)
+	}
+	return
+}
+
+func (this *ShadowMap) Update(key interface{}, action func(oldVal interface{}) (newVal interface{})) (oldVal interface{}, err error) {
+	if oldVal, err = this.primary.Update(key, action); err == nil {
+		this.mirror(key)
+	}
+	return
+}
+
+func (this *ShadowMap) Remove(key interface{}) (oldVal interface{}, err error) {
+	if oldVal, err = this.primary.Remove(key); err == nil {
+		this.shadow.Remove(key)
+	}
+	return
+}
+
+func (this *ShadowMap) RemoveEntry(key interface{}, value interface{}) (ok bool, err error) {
+	if ok, err = this.primary.RemoveEntry(key, value); err == nil {
+		this.mirror(key)
+	}
+	return
+}
+
+func (this *ShadowMap) Replace(key interface{}, value interface{}) (oldVal interface{}, err error) {
+	if oldVal, err = this.primary.Replace(key, value); err == nil {
+		this.mirror(key)
+	}
+	return
+}
+
+func (this *ShadowMap) CompareAndReplace(key interface{}, oldVal interface{}, newVal interface{}) (ok bool, err error) {
+	if ok, err = this.primary.CompareAndReplace(key, oldVal, newVal); err == nil {
+		this.mirror(key)
+	}
+	return
+}
+
+func (this *ShadowMap) Size() int32 {
+	return this.primary.Size()
+}
+
+func (this *ShadowMap) IsEmpty() bool {
+	return this.primary.IsEmpty()
+}
+
+func (this *ShadowMap) Clear() {
+	this.primary.Clear()
+	this.shadow.Clear()
+}
+
+func (this *ShadowMap) ToSlice() (kvs []*Entry) {
+	return this.primary.ToSlice()
+}
@@ -0,0 +1,62 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShadowMap(t *testing.T) {
+	primary, shadow := NewConcurrentMap(), NewConcurrentMap()
+	var lock sync.Mutex
+	divergences := map[interface{}][2]interface{}{}
+	m := NewShadowMap(primary, shadow, func(key interface{}, p interface{}, s interface{}) {
+		lock.Lock()
+		divergences[key] = [2]interface{}{p, s}
+		lock.Unlock()
+	})
+	defer m.Close()
+
+	m.Put(1, 1)
+	m.PutIfAbsent(2, 2)
+	m.PutAll(map[interface{}]interface{}{3: 3, 4: 4})
+	m.Update(1, func(oldVal interface{}) interface{} {
+		return oldVal.(int) + 10
+	})
+	m.Replace(3, 30)
+	m.CompareAndReplace(4, 4, 40)
+	m.Remove(2)
+	for _, k := range []int{1, 2, 3, 4} {
+		p, _ := primary.Get(k)
+		s, _ := shadow.Get(k)
+		if p != s {
+			t.Errorf("mirrored %v, shadow is %v, want %v", k, s, p)
+		}
+	}
+
+	//a write that bypasses the decorator diverges
+	shadow.Put(1, 100)
+	for _, k := range []int{1, 3} {
+		m.Get(k)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		if compared, _ := m.Divergences(); compared == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if compared, diverged := m.Divergences(); compared != 2 || diverged != 1 {
+		t.Errorf("Divergences, return %v, %v, want 2, 1", compared, diverged)
+	}
+	lock.Lock()
+	if d, ok := divergences[1]; !ok || d[0] != 11 || d[1] != 100 {
+		t.Errorf("divergence of 1, return %v, want [11 100]", d)
+	}
+	lock.Unlock()
+
+	m.Clear()
+	if shadow.Size() != 0 {
+		t.Errorf("Clear, Size of shadow is %v, want 0", shadow.Size())
+	}
+}
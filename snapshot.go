@@ -0,0 +1,177 @@
+package concurrent
+
+import (
+	"time"
+)
+
+/**
+ * SnapshotHandle is a copy of all mappings of a map at a moment,
+ * it records the version of every value, so can be used to find out
+ * the changes of map since the snapshot was taken.
+ *
+ * Every segment is copied under its lock, so the view of a segment is consistent,
+ * but the views of different segments are taken at different moments.
+ */
+type SnapshotHandle struct {
+	m       *ConcurrentMap
+	size    int
+	entries map[uint32][]Entry //entries grouped by hash code
+}
+
+/**
+ * Returns the number of mappings in snapshot.
+ */
+func (this *SnapshotHandle) Size() int {
+	return this.size
+}
+
+/**
+ * Returns the entry for key in snapshot, or nil if there is no mapping for the key.
+ */
+func (this *SnapshotHandle) find(key interface{}, hash uint32) *Entry {
+	entries := this.entries[hash]
+	for i := 0; i < len(entries); i++ {
+		if equals(entries[i].key, key) {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+/**
+ * SnapshotDelta includes the keys that have been added, removed or changed
+ * between two snapshots.
+ */
+type SnapshotDelta struct {
+	Added   []interface{}
+	Removed []interface{}
+	Changed []interface{}
+	/**
+	 * The snapshot that delta is computed against, it can be used to compute next delta.
+	 */
+	Snapshot *SnapshotHandle
+}
+
+/**
+ * Returns a snapshot of this map.
+ */
+func (this *ConcurrentMap) Snapshot() *SnapshotHandle {
+	s := &SnapshotHandle{m: this, entries: make(map[uint32][]Entry)}
+	this.ForEachSegmentLocked(func(entries []Entry) {
+		for _, e := range entries {
+			s.entries[e.hash] = append(s.entries[e.hash], e)
+		}
+		s.size += len(entries)
+	})
+	return s
+}
+
+/**
+ * Computes the keys that have been added, removed or changed since the old snapshot was taken.
+ * A key is changed if its value has been stored again, even if the new value is equal to old value.
+ *
+ * @param oldSnapshot a snapshot taken from this map by Snapshot or previous SnapshotDelta
+ * @return the delta, and the delta.Snapshot is the current snapshot
+ * panic IllegalArgError if oldSnapshot was not taken from this map
+ */
+func (this *ConcurrentMap) SnapshotDelta(oldSnapshot *SnapshotHandle) (delta *SnapshotDelta) {
+	if oldSnapshot == nil || oldSnapshot.m != this {
+		panic(IllegalArgError)
+	}
+
+	current := this.Snapshot()
+	delta = &SnapshotDelta{
+		Added:    make([]interface{}, 0),
+		Removed:  make([]interface{}, 0),
+		Changed:  make([]interface{}, 0),
+		Snapshot: current,
+	}
+
+	diffSnapshots(oldSnapshot, current, func(e *Entry, added bool) error {
+		if added {
+			delta.Added = append(delta.Added, e.key)
+		} else {
+			delta.Changed = append(delta.Changed, e.key)
+		}
+		return nil
+	}, func(e *Entry) error {
+		delta.Removed = append(delta.Removed, e.key)
+		return nil
+	})
+	return
+}
+
+/**
+ * Calls changed for the entries of current snapshot that have been added or changed since old snapshot,
+ * and calls removed for the entries of old snapshot that have been removed, until any callback returns error.
+ */
+func diffSnapshots(old *SnapshotHandle, current *SnapshotHandle,
+	changed func(e *Entry, added bool) error, removed func(e *Entry) error) (err error) {
+	for hash, entries := range current.entries {
+		for i := range entries {
+			e := &entries[i]
+			if o := old.find(e.key, hash); o == nil {
+				err = changed(e, true)
+			} else if o.version != e.version {
+				err = changed(e, false)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	for hash, entries := range old.entries {
+		for i := range entries {
+			if current.find(entries[i].key, hash) == nil {
+				if err = removed(&entries[i]); err != nil {
+					return
+				}
+			}
+		}
+	}
+	return
+}
+
+/**
+ * Ships the mappings that have been added, changed or removed since the snapshot to dst,
+ * so dst is brought up to date cheaply, e.g. the replica of a primary in-process cache.
+ * If since is nil, all mappings are shipped. The expiration times are shipped too if dst is a ConcurrentMap.
+ *
+ * dst must have been synchronized to since, i.e. by the previous SyncTo that returned since,
+ * and must not be written by others, otherwise it may diverge from this map.
+ *
+ * @param since the snapshot returned by previous SyncTo, or nil
+ * @return the current snapshot that should be passed to next SyncTo,
+ *         or the first error returned by dst, then next SyncTo should be passed the same since
+ * panic IllegalArgError if since was not taken from this map
+ */
+func (this *ConcurrentMap) SyncTo(dst Map, since *SnapshotHandle) (current *SnapshotHandle, err error) {
+	if dst == nil || (since != nil && since.m != this) {
+		panic(IllegalArgError)
+	}
+	if since == nil {
+		since = &SnapshotHandle{m: this}
+	}
+
+	current = this.Snapshot()
+	cm, _ := dst.(*ConcurrentMap)
+	err = diffSnapshots(since, current, func(e *Entry, added bool) (err error) {
+		if e.expireAt != 0 && cm != nil {
+			if e.isExpired(time.Now().UnixNano()) {
+				_, err = cm.Remove(e.key)
+			} else {
+				_, err = cm.putWithExpireAt(e.key, e.fastValue(), e.expireAt)
+			}
+		} else {
+			_, err = dst.Put(e.key, e.fastValue())
+		}
+		return
+	}, func(e *Entry) (err error) {
+		_, err = dst.Remove(e.key)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
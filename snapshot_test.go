@@ -0,0 +1,105 @@
+package concurrent
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func sortedInts(keys []interface{}) []int {
+	ints := make([]int, 0, len(keys))
+	for _, k := range keys {
+		ints = append(ints, k.(int))
+	}
+	sort.Ints(ints)
+	return ints
+}
+
+func TestSnapshotDelta(t *testing.T) {
+	cm := NewConcurrentMap()
+	for i := 0; i < 10; i++ {
+		cm.Put(i, i)
+	}
+	s := cm.Snapshot()
+	if s.Size() != 10 {
+		t.Errorf("Size of snapshot, return %v, want 10", s.Size())
+	}
+
+	cm.Put(10, 10)
+	cm.Put(11, 11)
+	cm.Remove(0)
+	cm.Replace(1, 100)
+	cm.Put(2, 2) //store the same value
+	cm.Update(3, func(oldVal interface{}) interface{} { return oldVal })
+
+	delta := cm.SnapshotDelta(s)
+	if added := sortedInts(delta.Added); len(added) != 2 || added[0] != 10 || added[1] != 11 {
+		t.Errorf("Added keys, return %v, want [10 11]", added)
+	}
+	if removed := sortedInts(delta.Removed); len(removed) != 1 || removed[0] != 0 {
+		t.Errorf("Removed keys, return %v, want [0]", removed)
+	}
+	if changed := sortedInts(delta.Changed); len(changed) != 3 || changed[0] != 1 || changed[1] != 2 || changed[2] != 3 {
+		t.Errorf("Changed keys, return %v, want [1 2 3]", changed)
+	}
+
+	delta = cm.SnapshotDelta(delta.Snapshot)
+	if len(delta.Added)+len(delta.Removed)+len(delta.Changed) != 0 {
+		t.Errorf("Delta without changes, return %v, want empty delta", delta)
+	}
+}
+
+//countingMap counts the writes of Put and Remove
+type countingMap struct {
+	*ConcurrentMap
+	writes int
+}
+
+func (this *countingMap) Put(key interface{}, value interface{}) (interface{}, error) {
+	this.writes++
+	return this.ConcurrentMap.Put(key, value)
+}
+
+func (this *countingMap) Remove(key interface{}) (interface{}, error) {
+	this.writes++
+	return this.ConcurrentMap.Remove(key)
+}
+
+func TestSyncTo(t *testing.T) {
+	primary, replica := NewConcurrentMap(), NewConcurrentMap()
+	for i := 0; i < 10; i++ {
+		primary.Put(i, i)
+	}
+	primary.PutWithTTL("ttl", 1, time.Hour)
+	since, err := primary.SyncTo(replica, nil)
+	if err != nil || replica.Size() != 11 {
+		t.Errorf("SyncTo empty replica, return %v and size %v, want 11", err, replica.Size())
+	}
+	if remaining, ok, _ := replica.TTL("ttl"); !ok || remaining <= 0 {
+		t.Errorf("TTL of synchronized mapping, return %v, %v, want positive", remaining, ok)
+	}
+
+	//only the changes are shipped
+	primary.Put(10, 10)
+	primary.Remove(0)
+	primary.Replace(1, 100)
+	rec := &countingMap{ConcurrentMap: replica}
+	if since, err = primary.SyncTo(rec, since); err != nil {
+		t.Errorf("SyncTo, return error %v", err)
+	}
+	if n := rec.writes; n != 3 {
+		t.Errorf("SyncTo, ship %v operations, want 3", n)
+	}
+	for _, e := range primary.ToSlice() {
+		if v, _ := replica.Get(e.Key()); v != e.Value() {
+			t.Errorf("Get %v from replica, return %v, want %v", e.Key(), v, e.Value())
+		}
+	}
+	if v, _ := replica.Get(0); v != nil || replica.Size() != primary.Size() {
+		t.Errorf("Get removed key from replica, return %v, want nil", v)
+	}
+
+	if _, err = primary.SyncTo(rec, since); err != nil || rec.writes != 3 {
+		t.Errorf("SyncTo without changes, ship %v operations, want 3", rec.writes)
+	}
+}
@@ -0,0 +1,44 @@
+package concurrent
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+/**
+ * PanicError is returned instead of the panic of a user callback if WithSoftFail is used.
+ */
+type PanicError struct {
+	//the value passed to panic
+	Value interface{}
+	//the stack of goroutine when the panic was recovered
+	Stack []byte
+}
+
+func (this *PanicError) Error() string {
+	return fmt.Sprintf("callback panicked: %v", this.Value)
+}
+
+/**
+ * Returns an Option that recovers the panics of user callbacks, e.g. the action of Update
+ * and the functions of ComputeAll and Atomically, and returns them as PanicError.
+ *
+ * The segment locks are always released when a callback panics, with or without this option.
+ * The changes that the operation has made before the panic are kept, e.g. the keys
+ * computed by ComputeAll before the panicking key, but the mapping of panicking key is not changed.
+ */
+func WithSoftFail() Option {
+	return func(m *ConcurrentMap) {
+		m.softFail = true
+	}
+}
+
+//recoverCallback converts the panic to PanicError if soft fail is enabled, it must be deferred directly
+func (this *ConcurrentMap) recoverCallback(err *error) {
+	if !this.softFail {
+		return
+	}
+	if r := recover(); r != nil {
+		*err = &PanicError{Value: r, Stack: debug.Stack()}
+	}
+}
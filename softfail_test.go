@@ -0,0 +1,53 @@
+package concurrent
+
+import (
+	"testing"
+)
+
+func TestWithSoftFail(t *testing.T) {
+	cm := NewConcurrentMap(WithSoftFail())
+	cm.Put(1, 1)
+
+	_, err := cm.Update(1, func(oldVal interface{}) interface{} {
+		panic("bad callback")
+	})
+	if pe, ok := err.(*PanicError); !ok || pe.Value != "bad callback" || len(pe.Stack) == 0 {
+		t.Errorf("Update with panicking action, return %v, want PanicError", err)
+	}
+	//the segment is not locked by the panicking callback
+	if _, err := cm.Put(1, 2); err != nil {
+		t.Errorf("Put after panic, return %v, want nil", err)
+	}
+	if v, _ := cm.Get(1); v != 2 {
+		t.Errorf("Get after panic, return %v, want 2", v)
+	}
+
+	err = cm.ComputeAll([]interface{}{1, 2}, func(key interface{}, oldVal interface{}) interface{} {
+		if key == 2 {
+			panic("bad key")
+		}
+		return 10
+	})
+	if _, ok := err.(*PanicError); !ok {
+		t.Errorf("ComputeAll with panicking function, return %v, want PanicError", err)
+	}
+	if v, _ := cm.Get(2); v != nil {
+		t.Errorf("Get panicking key, return %v, want nil", v)
+	}
+
+	if err := cm.Atomically(func(tx *Tx) error {
+		panic("bad tx")
+	}); err == nil {
+		t.Errorf("Atomically with panicking function, return nil, want PanicError")
+	}
+
+	//panics are not recovered without soft fail
+	defer func() {
+		if r := recover(); r != "hard" {
+			t.Errorf("Update without soft fail, panic %v, want hard", r)
+		}
+	}()
+	NewConcurrentMap().Update(1, func(oldVal interface{}) interface{} {
+		panic("hard")
+	})
+}
@@ -0,0 +1,52 @@
+package concurrent
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+/**
+ * Returns an Option that makes the writers spin briefly before blocking on a contended segment lock,
+ * so the short-lived contention of bursty writes doesn't park the goroutines.
+ * Every spin yields the processor by runtime.Gosched and then tries the lock again.
+ *
+ * The spin budget is adaptive per segment: it grows by one (up to maxSpins) when the lock is acquired
+ * by spinning, and is halved when the spins fail, so the segments whose lock is held for long
+ * soon stop wasting the processor.
+ *
+ * @param maxSpins the max number of spins before blocking, must be positive
+ */
+func WithLockSpin(maxSpins int) Option {
+	if maxSpins <= 0 {
+		panic(IllegalArgError)
+	}
+	return func(m *ConcurrentMap) {
+		for _, seg := range m.segments {
+			seg.maxSpins = int32(maxSpins)
+			seg.spinBudget = int32(maxSpins)
+		}
+	}
+}
+
+/**
+ * Spins for the lock of segment within the current spin budget, and adapts the budget by the result.
+ *
+ * @return true if the lock was acquired
+ */
+func (this *Segment) spin() bool {
+	budget := atomic.LoadInt32(&this.spinBudget)
+	for i := int32(0); i < budget; i++ {
+		runtime.Gosched()
+		if this.lock.TryLock() {
+			if budget < this.maxSpins {
+				atomic.CompareAndSwapInt32(&this.spinBudget, budget, budget+1)
+			}
+			return true
+		}
+	}
+	//keep at least one spin, so the budget can grow again when the contention becomes short
+	if budget > 1 {
+		atomic.CompareAndSwapInt32(&this.spinBudget, budget, budget/2)
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithLockSpin(t *testing.T) {
+	cm := NewConcurrentMap(WithLockSpin(8))
+	hash, _ := hashKey(1, cm, false)
+	seg := cm.segmentFor(hash)
+
+	//the lock is released while spinning
+	seg.lock.Lock()
+	go func() {
+		time.Sleep(time.Microsecond)
+		seg.lock.Unlock()
+	}()
+	if _, err := cm.Put(1, 1); err != nil {
+		t.Errorf("Put to contended segment, return %v, want nil", err)
+	}
+
+	//the budget is halved if the spins fail, but keeps at least one spin
+	seg.lock.Lock()
+	for i := 0; i < 10; i++ {
+		if seg.spin() {
+			t.Errorf("spin on held lock, return true, want false")
+		}
+	}
+	seg.lock.Unlock()
+	if seg.spinBudget != 1 {
+		t.Errorf("spinBudget after failed spins, is %v, want 1", seg.spinBudget)
+	}
+
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				cm.Put(i, g)
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := cm.Size(); n != 1000 {
+		t.Errorf("Size after concurrent Put, return %v, want 1000", n)
+	}
+	if b := seg.spinBudget; b < 1 || b > 8 {
+		t.Errorf("spinBudget after concurrent Put, is %v, want 1-8", b)
+	}
+}
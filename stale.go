@@ -0,0 +1,115 @@
+package concurrent
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+/**
+ * Maps the key to the value with two deadlines, like the caches of CDN.
+ * After softTTL the mapping becomes stale, it is still returned but GetWithStale reports it,
+ * so the caller can serve it while refreshing it. After hardTTL the mapping expires
+ * and is removed like PutWithTTL. The hardTTL is randomized if WithTTLJitter is used.
+ *
+ * Put clears both deadlines, and Replace, CompareAndReplace and Update keep them like PutWithTTL.
+ *
+ * @param softTTL must be > 0
+ * @param hardTTL must be >= softTTL, or <= 0 if the mapping never expires
+ * @return the previous value associated with key, or nil if there was no mapping for key
+ */
+func (this *ConcurrentMap) PutWithSoftTTL(key interface{}, value interface{}, softTTL time.Duration, hardTTL time.Duration) (oldVal interface{}, err error) {
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+	if isNil(value) {
+		return nil, NilValueError
+	}
+	if e := this.validate(key, value); e != nil {
+		return nil, e
+	}
+	if softTTL <= 0 || (hardTTL > 0 && hardTTL < softTTL) {
+		return nil, IllegalArgError
+	}
+
+	now := time.Now()
+	staleAt := now.Add(softTTL).UnixNano()
+	var expireAt int64
+	if hardTTL > 0 {
+		if expireAt = now.Add(this.jitter(hardTTL)).UnixNano(); expireAt < staleAt {
+			expireAt = staleAt
+		}
+	}
+
+	if hash, e := hashKey(key, this, false); e != nil {
+		err = e
+	} else {
+		Printf("PutWithSoftTTL, %v, %v, %v, %v\n", key, hash, softTTL, hardTTL)
+		oldVal = this.segmentFor(hash).putWithStale(key, hash, value, staleAt, expireAt)
+		if expireAt != 0 {
+			this.scheduleExpiration(key, hash, expireAt)
+		}
+	}
+	return
+}
+
+/**
+ * Returns the value to which the specified key is mapped like Get,
+ * and reports if the mapping is stale, i.e. its soft TTL has passed, see PutWithSoftTTL.
+ */
+func (this *ConcurrentMap) GetWithStale(key interface{}) (value interface{}, stale bool, err error) {
+	if isNil(key) {
+		return nil, false, NilKeyError
+	}
+	if hash, e := hashKey(key, this, false); e != nil {
+		err = e
+	} else {
+		Printf("GetWithStale, %v, %v\n", key, hash)
+		value, stale = this.segmentFor(hash).getWithStale(key, hash)
+		value = this.decode(value)
+	}
+	return
+}
+
+//putWithStale is same as putWithExpiration, and the stored mapping becomes stale at staleAt
+func (this *Segment) putWithStale(key interface{}, hash uint32, value interface{}, staleAt int64, expireAt int64) (oldVal interface{}) {
+	this.acquire()
+	defer this.lock.Unlock()
+	return this.putEntryUnderLock(key, hash, value, false, nil, expireAt, staleAt, true)
+}
+
+//storeWithStaleUnderLock is same as storeUnderLock, and the stored mapping becomes stale at staleAt.
+//Call only while holding lock.
+func (this *Segment) storeWithStaleUnderLock(key interface{}, hash uint32, value interface{}, expireAt int64, staleAt int64) {
+	this.putEntryUnderLock(key, hash, value, false, nil, expireAt, staleAt, false)
+}
+
+//setStaleValue is same as setValue, and the value becomes stale at staleAt.
+//If staleAt is changed, e is replaced by a new entry that holds both, so the lock-free
+//getWithStale never sees the new value with the staleAt of previous value or vice versa.
+//Call only while holding lock.
+func (this *Segment) setStaleValue(e *Entry, v interface{}, staleAt int64) *Entry {
+	if e.staleAt == staleAt {
+		return this.setValue(e, v)
+	}
+	this.version++
+	return this.replaceEntryUnderLock(e, this.m.boxValue(v), staleAt)
+}
+
+func (this *Segment) getWithStale(key interface{}, hash uint32) (value interface{}, stale bool) {
+	if atomic.LoadInt32(&this.count) == 0 {
+		return
+	}
+	for e := this.getFirst(hash); e != nil; e = e.next {
+		if e.hash == hash && equals(e.key, key) {
+			now := time.Now().UnixNano()
+			if e.isExpired(now) {
+				return
+			}
+			value = e.Value()
+			this.touch(e)
+			staleAt := atomic.LoadInt64(&e.staleAt)
+			return value, staleAt != 0 && staleAt <= now
+		}
+	}
+	return
+}
@@ -0,0 +1,69 @@
+package concurrent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPutWithSoftTTL(t *testing.T) {
+	cm := NewConcurrentMap()
+	if _, err := cm.PutWithSoftTTL(1, 1, 50*time.Millisecond, 200*time.Millisecond); err != nil {
+		t.Errorf("PutWithSoftTTL, return %v, want nil", err)
+	}
+	if v, stale, _ := cm.GetWithStale(1); v != 1 || stale {
+		t.Errorf("GetWithStale before soft TTL, return %v, %v, want 1, false", v, stale)
+	}
+
+	time.Sleep(80 * time.Millisecond)
+	if v, stale, _ := cm.GetWithStale(1); v != 1 || !stale {
+		t.Errorf("GetWithStale after soft TTL, return %v, %v, want 1, true", v, stale)
+	}
+	if v, _ := cm.Get(1); v != 1 {
+		t.Errorf("Get of stale mapping, return %v, want 1", v)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if v, stale, _ := cm.GetWithStale(1); v != nil || stale {
+		t.Errorf("GetWithStale after hard TTL, return %v, %v, want nil, false", v, stale)
+	}
+
+	//Put clears the soft TTL
+	cm.PutWithSoftTTL(2, 2, time.Millisecond, 0)
+	cm.Put(2, 3)
+	time.Sleep(5 * time.Millisecond)
+	if v, stale, _ := cm.GetWithStale(2); v != 3 || stale {
+		t.Errorf("GetWithStale after Put, return %v, %v, want 3, false", v, stale)
+	}
+
+	if _, err := cm.PutWithSoftTTL(3, 3, time.Second, time.Millisecond); err != IllegalArgError {
+		t.Errorf("PutWithSoftTTL with hard TTL < soft TTL, return %v, want IllegalArgError", err)
+	}
+	if _, err := cm.PutWithSoftTTL(3, 3, 0, time.Second); err != IllegalArgError {
+		t.Errorf("PutWithSoftTTL with zero soft TTL, return %v, want IllegalArgError", err)
+	}
+}
+
+func TestGetWithStaleConsistent(t *testing.T) {
+	cm := NewConcurrentMap()
+	hash, _ := hashKey(1, cm, false)
+	seg := cm.segmentFor(hash)
+
+	//the lock-free readers that hold the entry see its value and soft TTL together,
+	//so the entry isn't changed in place if the soft TTL changes
+	cm.PutWithSoftTTL(1, "stale", time.Nanosecond, 0)
+	e := seg.findUnderLock(1, hash)
+	cm.Put(1, "fresh")
+	if v, staleAt := e.Value(), e.staleAt; v != "stale" || staleAt == 0 {
+		t.Errorf("The held entry after Put, return %v, %v, want stale and its soft TTL", v, staleAt)
+	}
+	time.Sleep(time.Millisecond)
+	if v, stale, _ := cm.GetWithStale(1); v != "fresh" || stale {
+		t.Errorf("GetWithStale after Put, return %v, %v, want fresh, false", v, stale)
+	}
+
+	e = seg.findUnderLock(1, hash)
+	cm.PutWithSoftTTL(1, "stale", time.Nanosecond, 0)
+	if v, staleAt := e.Value(), e.staleAt; v != "fresh" || staleAt != 0 {
+		t.Errorf("The held entry after PutWithSoftTTL, return %v, %v, want fresh, 0", v, staleAt)
+	}
+}
@@ -0,0 +1,81 @@
+package concurrent
+
+/**
+ * EntryState is the lifecycle state of a key, see State.
+ */
+type EntryState int
+
+const (
+	//no mapping for the key and no load is running
+	ENTRY_ABSENT EntryState = iota
+	//the value is being loaded by ComputeIfAbsent
+	ENTRY_LOADING
+	//the mapping exists and has not expired
+	ENTRY_PRESENT
+	//the mapping has expired but has not been removed yet
+	ENTRY_EXPIRED
+)
+
+func (this EntryState) String() string {
+	switch this {
+	case ENTRY_ABSENT:
+		return "absent"
+	case ENTRY_LOADING:
+		return "loading"
+	case ENTRY_PRESENT:
+		return "present"
+	case ENTRY_EXPIRED:
+		return "expired"
+	}
+	return "unknown"
+}
+
+/**
+ * Returns the lifecycle state of key, so the callers can tell a key that is being loaded
+ * by ComputeIfAbsent from an absent key, e.g. to back off rather than load it again.
+ * A running load is reported even if the expired mapping of key still exists,
+ * and if WithCoalesceKeyFunc is used, the load of an equivalent key is reported too.
+ *
+ * The state may be changed by others right after it is returned.
+ */
+func (this *ConcurrentMap) State(key interface{}) (state EntryState, err error) {
+	if isNil(key) {
+		return ENTRY_ABSENT, NilKeyError
+	}
+	hash, err := hashKey(key, this, false)
+	if err != nil {
+		return
+	}
+	Printf("State, %v, %v\n", key, hash)
+	state = this.segmentFor(hash).state(key, hash)
+	if c := this.coalescer; c != nil && state == ENTRY_ABSENT && c.loading(key) {
+		state = ENTRY_LOADING
+	}
+	return
+}
+
+func (this *Segment) state(key interface{}, hash uint32) EntryState {
+	//the present mapping is found without lock
+	e := this.getFirst(hash)
+	for e != nil && (e.hash != hash || !equals(e.key, key)) {
+		e = e.next
+	}
+	if e != nil && !e.expired() {
+		return ENTRY_PRESENT
+	}
+
+	this.acquire()
+	defer this.lock.Unlock()
+	if this.findFlightUnderLock(key, hash) != nil {
+		return ENTRY_LOADING
+	}
+	for e = this.getFirst(hash); e != nil; e = e.next {
+		if e.hash == hash && equals(e.key, key) {
+			if e.expired() {
+				return ENTRY_EXPIRED
+			}
+			return ENTRY_PRESENT
+		}
+	}
+	return ENTRY_ABSENT
+}
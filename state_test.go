@@ -0,0 +1,48 @@
+package concurrent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestState(t *testing.T) {
+	cm := NewConcurrentMap()
+	if s, _ := cm.State(1); s != ENTRY_ABSENT {
+		t.Errorf("State of absent key, return %v, want absent", s)
+	}
+	cm.Put(1, 1)
+	if s, _ := cm.State(1); s != ENTRY_PRESENT {
+		t.Errorf("State of present key, return %v, want present", s)
+	}
+
+	//the expired mapping is kept until it is removed, it is not scheduled here
+	hash, _ := hashKey(2, cm, false)
+	cm.segmentFor(hash).putWithExpiration(2, hash, 2, false, nil, time.Now().Add(-time.Second).UnixNano())
+	if s, _ := cm.State(2); s != ENTRY_EXPIRED {
+		t.Errorf("State of expired key, return %v, want expired", s)
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cm.ComputeIfAbsent(3, func(key interface{}) (interface{}, error) {
+			close(started)
+			<-release
+			return 3, nil
+		})
+	}()
+	<-started
+	if s, _ := cm.State(3); s != ENTRY_LOADING {
+		t.Errorf("State of loading key, return %v, want loading", s)
+	}
+	close(release)
+	<-done
+	if s, _ := cm.State(3); s != ENTRY_PRESENT {
+		t.Errorf("State of loaded key, return %v, want present", s)
+	}
+
+	if _, err := cm.State(nil); err != NilKeyError {
+		t.Errorf("State of nil key, return %v, want NilKeyError", err)
+	}
+}
@@ -0,0 +1,179 @@
+package concurrent
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	//every power of 2 of nanoseconds is split into 1<<latencySubBits buckets like HDR histogram,
+	//so the relative error of a bucket is at most 25%
+	latencySubBits    = 2
+	latencySubBuckets = 1 << latencySubBits
+	latencyBuckets    = 64 * latencySubBuckets
+)
+
+//latencyBucket returns the index of bucket for the specified nanoseconds
+func latencyBucket(ns int64) int {
+	if ns < latencySubBuckets {
+		if ns < 0 {
+			return 0
+		}
+		return int(ns)
+	}
+	shift := bits.Len64(uint64(ns)) - latencySubBits - 1
+	return shift*latencySubBuckets + int(ns>>uint(shift))
+}
+
+//latencyBucketUpper returns the max nanoseconds in the bucket at index i
+func latencyBucketUpper(i int) int64 {
+	if i < latencySubBuckets {
+		return int64(i)
+	}
+	shift := uint(i/latencySubBuckets - 1)
+	mantissa := int64(i%latencySubBuckets + latencySubBuckets)
+	return (mantissa+1)<<shift - 1
+}
+
+type latencyHistogram struct {
+	count  int64
+	sum    int64
+	errors int64
+	counts [latencyBuckets]int64
+}
+
+func (this *latencyHistogram) since(start time.Time) {
+	ns := int64(time.Since(start))
+	atomic.AddInt64(&this.counts[latencyBucket(ns)], 1)
+	atomic.AddInt64(&this.sum, ns)
+	atomic.AddInt64(&this.count, 1)
+}
+
+//sinceWithError records the latency, and counts the failure if err isn't nil
+func (this *latencyHistogram) sinceWithError(start time.Time, err error) {
+	this.since(start)
+	if err != nil {
+		atomic.AddInt64(&this.errors, 1)
+	}
+}
+
+func (this *latencyHistogram) snapshot() (s LatencyStats) {
+	for i := range this.counts {
+		s.counts[i] = atomic.LoadInt64(&this.counts[i])
+		s.Count += s.counts[i]
+	}
+	s.Sum = time.Duration(atomic.LoadInt64(&this.sum))
+	s.Errors = atomic.LoadInt64(&this.errors)
+	return
+}
+
+type latencyRecorder struct {
+	get, put, remove     latencyHistogram
+	storeLoad, storeSave latencyHistogram
+}
+
+/**
+ * LatencyStats is a snapshot of the latency histogram of an operation.
+ * The latencies are recorded in the buckets like HDR histogram,
+ * every power of 2 of nanoseconds is split into 4 buckets.
+ */
+type LatencyStats struct {
+	Count int64
+	Sum   time.Duration
+	//the number of failed operations, it is only recorded for the calls of backing stores
+	Errors int64
+	counts [latencyBuckets]int64
+}
+
+/**
+ * Returns the mean latency, or 0 if no operation was recorded.
+ */
+func (this *LatencyStats) Mean() time.Duration {
+	if this.Count == 0 {
+		return 0
+	}
+	return this.Sum / time.Duration(this.Count)
+}
+
+/**
+ * Returns the fraction of failed operations, or 0 if no operation was recorded.
+ */
+func (this *LatencyStats) ErrorRate() float64 {
+	if this.Count == 0 {
+		return 0
+	}
+	return float64(this.Errors) / float64(this.Count)
+}
+
+/**
+ * Returns the latency at the specified quantile, e.g. 0.99 returns the p99 latency.
+ * The result is the upper bound of the bucket that the quantile falls in,
+ * so it is at most 25% higher than the real latency.
+ *
+ * @param q the quantile in [0, 1]
+ */
+func (this *LatencyStats) Quantile(q float64) time.Duration {
+	if q < 0 || q > 1 {
+		panic(IllegalArgError)
+	}
+	if this.Count == 0 {
+		return 0
+	}
+	rank := int64(q*float64(this.Count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, c := range this.counts {
+		if seen += c; seen >= rank {
+			return time.Duration(latencyBucketUpper(i))
+		}
+	}
+	return time.Duration(latencyBucketUpper(latencyBuckets - 1))
+}
+
+/**
+ * Stats includes the latency histograms of the operations of map.
+ * Get includes Get, Put includes Put, PutIfAbsent and PutWithTTL,
+ * and Remove includes Remove and RemoveEntry.
+ * StoreLoad and StoreSave are the calls of the backing stores wrapped by InstrumentStore,
+ * they are recorded separately so the slowness of stores isn't attributed to the map.
+ */
+type Stats struct {
+	//the name of map, see WithName
+	Name      string
+	Get       LatencyStats
+	Put       LatencyStats
+	Remove    LatencyStats
+	StoreLoad LatencyStats
+	StoreSave LatencyStats
+}
+
+/**
+ * Returns an Option that enables the latency histograms of operations, see Stats.
+ * Recording costs two clock reads and three atomic adds per operation.
+ */
+func WithLatencyStats() Option {
+	return func(m *ConcurrentMap) {
+		m.latency = new(latencyRecorder)
+	}
+}
+
+/**
+ * Returns the snapshot of latency histograms of operations.
+ *
+ * @return IllegalStateError if WithLatencyStats isn't enabled
+ */
+func (this *ConcurrentMap) Stats() (stats Stats, err error) {
+	if this.latency == nil {
+		return stats, IllegalStateError
+	}
+	stats.Name = this.name
+	stats.Get = this.latency.get.snapshot()
+	stats.Put = this.latency.put.snapshot()
+	stats.Remove = this.latency.remove.snapshot()
+	stats.StoreLoad = this.latency.storeLoad.snapshot()
+	stats.StoreSave = this.latency.storeSave.snapshot()
+	return
+}
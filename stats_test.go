@@ -0,0 +1,48 @@
+package concurrent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyBucket(t *testing.T) {
+	last := -1
+	for _, ns := range []int64{0, 1, 3, 4, 7, 8, 15, 16, 1000, 1 << 40, 1 << 61} {
+		i := latencyBucket(ns)
+		if i < last || i >= latencyBuckets {
+			t.Errorf("latencyBucket %v, return %v, want in [%v, %v)", ns, i, last, latencyBuckets)
+		}
+		if upper := latencyBucketUpper(i); upper < ns || (ns > 0 && upper > ns+ns/2) {
+			t.Errorf("latencyBucketUpper %v for %v, return %v", i, ns, upper)
+		}
+		last = i
+	}
+}
+
+func TestStats(t *testing.T) {
+	cm := NewConcurrentMap()
+	if _, err := cm.Stats(); err != IllegalStateError {
+		t.Errorf("Stats without option, return %v, want IllegalStateError", err)
+	}
+
+	cm = NewConcurrentMap(WithLatencyStats())
+	for i := 0; i < 100; i++ {
+		cm.Put(i, i)
+		cm.Get(i)
+	}
+	cm.Remove(1)
+
+	stats, err := cm.Stats()
+	if err != nil {
+		t.Errorf("Stats, return %v, want nil", err)
+	}
+	if stats.Get.Count != 100 || stats.Put.Count != 100 || stats.Remove.Count != 1 {
+		t.Errorf("Stats counts, return %v, %v, %v, want 100, 100, 1", stats.Get.Count, stats.Put.Count, stats.Remove.Count)
+	}
+	if p50, p99 := stats.Put.Quantile(0.5), stats.Put.Quantile(0.99); p50 <= 0 || p99 < p50 || p99 > time.Second {
+		t.Errorf("Quantile of Put, return p50 %v, p99 %v", p50, p99)
+	}
+	if m := stats.Get.Mean(); m <= 0 {
+		t.Errorf("Mean of Get, return %v, want > 0", m)
+	}
+}
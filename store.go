@@ -0,0 +1,158 @@
+package concurrent
+
+import (
+	"time"
+)
+
+/**
+ * Store is a tier of cache or a backing store, e.g. a map, a disk cache or the origin.
+ * Load returns nil value if key isn't found. All methods must be safe for concurrent use.
+ */
+type Store interface {
+	Load(key interface{}) (value interface{}, err error)
+	Save(key interface{}, value interface{}) (err error)
+}
+
+/**
+ * LoaderFunc adapts a function to a read-only Store, it is usually the origin of Chain.
+ * Save does nothing.
+ */
+type LoaderFunc func(key interface{}) (value interface{}, err error)
+
+func (this LoaderFunc) Load(key interface{}) (value interface{}, err error) {
+	return this(key)
+}
+
+func (this LoaderFunc) Save(key interface{}, value interface{}) (err error) {
+	return nil
+}
+
+//mapStore adapts a Map to Store
+type mapStore struct {
+	m Map
+}
+
+/**
+ * Returns a Store that loads from and saves to m.
+ */
+func MapStore(m Map) Store {
+	if m == nil {
+		panic(IllegalArgError)
+	}
+	return mapStore{m}
+}
+
+func (this mapStore) Load(key interface{}) (value interface{}, err error) {
+	return this.m.Get(key)
+}
+
+func (this mapStore) Save(key interface{}, value interface{}) (err error) {
+	_, err = this.m.Put(key, value)
+	return
+}
+
+/**
+ * Chain composes the Stores into a read-through lookup chain,
+ * e.g. L1 map -> L2 map -> disk -> origin loader, it is a ready-made multi-level cache.
+ * A Chain is also a Store, so it can be a tier of another Chain.
+ */
+type Chain struct {
+	tiers []Store
+}
+
+/**
+ * Creates a Chain, the tiers are looked up in the order of arguments.
+ */
+func NewChain(tiers ...Store) *Chain {
+	if len(tiers) == 0 {
+		panic(IllegalArgError)
+	}
+	for _, t := range tiers {
+		if t == nil {
+			panic(IllegalArgError)
+		}
+	}
+	return &Chain{tiers: append([]Store(nil), tiers...)}
+}
+
+/**
+ * Looks up the tiers in order until the value is found,
+ * and saves the value to all upper tiers that missed it.
+ * The concurrent misses of same key are not merged, every one looks up the lower tiers.
+ *
+ * @return nil if no tier has key. The error of a tier stops the lookup,
+ * and the error of populating an upper tier is returned with the found value.
+ */
+func (this *Chain) Load(key interface{}) (value interface{}, err error) {
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+	for i, t := range this.tiers {
+		if value, err = t.Load(key); err != nil {
+			return nil, err
+		}
+		if value != nil {
+			for j := i - 1; j >= 0; j-- {
+				if e := this.tiers[j].Save(key, value); e != nil && err == nil {
+					err = e
+				}
+			}
+			return
+		}
+	}
+	return
+}
+
+/**
+ * Saves the value to all tiers from the lowest one, so an upper tier never has a value
+ * that lower tiers don't have. It stops at the first error.
+ */
+func (this *Chain) Save(key interface{}, value interface{}) (err error) {
+	if isNil(key) {
+		return NilKeyError
+	}
+	if isNil(value) {
+		return NilValueError
+	}
+	for i := len(this.tiers) - 1; i >= 0; i-- {
+		if err = this.tiers[i].Save(key, value); err != nil {
+			return
+		}
+	}
+	return
+}
+
+//instrumentedStore records the latencies and errors of the calls of a Store, see InstrumentStore
+type instrumentedStore struct {
+	s       Store
+	latency *latencyRecorder
+}
+
+/**
+ * Returns a Store that delegates to s and records the latencies and failures of its calls
+ * in StoreLoad and StoreSave of Stats, e.g. for the origin of a read-through Chain.
+ * It returns s itself if WithLatencyStats isn't enabled.
+ */
+func (this *ConcurrentMap) InstrumentStore(s Store) Store {
+	if s == nil {
+		panic(IllegalArgError)
+	}
+	if this.latency == nil {
+		return s
+	}
+	return &instrumentedStore{s, this.latency}
+}
+
+func (this *instrumentedStore) Load(key interface{}) (value interface{}, err error) {
+	defer func(start time.Time) {
+		this.latency.storeLoad.sinceWithError(start, err)
+	}(time.Now())
+	return this.s.Load(key)
+}
+
+func (this *instrumentedStore) Save(key interface{}, value interface{}) (err error) {
+	defer func(start time.Time) {
+		this.latency.storeSave.sinceWithError(start, err)
+	}(time.Now())
+	return this.s.Save(key, value)
+}
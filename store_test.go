@@ -0,0 +1,95 @@
+package concurrent
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	l1, l2 := NewConcurrentMap(), NewConcurrentMap()
+	loads := 0
+	origin := LoaderFunc(func(key interface{}) (interface{}, error) {
+		loads++
+		if key == -1 {
+			return nil, errors.New("origin failed")
+		}
+		if key.(int) >= 100 {
+			return nil, nil
+		}
+		return key.(int) * 10, nil
+	})
+	c := NewChain(MapStore(l1), MapStore(l2), origin)
+
+	if v, err := c.Load(1); v != 10 || err != nil {
+		t.Errorf("Load from origin, return %v, %v, want 10, nil", v, err)
+	}
+	if v1, _ := l1.Get(1); v1 != 10 {
+		t.Errorf("Get L1 after Load, return %v, want 10", v1)
+	}
+	if v2, _ := l2.Get(1); v2 != 10 {
+		t.Errorf("Get L2 after Load, return %v, want 10", v2)
+	}
+
+	//hit on L2 populates L1 only
+	l2.Put(2, 200)
+	if v, _ := c.Load(2); v != 200 || loads != 1 {
+		t.Errorf("Load hit on L2, return %v with %v loads, want 200 with 1 load", v, loads)
+	}
+	if v1, _ := l1.Get(2); v1 != 200 {
+		t.Errorf("Get L1 after hit on L2, return %v, want 200", v1)
+	}
+
+	if v, err := c.Load(100); v != nil || err != nil {
+		t.Errorf("Load absent key, return %v, %v, want nil, nil", v, err)
+	}
+	if _, err := c.Load(-1); err == nil {
+		t.Errorf("Load with failed origin, return nil, want error")
+	}
+
+	if err := c.Save(3, 30); err != nil {
+		t.Errorf("Save, return %v, want nil", err)
+	}
+	if v1, _ := l1.Get(3); v1 != 30 {
+		t.Errorf("Get L1 after Save, return %v, want 30", v1)
+	}
+
+	//chains can be nested
+	if v, _ := NewChain(NewChain(MapStore(NewConcurrentMap()), MapStore(l2))).Load(3); v != 30 {
+		t.Errorf("Load from nested chain, return %v, want 30", v)
+	}
+}
+
+func TestInstrumentStore(t *testing.T) {
+	cm := NewConcurrentMap(WithLatencyStats())
+	origin := cm.InstrumentStore(LoaderFunc(func(key interface{}) (interface{}, error) {
+		time.Sleep(time.Millisecond)
+		if key == -1 {
+			return nil, errors.New("origin failed")
+		}
+		return key, nil
+	}))
+	c := NewChain(MapStore(cm), origin)
+	for i := 0; i < 4; i++ {
+		c.Load(i)
+	}
+	c.Load(-1)
+	c.Save(5, 5)
+
+	stats, _ := cm.Stats()
+	if l := stats.StoreLoad; l.Count != 5 || l.Errors != 1 || l.ErrorRate() != 0.2 || l.Mean() < time.Millisecond {
+		t.Errorf("StoreLoad, return count %v, errors %v, mean %v, want 5, 1, >= 1ms", l.Count, l.Errors, l.Mean())
+	}
+	if l := stats.StoreSave; l.Count != 1 || l.Errors != 0 {
+		t.Errorf("StoreSave, return count %v, errors %v, want 1, 0", l.Count, l.Errors)
+	}
+	//the in-memory latencies are recorded separately
+	if stats.Get.Count != 5 || stats.Get.Mean() >= time.Millisecond {
+		t.Errorf("Get, return count %v, mean %v, want 5, < 1ms", stats.Get.Count, stats.Get.Mean())
+	}
+
+	s := LoaderFunc(func(key interface{}) (interface{}, error) { return nil, nil })
+	if NewConcurrentMap().InstrumentStore(s) == nil {
+		t.Errorf("InstrumentStore without stats, return nil, want the store itself")
+	}
+}
@@ -0,0 +1,129 @@
+package concurrent
+
+import (
+	"sync/atomic"
+)
+
+/**
+ * Maps the specified key to the specified value with a user-defined tag, both are set
+ * atomically under lock. So the applications can mark the entries, e.g. dirty, pinned
+ * or generation, without wrapping every value type. Neither the key nor the value can be nil.
+ *
+ * The tag of a new mapping is 0, other writes keep the tag of mapping.
+ * Like Put, PutWithTag clears the expiration time of key.
+ *
+ * @return the previous value associated with key, or
+ *         nil if there was no mapping for key
+ */
+func (this *ConcurrentMap) PutWithTag(key interface{}, value interface{}, tag uint64) (oldVal interface{}, err error) {
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+	if isNil(value) {
+		return nil, NilValueError
+	}
+	if e := this.validate(key, value); e != nil {
+		return nil, e
+	}
+
+	if hash, e := hashKey(key, this, false); e != nil {
+		err = e
+	} else {
+		Printf("PutWithTag, %v, %v, %v\n", key, hash, tag)
+		seg := this.segmentFor(hash)
+		seg.acquire()
+		defer seg.lock.Unlock()
+		oldVal = seg.putUnderLock(key, hash, value, false, nil, 0)
+		atomic.StoreUint64(&seg.findUnderLock(key, hash).tag, tag)
+	}
+	return
+}
+
+/**
+ * Returns the value and tag of the mapping for the specified key, they are read
+ * under lock, so they are consistent. The value is nil if there was no mapping for key.
+ */
+func (this *ConcurrentMap) GetWithTag(key interface{}) (value interface{}, tag uint64, err error) {
+	if isNil(key) {
+		return nil, 0, NilKeyError
+	}
+
+	if hash, e := hashKey(key, this, false); e != nil {
+		err = e
+	} else {
+		Printf("GetWithTag, %v, %v\n", key, hash)
+		seg := this.segmentFor(hash)
+		seg.acquire()
+		if e := seg.findUnderLock(key, hash); e != nil {
+			value, tag = e.fastValue(), e.tag
+		}
+		seg.lock.Unlock()
+		value = this.decode(value)
+	}
+	return
+}
+
+/**
+ * Returns the tag of the mapping for the specified key without lock,
+ * ok is false if there was no mapping for key.
+ */
+func (this *ConcurrentMap) Tag(key interface{}) (tag uint64, ok bool, err error) {
+	if isNil(key) {
+		return 0, false, NilKeyError
+	}
+
+	if hash, e := hashKey(key, this, false); e != nil {
+		err = e
+	} else {
+		Printf("Tag, %v, %v\n", key, hash)
+		if e := this.segmentFor(hash).find(key, hash); e != nil && !e.expired() {
+			tag, ok = atomic.LoadUint64(&e.tag), true
+		}
+	}
+	return
+}
+
+/**
+ * Sets the tag of the mapping for the specified key.
+ *
+ * @return true if the tag be set, false if there was no mapping for key
+ */
+func (this *ConcurrentMap) SetTag(key interface{}, tag uint64) (ok bool, err error) {
+	return this.updateTag(key, func(old uint64) (uint64, bool) {
+		return tag, true
+	})
+}
+
+/**
+ * Sets the tag of the mapping for the specified key if the current tag is oldTag.
+ *
+ * @return true if the tag be set, false if there was no mapping for key or the tag isn't oldTag
+ */
+func (this *ConcurrentMap) CompareAndSetTag(key interface{}, oldTag uint64, newTag uint64) (ok bool, err error) {
+	return this.updateTag(key, func(old uint64) (uint64, bool) {
+		return newTag, old == oldTag
+	})
+}
+
+func (this *ConcurrentMap) updateTag(key interface{}, f func(old uint64) (tag uint64, ok bool)) (ok bool, err error) {
+	if isNil(key) {
+		return false, NilKeyError
+	}
+
+	if hash, e := hashKey(key, this, false); e != nil {
+		err = e
+	} else {
+		Printf("SetTag, %v, %v\n", key, hash)
+		seg := this.segmentFor(hash)
+		//the entries are cloned under lock, so the tag must be set under lock too
+		seg.acquire()
+		defer seg.lock.Unlock()
+		if e := seg.findUnderLock(key, hash); e != nil {
+			var tag uint64
+			if tag, ok = f(e.tag); ok {
+				atomic.StoreUint64(&e.tag, tag)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,36 @@
+package concurrent
+
+import (
+	"testing"
+)
+
+func TestTag(t *testing.T) {
+	cm := NewConcurrentMap()
+	cm.PutWithTag("a", 1, 7)
+	if v, tag, err := cm.GetWithTag("a"); v != 1 || tag != 7 || err != nil {
+		t.Errorf("GetWithTag a, return %v, %v, %v, want 1, 7, nil", v, tag, err)
+	}
+
+	//the writes keep the tag
+	cm.Put("a", "x")
+	if tag, ok, _ := cm.Tag("a"); tag != 7 || !ok {
+		t.Errorf("Tag a after Put, return %v, %v, want 7, true", tag, ok)
+	}
+
+	if ok, _ := cm.CompareAndSetTag("a", 1, 8); ok {
+		t.Errorf("CompareAndSetTag with wrong tag, return true, want false")
+	}
+	if ok, _ := cm.CompareAndSetTag("a", 7, 8); !ok {
+		t.Errorf("CompareAndSetTag, return false, want true")
+	}
+	if ok, _ := cm.SetTag("c", 1); ok {
+		t.Errorf("SetTag absent key, return true, want false")
+	}
+	cm.Put("c", 3)
+	if tag, ok, _ := cm.Tag("c"); tag != 0 || !ok {
+		t.Errorf("Tag of new mapping, return %v, %v, want 0, true", tag, ok)
+	}
+	if v, tag, _ := cm.GetWithTag("a"); v != "x" || tag != 8 {
+		t.Errorf("GetWithTag a, return %v, %v, want x, 8", v, tag)
+	}
+}
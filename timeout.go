@@ -0,0 +1,149 @@
+package concurrent
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	//the maximum interval between two attempts of tryAcquire
+	maxAcquireBackoff = time.Millisecond
+)
+
+/**
+ * Tries to acquire the lock of segment until timeout elapses,
+ * the lock is polled with an exponential backoff because sync.Mutex has no timed lock.
+ *
+ * @return true if the lock was acquired
+ */
+func (this *Segment) tryAcquire(timeout time.Duration) bool {
+	if this.lock.TryLock() {
+		return true
+	}
+	atomic.AddInt32(&this.contended, 1)
+
+	deadline := time.Now().Add(timeout)
+	backoff := time.Microsecond
+	for spins := 0; ; spins++ {
+		if this.lock.TryLock() {
+			return true
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false
+		}
+		if spins < 4 {
+			runtime.Gosched()
+			continue
+		}
+		if backoff > remaining {
+			backoff = remaining
+		}
+		time.Sleep(backoff)
+		if backoff < maxAcquireBackoff {
+			backoff <<= 1
+		}
+	}
+}
+
+/**
+ * Same as Get, but if the key is absent and a load of ComputeIfAbsent is running for it,
+ * waits for the load and returns the loaded value. It returns TimeoutError if the lock of segment
+ * cannot be acquired to find the load, or the load doesn't finish, within timeout.
+ * It is used by the request paths with strict SLO, which prefer a timeout to a miss
+ * while the value is being loaded, but must not wait for a slow loader.
+ *
+ * @return the value, or nil if the key is absent and no load is running or the load failed
+ */
+func (this *ConcurrentMap) GetWithTimeout(key interface{}, timeout time.Duration) (value interface{}, err error) {
+	if l := this.latency; l != nil {
+		defer l.get.since(time.Now())
+	}
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+
+	hash, err := hashKey(key, this, false)
+	if err != nil {
+		return
+	}
+	Printf("GetWithTimeout, %v, %v\n", key, hash)
+	seg := this.segmentFor(hash)
+	if h := this.hotKeys; h != nil {
+		if v, ok := h.get(seg, key, hash); ok {
+			return this.decode(v), nil
+		}
+	}
+	if v := seg.get(key, hash); v != nil {
+		return this.decode(v), nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	f, err := this.findLoad(seg, key, hash, timeout)
+	if f == nil || err != nil {
+		return
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-f.done:
+		//the loaded value has been put before the load is done,
+		//but a coalesced load only puts the value for the keys of its callers
+		if value = seg.get(key, hash); value == nil && f.err == nil {
+			value = f.value
+		}
+		return this.decode(value), nil
+	case <-timer.C:
+		return nil, TimeoutError
+	}
+}
+
+//findLoad returns the running load of key, it waits for the lock of segment at most timeout
+func (this *ConcurrentMap) findLoad(seg *Segment, key interface{}, hash uint32, timeout time.Duration) (f *flight, err error) {
+	if c := this.coalescer; c != nil {
+		ck := c.keyFunc(key)
+		c.lock.Lock()
+		f = c.inflight[ck]
+		c.lock.Unlock()
+		return
+	}
+	if !seg.tryAcquire(timeout) {
+		return nil, TimeoutError
+	}
+	f = seg.findFlightUnderLock(key, hash)
+	seg.lock.Unlock()
+	return
+}
+
+/**
+ * Same as Put, but returns TimeoutError and does nothing
+ * if the lock of segment cannot be acquired within timeout.
+ * It is used by the request paths with strict SLO.
+ */
+func (this *ConcurrentMap) PutWithTimeout(key interface{}, value interface{}, timeout time.Duration) (oldVal interface{}, err error) {
+	if l := this.latency; l != nil {
+		defer l.put.since(time.Now())
+	}
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+	if isNil(value) {
+		return nil, NilValueError
+	}
+	if e := this.validate(key, value); e != nil {
+		return nil, e
+	}
+
+	hash, err := hashKey(key, this, false)
+	if err != nil {
+		return
+	}
+	Printf("PutWithTimeout, %v, %v\n", key, hash)
+	seg := this.segmentFor(hash)
+	if !seg.tryAcquire(timeout) {
+		return nil, TimeoutError
+	}
+	defer seg.lock.Unlock()
+	return seg.putUnderLock(key, hash, value, false, nil, 0), nil
+}
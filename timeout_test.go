@@ -0,0 +1,81 @@
+package concurrent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	cm := NewConcurrentMap()
+	if _, err := cm.PutWithTimeout(1, 1, time.Millisecond); err != nil {
+		t.Errorf("PutWithTimeout, return %v, want nil", err)
+	}
+	if v, err := cm.GetWithTimeout(1, time.Millisecond); v != 1 || err != nil {
+		t.Errorf("GetWithTimeout, return %v, %v, want 1, nil", v, err)
+	}
+
+	locked, _ := cm.LockSegmentOf(1)
+	start := time.Now()
+	if _, err := cm.PutWithTimeout(1, 2, 20*time.Millisecond); err != TimeoutError {
+		t.Errorf("PutWithTimeout to locked segment, return %v, want TimeoutError", err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("PutWithTimeout to locked segment, return after %v, want >= 20ms", d)
+	}
+	//Get of present key does not need the lock
+	if v, err := cm.GetWithTimeout(1, time.Millisecond); v != 1 || err != nil {
+		t.Errorf("GetWithTimeout from locked segment, return %v, %v, want 1, nil", v, err)
+	}
+	//but the lock is needed to find the running load of absent key
+	hash1, _ := hashKey(1, cm, false)
+	absent := 2
+	for hash, _ := hashKey(absent, cm, false); cm.segmentFor(hash) != cm.segmentFor(hash1); hash, _ = hashKey(absent, cm, false) {
+		absent++
+	}
+	if _, err := cm.GetWithTimeout(absent, time.Millisecond); err != TimeoutError {
+		t.Errorf("GetWithTimeout absent key from locked segment, return %v, want TimeoutError", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		locked.Unlock()
+	}()
+	if old, err := cm.PutWithTimeout(1, 2, time.Second); old != 1 || err != nil {
+		t.Errorf("PutWithTimeout after Unlock, return %v, %v, want 1, nil", old, err)
+	}
+	if _, err := cm.PutWithTimeout(nil, 2, time.Second); err != NilKeyError {
+		t.Errorf("PutWithTimeout nil key, return %v, want NilKeyError", err)
+	}
+}
+
+func TestGetWithTimeoutWaitsForLoad(t *testing.T) {
+	cm := NewConcurrentMap()
+	if v, err := cm.GetWithTimeout(1, time.Millisecond); v != nil || err != nil {
+		t.Errorf("GetWithTimeout absent key, return %v, %v, want nil, nil", v, err)
+	}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go cm.ComputeIfAbsent(1, func(key interface{}) (interface{}, error) {
+		close(started)
+		<-release
+		return "loaded", nil
+	})
+	<-started
+
+	start := time.Now()
+	if _, err := cm.GetWithTimeout(1, 20*time.Millisecond); err != TimeoutError {
+		t.Errorf("GetWithTimeout while loading, return %v, want TimeoutError", err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("GetWithTimeout while loading, return after %v, want >= 20ms", d)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	if v, err := cm.GetWithTimeout(1, time.Second); v != "loaded" || err != nil {
+		t.Errorf("GetWithTimeout until loaded, return %v, %v, want loaded, nil", v, err)
+	}
+}
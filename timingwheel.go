@@ -0,0 +1,183 @@
+package concurrent
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	wheelBits   = 6
+	wheelSize   = 1 << wheelBits
+	wheelMask   = wheelSize - 1
+	wheelLevels = 4
+)
+
+//wheelTimer records the key that should be checked when the deadline tick is reached.
+//Timer does not point to Entry, because Entry may be cloned by rehash and remove,
+//so the callback must find the entry by key and recheck its expiration time.
+type wheelTimer struct {
+	key      interface{}
+	hash     uint32
+	deadline int64
+}
+
+/**
+ * timingWheel is a hierarchical timing wheel, it is used to schedule the expiration of entries.
+ * Level 0 has wheelSize slots and every slot is one tick, every slot of level n covers
+ * all slots of level n-1. Adding a timer is O(1), when current tick reaches a slot of level n,
+ * the timers in this slot will be cascaded to level n-1.
+ *
+ * The timing wheel runs a goroutine only if there are timers in wheel.
+ */
+type timingWheel struct {
+	lock    sync.Mutex
+	tick    int64 //nanoseconds of a tick
+	current int64 //current tick, all timers before current tick were fired
+	count   int
+	running bool
+	slots   [wheelLevels][wheelSize][]*wheelTimer
+
+	//onExpire is called with the timers that deadline is reached, without holding lock
+	onExpire func(timers []*wheelTimer)
+	//the goroutine of wheel exits when done is closed
+	done <-chan struct{}
+}
+
+func newTimingWheel(tick time.Duration, onExpire func(timers []*wheelTimer), done <-chan struct{}) *timingWheel {
+	return &timingWheel{
+		tick:     int64(tick),
+		onExpire: onExpire,
+		done:     done,
+	}
+}
+
+/**
+ * Schedules a timer for key, the timer will be fired at first tick after expireAt.
+ *
+ * @param expireAt the unix time in nanoseconds
+ */
+func (this *timingWheel) schedule(key interface{}, hash uint32, expireAt int64) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	select {
+	case <-this.done:
+		//the wheel has been stopped
+		return
+	default:
+	}
+
+	if !this.running {
+		//no timer in wheel, so can move current tick to now directly
+		this.current = time.Now().UnixNano() / this.tick
+		this.running = true
+		go this.run()
+	}
+	this.add(&wheelTimer{key, hash, expireAt/this.tick + 1})
+	this.count++
+}
+
+//add puts the timer into the slot it belongs to, call only while holding lock
+func (this *timingWheel) add(t *wheelTimer) {
+	if t.deadline <= this.current {
+		t.deadline = this.current + 1
+	}
+	for level := uint(0); level < wheelLevels; level++ {
+		shift := wheelBits * level
+		if (t.deadline>>shift)-(this.current>>shift) < wheelSize {
+			slot := &this.slots[level][(t.deadline>>shift)&wheelMask]
+			*slot = append(*slot, t)
+			return
+		}
+	}
+	//the deadline is out of range of wheel,
+	//puts it into the farthest slot and it will be re-added when this slot is cascaded
+	shift := uint(wheelBits * (wheelLevels - 1))
+	slot := &this.slots[wheelLevels-1][((this.current>>shift)+wheelMask)&wheelMask]
+	*slot = append(*slot, t)
+}
+
+//advance moves current tick to specified tick and returns the timers that be fired,
+//call only while holding lock
+func (this *timingWheel) advance(to int64) (fired []*wheelTimer) {
+	for this.current < to {
+		this.current++
+		//cascade the higher levels first, so the timers re-added to the lower level slot
+		//that is cascading now can be cascaded again
+		for level := uint(wheelLevels - 1); level > 0; level-- {
+			shift := wheelBits * level
+			if this.current&(1<<shift-1) == 0 {
+				slot := &this.slots[level][(this.current>>shift)&wheelMask]
+				timers := *slot
+				*slot = nil
+				for _, t := range timers {
+					this.add(t)
+				}
+			}
+		}
+
+		slot := &this.slots[0][this.current&wheelMask]
+		if len(*slot) > 0 {
+			fired = append(fired, (*slot)...)
+			this.count -= len(*slot)
+			*slot = nil
+		}
+	}
+	return
+}
+
+func (this *timingWheel) run() {
+	ticker := time.NewTicker(time.Duration(this.tick))
+	defer ticker.Stop()
+
+	for {
+		var now time.Time
+		select {
+		case now = <-ticker.C:
+		case <-this.done:
+			this.lock.Lock()
+			this.running = false
+			this.lock.Unlock()
+			return
+		}
+
+		this.lock.Lock()
+		fired := this.advance(now.UnixNano() / this.tick)
+		this.lock.Unlock()
+
+		if len(fired) > 0 {
+			this.onExpire(fired)
+		}
+
+		this.lock.Lock()
+		if this.count == 0 {
+			this.running = false
+			this.lock.Unlock()
+			return
+		}
+		this.lock.Unlock()
+	}
+}
+
+/**
+ * Calls f with the timers of every slot until f returns false, level by level
+ * and in the order of deadline within a level. The deadlines of timers in a slot of level 0
+ * are same, but the timers in a slot of higher levels are not sorted, and a slot of higher level
+ * may hold earlier deadlines than the slots of lower level that were added later.
+ * The timers must not be changed by f.
+ */
+func (this *timingWheel) walk(f func(timers []*wheelTimer) bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for level := uint(0); level < wheelLevels; level++ {
+		shift := wheelBits * level
+		//the slot of current tick is empty in all levels, see add
+		for i := int64(1); i <= wheelMask; i++ {
+			slot := this.slots[level][((this.current>>shift)+i)&wheelMask]
+			if len(slot) > 0 && !f(slot) {
+				return
+			}
+		}
+	}
+}
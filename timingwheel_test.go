@@ -0,0 +1,246 @@
+package concurrent
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTimingWheelAdvance(t *testing.T) {
+	tw := newTimingWheel(time.Millisecond, nil, nil)
+	tw.current = 100
+
+	deadlines := []int64{101, 163, 164, 165, 100 + wheelSize*wheelSize + 7, 100 + 3*wheelSize*wheelSize*wheelSize + 5,
+		//out of range of wheel
+		100 + 2*wheelSize*wheelSize*wheelSize*wheelSize + 3}
+	for i, d := range deadlines {
+		tw.add(&wheelTimer{key: i, deadline: d})
+	}
+	//deadline before current tick will be fired at next tick
+	tw.add(&wheelTimer{key: -1, deadline: 50})
+
+	fired := map[interface{}]int64{}
+	for tw.current < deadlines[len(deadlines)-1] {
+		for _, timer := range tw.advance(tw.current + 1) {
+			fired[timer.key] = tw.current
+		}
+	}
+
+	for i, d := range deadlines {
+		if fired[i] != d {
+			t.Errorf("Timer %v with deadline %v, fired at %v", i, d, fired[i])
+		}
+	}
+	if fired[-1] != 101 {
+		t.Errorf("Timer with expired deadline 50, fired at %v, want 101", fired[-1])
+	}
+}
+
+func TestPutWithTTL(t *testing.T) {
+	cm := NewConcurrentMap()
+	cm.PutWithTTL(1, 10, 50*time.Millisecond)
+	cm.PutWithTTL(2, 20, time.Hour)
+	cm.PutWithTTL(3, 30, 0)
+
+	if v, err := cm.Get(1); v != 10 || err != nil {
+		t.Errorf("Get 1 before expiration, return %v, %v, want 10, nil", v, err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if v, _ := cm.Get(1); v != nil {
+		t.Errorf("Get 1 after expiration, return %v, want nil", v)
+	}
+	if ok, _ := cm.ContainsKey(1); ok {
+		t.Errorf("ContainsKey 1 after expiration, return true, want false")
+	}
+	if v, _ := cm.Get(2); v != 20 {
+		t.Errorf("Get 2, return %v, want 20", v)
+	}
+
+	//expired entry is removed by timing wheel
+	time.Sleep(3 * EXPIRATION_TICK)
+	if s := cm.Size(); s != 2 {
+		t.Errorf("Get size after expiration, return %v, want 2", s)
+	}
+	if kvs := cm.ToSlice(); len(kvs) != 2 {
+		t.Errorf("ToSlice after expiration, return %v, want 2 entries", kvs)
+	}
+
+	//Put clears expiration time
+	cm.PutWithTTL(4, 40, 20*time.Millisecond)
+	if previous, _ := cm.Put(4, 41); previous != 40 {
+		t.Errorf("Put 4, return %v, want 40", previous)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if v, _ := cm.Get(4); v != 41 {
+		t.Errorf("Get 4 after Put, return %v, want 41", v)
+	}
+
+	//PutIfAbsent stores value if mapping has expired
+	cm.PutWithTTL(5, 50, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if previous, _ := cm.PutIfAbsent(5, 51); previous != nil {
+		t.Errorf("PutIfAbsent 5 after expiration, return %v, want nil", previous)
+	}
+	if v, _ := cm.Get(5); v != 51 {
+		t.Errorf("Get 5 after PutIfAbsent, return %v, want 51", v)
+	}
+}
+
+func TestTTLAndExpireAt(t *testing.T) {
+	cm := NewConcurrentMap()
+	cm.Put(1, 10)
+	cm.PutWithTTL(2, 20, time.Hour)
+
+	if d, ok, err := cm.TTL(1); d >= 0 || !ok || err != nil {
+		t.Errorf("TTL 1, return %v, %v, %v, want negative duration, true, nil", d, ok, err)
+	}
+	if d, ok, _ := cm.TTL(2); d <= 59*time.Minute || d > time.Hour || !ok {
+		t.Errorf("TTL 2, return %v, %v, want about 1h, true", d, ok)
+	}
+	if _, ok, _ := cm.TTL(3); ok {
+		t.Errorf("TTL 3, return true, want false")
+	}
+
+	if ok, err := cm.ExpireAt(1, time.Now().Add(20*time.Millisecond)); !ok || err != nil {
+		t.Errorf("ExpireAt 1, return %v, %v, want true, nil", ok, err)
+	}
+	if ok, _ := cm.ExpireAt(3, time.Now()); ok {
+		t.Errorf("ExpireAt 3, return true, want false")
+	}
+	//zero time clears expiration time
+	if ok, _ := cm.ExpireAt(2, time.Time{}); !ok {
+		t.Errorf("ExpireAt 2 with zero time, return false, want true")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, ok, _ := cm.TTL(1); ok {
+		t.Errorf("TTL 1 after expiration, return true, want false")
+	}
+	if d, ok, _ := cm.TTL(2); d >= 0 || !ok {
+		t.Errorf("TTL 2 after clearing expiration time, return %v, %v, want negative duration, true", d, ok)
+	}
+	if ok, _ := cm.ExpireAt(1, time.Now().Add(time.Hour)); ok {
+		t.Errorf("ExpireAt 1 after expiration, return true, want false")
+	}
+}
+
+func TestExpired(t *testing.T) {
+	cm := NewConcurrentMap()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := cm.Expired(ctx)
+
+	cm.PutWithTTL(1, 10, 10*time.Millisecond)
+	cm.PutWithTTL(2, 20, 10*time.Millisecond)
+	cm.PutWithTTL(3, 30, time.Hour)
+
+	got := map[interface{}]interface{}{}
+	timeout := time.After(time.Second)
+	for len(got) < 2 {
+		select {
+		case e := <-ch:
+			got[e.Key()] = e.Value()
+		case <-timeout:
+			t.Fatalf("Receive expired entries timeout, got %v", got)
+		}
+	}
+	if got[1] != 10 || got[2] != 20 {
+		t.Errorf("Receive expired entries %v, want map[1:10 2:20]", got)
+	}
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestClose(t *testing.T) {
+	cm := NewConcurrentMap()
+	ch := cm.Expired(context.Background())
+	cm.PutWithTTL(1, 10, time.Hour)
+
+	if err := cm.Close(); err != nil {
+		t.Errorf("Close, return %v, want nil", err)
+	}
+	if err := cm.Close(); err != nil {
+		t.Errorf("Close again, return %v, want nil", err)
+	}
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Errorf("Receive from expired channel after Close, return an entry, want closed channel")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expired channel is not closed after Close")
+	}
+
+	//the timing wheel goroutine exits after Close
+	time.Sleep(3 * EXPIRATION_TICK)
+	cm.wheel.lock.Lock()
+	running := cm.wheel.running
+	cm.wheel.lock.Unlock()
+	if running {
+		t.Errorf("Timing wheel is still running after Close")
+	}
+
+	//map can still be used after Close
+	cm.PutWithTTL(2, 20, 10*time.Millisecond)
+	if v, _ := cm.Get(1); v != 10 {
+		t.Errorf("Get 1 after Close, return %v, want 10", v)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if v, _ := cm.Get(2); v != nil {
+		t.Errorf("Get 2 after expiration, return %v, want nil", v)
+	}
+}
+
+func TestNextExpirations(t *testing.T) {
+	cm := NewConcurrentMap()
+	defer cm.Close()
+	if exps := cm.NextExpirations(3); len(exps) != 0 {
+		t.Errorf("NextExpirations without TTL, return %v, want empty", exps)
+	}
+
+	cm.Put(0, 0)
+	for i := 1; i <= 10; i++ {
+		cm.PutWithTTL(i, i, time.Duration(i)*time.Hour)
+	}
+	//the stale timers are ignored
+	cm.PutWithTTL(1, 1, 20*time.Hour)
+	cm.ExpireAt(2, time.Time{})
+	cm.ExpireAt(3, time.Now().Add(5*time.Hour))
+	cm.ExpireAt(3, time.Now().Add(5*time.Hour))
+
+	exps := cm.NextExpirations(3)
+	keys := make([]interface{}, 0, len(exps))
+	for _, exp := range exps {
+		keys = append(keys, exp.Key)
+	}
+	if len(exps) != 3 || exps[0].Key != 4 || exps[1].Key != 5 || exps[2].Key != 3 || !exps[0].ExpireAt.Before(exps[1].ExpireAt) {
+		t.Errorf("NextExpirations, return keys %v, want [4 5 3]", keys)
+	}
+	if exps[0].Value != 4 {
+		t.Errorf("Value of first expiration, return %v, want 4", exps[0].Value)
+	}
+	if exps = cm.NextExpirations(100); len(exps) != 9 || exps[8].Key != 1 {
+		t.Errorf("NextExpirations of all, return %v mappings, want 9", len(exps))
+	}
+}
+
+func TestNextExpirationsAcrossLevels(t *testing.T) {
+	cm := NewConcurrentMap()
+	defer cm.Close()
+	//a is put into level 1, b is put into level 0 after the wheel advanced,
+	//but a expires earlier than b
+	cm.PutWithTTL("a", 1, 70*EXPIRATION_TICK)
+	time.Sleep(10 * EXPIRATION_TICK)
+	cm.PutWithTTL("b", 2, 62*EXPIRATION_TICK)
+
+	exps := cm.NextExpirations(1)
+	if len(exps) != 1 || exps[0].Key != "a" {
+		t.Errorf("NextExpirations across levels, return %v, want a", exps)
+	}
+	if exps = cm.NextExpirations(2); len(exps) != 2 || exps[1].Key != "b" {
+		t.Errorf("NextExpirations across levels, return %v, want [a b]", exps)
+	}
+}
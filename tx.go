@@ -0,0 +1,200 @@
+package concurrent
+
+import (
+	"runtime"
+)
+
+type txRead struct {
+	key     interface{}
+	hash    uint32
+	version int64 //0 if no mapping
+}
+
+type txWrite struct {
+	key   interface{}
+	hash  uint32
+	value interface{} //nil if the key is removed
+}
+
+//writeSet is the buffered writes, the last write of a key replaces the earlier one
+type writeSet []txWrite
+
+func (this writeSet) find(key interface{}, hash uint32) *txWrite {
+	for i := len(this) - 1; i >= 0; i-- {
+		if w := &this[i]; w.hash == hash && equals(w.key, key) {
+			return w
+		}
+	}
+	return nil
+}
+
+func (this *writeSet) set(key interface{}, hash uint32, value interface{}) {
+	if w := this.find(key, hash); w != nil {
+		w.value = value
+	} else {
+		*this = append(*this, txWrite{key, hash, value})
+	}
+}
+
+/**
+ * Tx is a transaction created by Atomically.
+ * The reads are recorded with the versions of entries, and the writes are buffered
+ * until the transaction is committed. A Tx can only be used in the function passed to Atomically.
+ */
+type Tx struct {
+	m      *ConcurrentMap
+	reads  []txRead
+	writes writeSet
+}
+
+func (this *Tx) recorded(key interface{}, hash uint32) bool {
+	for _, r := range this.reads {
+		if r.hash == hash && equals(r.key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Returns the value to which the specified key is mapped in this transaction,
+ * the writes of this transaction are visible to itself.
+ */
+func (this *Tx) Get(key interface{}) (value interface{}, err error) {
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+	hash, err := hashKey(key, this.m, false)
+	if err != nil {
+		return
+	}
+	if w := this.writes.find(key, hash); w != nil {
+		return w.value, nil
+	}
+
+	//reads the value and version under lock, so they are consistent
+	seg := this.m.segmentFor(hash)
+	var version int64
+	seg.acquire()
+	if e := seg.findUnderLock(key, hash); e != nil {
+		value, version = e.fastValue(), e.version
+	}
+	seg.lock.Unlock()
+
+	if !this.recorded(key, hash) {
+		this.reads = append(this.reads, txRead{key, hash, version})
+	}
+	return
+}
+
+/**
+ * Maps the specified key to the specified value when the transaction is committed.
+ * Neither the key nor the value can be nil.
+ */
+func (this *Tx) Put(key interface{}, value interface{}) (err error) {
+	if isNil(key) {
+		return NilKeyError
+	}
+	if isNil(value) {
+		return NilValueError
+	}
+	if e := this.m.validate(key, value); e != nil {
+		return e
+	}
+	return this.write(key, value)
+}
+
+/**
+ * Removes the specified key when the transaction is committed.
+ */
+func (this *Tx) Remove(key interface{}) (err error) {
+	if isNil(key) {
+		return NilKeyError
+	}
+	return this.write(key, nil)
+}
+
+func (this *Tx) write(key interface{}, value interface{}) (err error) {
+	hash, err := hashKey(key, this.m, false)
+	if err != nil {
+		return
+	}
+	this.writes.set(key, hash, value)
+	return
+}
+
+/**
+ * Locks all segments that the transaction reads or writes, validates the read versions,
+ * and applies the writes if validation succeeds.
+ *
+ * @return false if any read mapping was changed by others
+ */
+func (this *Tx) commit() bool {
+	if len(this.writes) == 0 && len(this.reads) <= 1 {
+		//a read-only transaction that reads at most one key is always consistent
+		return true
+	}
+
+	indexes := make([]int, 0, len(this.reads)+len(this.writes))
+	for _, r := range this.reads {
+		indexes = append(indexes, this.m.segmentIndex(r.hash))
+	}
+	for _, w := range this.writes {
+		indexes = append(indexes, this.m.segmentIndex(w.hash))
+	}
+	locked := this.m.lockIndexes(indexes)
+	defer func() {
+		for i := len(locked) - 1; i >= 0; i-- {
+			locked[i].lock.Unlock()
+		}
+	}()
+
+	for _, r := range this.reads {
+		var version int64
+		if e := this.m.segmentFor(r.hash).findUnderLock(r.key, r.hash); e != nil {
+			version = e.version
+		}
+		if version != r.version {
+			return false
+		}
+	}
+
+	for _, w := range this.writes {
+		seg := this.m.segmentFor(w.hash)
+		if w.value == nil {
+			seg.removeUnderLock(w.key, w.hash, nil)
+		} else {
+			seg.storeUnderLock(w.key, w.hash, w.value, 0)
+		}
+	}
+	return true
+}
+
+/**
+ * Runs f in a transaction, it provides the atomicity for multiple keys
+ * without holding locks while f is running, like software transactional memory.
+ *
+ * The reads in f are recorded with the versions of entries and the writes are buffered,
+ * when f returns, the segments are locked in index order and the reads are validated.
+ * If any read mapping was changed by others, the writes are discarded and f is run again
+ * with a new Tx, so f may be run more than once and should have no side effects except on tx.
+ * A Put in transaction clears the expiration time of key like Put.
+ *
+ * @return the error returned by f, the writes are discarded if f returns error
+ */
+func (this *ConcurrentMap) Atomically(f func(tx *Tx) error) (err error) {
+	if f == nil {
+		return NilActionError
+	}
+	defer this.recoverCallback(&err)
+	for {
+		tx := &Tx{m: this}
+		if err = f(tx); err != nil {
+			return
+		}
+		if tx.commit() {
+			return
+		}
+		runtime.Gosched()
+	}
+}
@@ -0,0 +1,56 @@
+package concurrent
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestAtomically(t *testing.T) {
+	cm := NewConcurrentMap()
+	n, accounts := 1000, 10
+	for i := 0; i < accounts; i++ {
+		cm.Put(i, 100)
+	}
+
+	//concurrent transfers keep the total balance
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				from, to := (g+i)%accounts, (g+i+1)%accounts
+				cm.Atomically(func(tx *Tx) error {
+					v1, _ := tx.Get(from)
+					v2, _ := tx.Get(to)
+					tx.Put(from, v1.(int)-1)
+					tx.Put(to, v2.(int)+1)
+					return nil
+				})
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	sum := 0
+	for i := 0; i < accounts; i++ {
+		v, _ := cm.Get(i)
+		sum += v.(int)
+	}
+	if sum != 100*accounts {
+		t.Errorf("Get total balance after transfers, return %v, want %v", sum, 100*accounts)
+	}
+
+	abort := errors.New("abort")
+	err := cm.Atomically(func(tx *Tx) error {
+		tx.Remove(0)
+		if v, _ := tx.Get(0); v != nil {
+			t.Errorf("Get 0 after Remove in transaction, return %v, want nil", v)
+		}
+		return abort
+	})
+	if v, _ := cm.Get(0); err != abort || v == nil {
+		t.Errorf("Atomically with error, return %v, want abort and no change", err)
+	}
+}
@@ -0,0 +1,171 @@
+//go:build go1.18
+
+/**
+ * Package typed provides ConcurrentMap[K, V], the type-parameterized wrapper of
+ * concurrent.ConcurrentMap, so Get, Put and Remove return the concrete types
+ * and the callers need no type assertions.
+ *
+ * The keys and values are still stored as interface{} by the wrapped map,
+ * so the boxing is same as the untyped map, and the options of the untyped map,
+ * e.g. WithInlineValues, can be used to reduce it.
+ */
+package typed
+
+import (
+	concurrent "github.com/fanliao/go-concurrentMap"
+)
+
+/**
+ * ConcurrentMap is a concurrent.ConcurrentMap whose keys are K and values are V.
+ * Like the untyped map, a nil V, e.g. a nil pointer, cannot be stored and returns NilValueError.
+ */
+type ConcurrentMap[K comparable, V any] struct {
+	m *concurrent.ConcurrentMap
+}
+
+/**
+ * Creates a ConcurrentMap, the paras are same as concurrent.NewConcurrentMap.
+ */
+func NewConcurrentMap[K comparable, V any](paras ...interface{}) *ConcurrentMap[K, V] {
+	return &ConcurrentMap[K, V]{concurrent.NewConcurrentMap(paras...)}
+}
+
+/**
+ * Wraps the specified map, all keys and values of m must be K and V.
+ */
+func Wrap[K comparable, V any](m *concurrent.ConcurrentMap) *ConcurrentMap[K, V] {
+	if m == nil {
+		panic(concurrent.IllegalArgError)
+	}
+	return &ConcurrentMap[K, V]{m}
+}
+
+/**
+ * Returns the untyped map that backs this map.
+ */
+func (this *ConcurrentMap[K, V]) Unwrap() *concurrent.ConcurrentMap {
+	return this.m
+}
+
+//value returns v as V, ok is false if v is nil, i.e. no mapping
+func value[V any](v interface{}) (value V, ok bool) {
+	if v == nil {
+		return
+	}
+	return v.(V), true
+}
+
+/**
+ * Returns the value to which the specified key is mapped.
+ *
+ * @return ok is false if this map contains no mapping for the key
+ */
+func (this *ConcurrentMap[K, V]) Get(key K) (val V, ok bool, err error) {
+	v, err := this.m.Get(key)
+	val, ok = value[V](v)
+	return
+}
+
+func (this *ConcurrentMap[K, V]) ContainsKey(key K) (found bool, err error) {
+	return this.m.ContainsKey(key)
+}
+
+/**
+ * Maps the specified key to the specified value.
+ *
+ * @return the previous value, loaded is false if there was no mapping for key
+ */
+func (this *ConcurrentMap[K, V]) Put(key K, val V) (oldVal V, loaded bool, err error) {
+	v, err := this.m.Put(key, val)
+	oldVal, loaded = value[V](v)
+	return
+}
+
+/**
+ * If the specified key is not already associated with a value, associate it with the given value.
+ *
+ * @return the existing value, loaded is false if the value was put
+ */
+func (this *ConcurrentMap[K, V]) PutIfAbsent(key K, val V) (oldVal V, loaded bool, err error) {
+	v, err := this.m.PutIfAbsent(key, val)
+	oldVal, loaded = value[V](v)
+	return
+}
+
+func (this *ConcurrentMap[K, V]) PutAll(m map[K]V) (err error) {
+	all := make(map[interface{}]interface{}, len(m))
+	for k, v := range m {
+		all[k] = v
+	}
+	return this.m.PutAll(all)
+}
+
+/**
+ * Updates the value of key by action atomically, see Update of concurrent.ConcurrentMap.
+ * The action receives ok false if no mapping for key, and the mapping is removed
+ * if the action returns keep false.
+ *
+ * @return the previous value, loaded is false if there was no mapping for key
+ */
+func (this *ConcurrentMap[K, V]) Update(key K, action func(oldVal V, ok bool) (newVal V, keep bool)) (oldVal V, loaded bool, err error) {
+	v, err := this.m.Update(key, func(old interface{}) interface{} {
+		newVal, keep := action(value[V](old))
+		if !keep {
+			return nil
+		}
+		return newVal
+	})
+	oldVal, loaded = value[V](v)
+	return
+}
+
+/**
+ * Removes the mapping for key.
+ *
+ * @return the previous value, loaded is false if there was no mapping for key
+ */
+func (this *ConcurrentMap[K, V]) Remove(key K) (oldVal V, loaded bool, err error) {
+	v, err := this.m.Remove(key)
+	oldVal, loaded = value[V](v)
+	return
+}
+
+func (this *ConcurrentMap[K, V]) RemoveEntry(key K, val V) (ok bool, err error) {
+	return this.m.RemoveEntry(key, val)
+}
+
+/**
+ * Replaces the entry for key only if it is currently mapped to some value.
+ *
+ * @return the previous value, loaded is false if there was no mapping for key
+ */
+func (this *ConcurrentMap[K, V]) Replace(key K, val V) (oldVal V, loaded bool, err error) {
+	v, err := this.m.Replace(key, val)
+	oldVal, loaded = value[V](v)
+	return
+}
+
+func (this *ConcurrentMap[K, V]) CompareAndReplace(key K, oldVal V, newVal V) (ok bool, err error) {
+	return this.m.CompareAndReplace(key, oldVal, newVal)
+}
+
+func (this *ConcurrentMap[K, V]) Size() int32 {
+	return this.m.Size()
+}
+
+func (this *ConcurrentMap[K, V]) IsEmpty() bool {
+	return this.m.IsEmpty()
+}
+
+func (this *ConcurrentMap[K, V]) Clear() {
+	this.m.Clear()
+}
+
+/**
+ * Calls f for every mapping until f returns false, it is weakly consistent like the Iterator.
+ */
+func (this *ConcurrentMap[K, V]) Range(f func(key K, val V) bool) {
+	this.m.Range(func(k interface{}, v interface{}) bool {
+		return f(k.(K), v.(V))
+	})
+}
@@ -0,0 +1,70 @@
+//go:build go1.18
+
+package typed
+
+import (
+	"testing"
+
+	concurrent "github.com/fanliao/go-concurrentMap"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestConcurrentMap(t *testing.T) {
+	m := NewConcurrentMap[string, *point]()
+	p1, p2 := &point{1, 2}, &point{3, 4}
+
+	if old, loaded, err := m.Put("a", p1); old != nil || loaded || err != nil {
+		t.Errorf("Put, return %v, %v, %v, want nil, false, nil", old, loaded, err)
+	}
+	if v, ok, err := m.Get("a"); v != p1 || !ok || err != nil {
+		t.Errorf("Get, return %v, %v, %v, want %v, true, nil", v, ok, err, p1)
+	}
+	if v, ok, _ := m.Get("b"); v != nil || ok {
+		t.Errorf("Get of absent key, return %v, %v, want nil, false", v, ok)
+	}
+	if old, loaded, _ := m.PutIfAbsent("a", p2); old != p1 || !loaded {
+		t.Errorf("PutIfAbsent, return %v, %v, want %v, true", old, loaded, p1)
+	}
+	if _, _, err := m.Put("b", nil); err != concurrent.NilValueError {
+		t.Errorf("Put nil pointer, return %v, want NilValueError", err)
+	}
+
+	//Update removes the mapping if keep is false
+	m.Update("a", func(old *point, ok bool) (*point, bool) {
+		return &point{old.X + 10, old.Y}, true
+	})
+	if v, _, _ := m.Get("a"); v.X != 11 {
+		t.Errorf("Update, X is %v, want 11", v.X)
+	}
+	m.Update("a", func(old *point, ok bool) (*point, bool) {
+		return nil, false
+	})
+	if m.Size() != 0 {
+		t.Errorf("Update with keep false, Size is %v, want 0", m.Size())
+	}
+
+	m.PutAll(map[string]*point{"x": p1, "y": p2})
+	sum := 0
+	m.Range(func(key string, val *point) bool {
+		sum += val.X
+		return true
+	})
+	if sum != 4 {
+		t.Errorf("Range, sum is %v, want 4", sum)
+	}
+	if old, loaded, _ := m.Remove("x"); old != p1 || !loaded {
+		t.Errorf("Remove, return %v, %v, want %v, true", old, loaded, p1)
+	}
+	if ok, _ := m.CompareAndReplace("y", p2, p1); !ok {
+		t.Errorf("CompareAndReplace, return false, want true")
+	}
+
+	ints := Wrap[int, int](concurrent.NewConcurrentMap())
+	ints.Put(1, 0)
+	if v, ok, _ := ints.Get(1); v != 0 || !ok {
+		t.Errorf("Get zero int, return %v, %v, want 0, true", v, ok)
+	}
+}
@@ -0,0 +1,100 @@
+package concurrent
+
+import (
+	"fmt"
+)
+
+/**
+ * ValidationError is returned if a mapping is rejected by the validator, see WithValidator.
+ */
+type ValidationError struct {
+	Key   interface{}
+	Value interface{}
+	//the error returned by the validator
+	Err error
+}
+
+func (this *ValidationError) Error() string {
+	return fmt.Sprintf("invalid mapping %v -> %v: %v", this.Key, this.Value, this.Err)
+}
+
+func (this *ValidationError) Unwrap() error {
+	return this.Err
+}
+
+/**
+ * Returns an Option that validates every mapping before it is stored, so the invariants
+ * of a map shared by many writers are checked in one place. If the validator returns an error,
+ * nothing is stored and a ValidationError is returned.
+ *
+ * The validated writes are:
+ *   - the Put methods, Replace, CompareAndReplace, PutAll, CompareAndReplaceAll and Builder.Build,
+ *     the batch methods validate all mappings first and store nothing if any of them is invalid
+ *   - WriteBuffer.Put, Session.Put, Tx.Put, which validate the mapping when it is buffered,
+ *     so Flush and Commit only store the validated mappings
+ *   - LockedSegments.Put, EntryHandle.Set and TenantQuota.Put
+ *   - the values computed by the callbacks of Update, UpdateIfPresent, Compute, Merge, ComputeAll
+ *     and EntryHandle.Update, and the values loaded by ComputeIfAbsent. A rejected computed value
+ *     aborts the call and keeps the current mapping. ComputeAll keeps the values computed
+ *     before the rejected one.
+ *
+ * The validator may be called while holding the segment lock, so it must not access this map.
+ */
+func WithValidator(validator func(key interface{}, value interface{}) error) Option {
+	if validator == nil {
+		panic(IllegalArgError)
+	}
+	return func(m *ConcurrentMap) {
+		m.validator = validator
+	}
+}
+
+//validate returns a ValidationError if the validator rejects the mapping
+func (this *ConcurrentMap) validate(key interface{}, value interface{}) error {
+	if this.validator == nil {
+		return nil
+	}
+	if err := this.validator(key, value); err != nil {
+		return &ValidationError{key, value, err}
+	}
+	return nil
+}
+
+//rejection aborts a callback under the segment lock if its value is rejected, see checkComputed
+type rejection struct {
+	err error
+}
+
+//checkComputed panics with rejection if the validator rejects the value computed by a callback,
+//the segment lock is released by the deferred unlock and the panic is recovered by recoverRejection
+func (this *ConcurrentMap) checkComputed(key interface{}, value interface{}) {
+	if value == nil {
+		return
+	}
+	if err := this.validate(key, value); err != nil {
+		panic(rejection{err})
+	}
+}
+
+//validatedAction returns an action that checks the value computed by action, or action itself if no validator
+func (this *ConcurrentMap) validatedAction(key interface{}, action func(oldVal interface{}) (newVal interface{})) func(oldVal interface{}) (newVal interface{}) {
+	if this.validator == nil {
+		return action
+	}
+	return func(oldVal interface{}) interface{} {
+		newVal := action(oldVal)
+		this.checkComputed(key, newVal)
+		return newVal
+	}
+}
+
+//recoverRejection returns the ValidationError of a rejected callback by err, other panics are raised again
+func recoverRejection(err *error) {
+	if r := recover(); r != nil {
+		if rj, ok := r.(rejection); ok {
+			*err = rj.err
+			return
+		}
+		panic(r)
+	}
+}
@@ -0,0 +1,164 @@
+package concurrent
+
+import (
+	"errors"
+	"testing"
+)
+
+var errNegative = errors.New("negative value")
+
+func nonNegative(key interface{}, value interface{}) error {
+	if n, ok := value.(int); ok && n < 0 {
+		return errNegative
+	}
+	return nil
+}
+
+func TestWithValidator(t *testing.T) {
+	cm := NewConcurrentMap(WithValidator(nonNegative))
+	if _, err := cm.Put(1, 1); err != nil {
+		t.Errorf("Put valid mapping, return %v, want nil", err)
+	}
+
+	_, err := cm.Put(2, -1)
+	if ve, ok := err.(*ValidationError); !ok || ve.Key != 2 || ve.Value != -1 || !errors.Is(err, errNegative) {
+		t.Errorf("Put invalid mapping, return %v, want ValidationError of %v", err, errNegative)
+	}
+	if _, err := cm.Replace(1, -1); err == nil {
+		t.Errorf("Replace with invalid value, return nil, want ValidationError")
+	}
+	if _, err := cm.CompareAndReplace(1, 1, -1); err == nil {
+		t.Errorf("CompareAndReplace with invalid value, return nil, want ValidationError")
+	}
+	if _, err := cm.PutIfAbsent(3, -1); err == nil {
+		t.Errorf("PutIfAbsent with invalid value, return nil, want ValidationError")
+	}
+
+	//PutAll stores nothing if any mapping is invalid
+	if err := cm.PutAll(map[interface{}]interface{}{4: 4, 5: -5}); err == nil {
+		t.Errorf("PutAll with invalid value, return nil, want ValidationError")
+	}
+	if v, _ := cm.Get(1); v != 1 || cm.Size() != 1 {
+		t.Errorf("after invalid writes, Get return %v, Size is %v, want 1, 1", v, cm.Size())
+	}
+
+	b := NewBuilder(WithValidator(nonNegative))
+	b.Put(1, -1)
+	if _, err := b.Build(); err == nil {
+		t.Errorf("Build with invalid value, return nil, want ValidationError")
+	}
+}
+
+//isRejected returns true if err is the ValidationError of nonNegative
+func isRejected(err error) bool {
+	_, ok := err.(*ValidationError)
+	return ok && errors.Is(err, errNegative)
+}
+
+func TestValidatorBufferedWrites(t *testing.T) {
+	cm := NewConcurrentMap(WithValidator(nonNegative))
+
+	wb := cm.NewWriteBuffer(10, 0)
+	if err := wb.Put(1, -1); !isRejected(err) {
+		t.Errorf("WriteBuffer.Put invalid mapping, return %v, want ValidationError", err)
+	}
+	wb.Flush()
+
+	s := cm.NewSession()
+	if err := s.Put(1, -1); !isRejected(err) {
+		t.Errorf("Session.Put invalid mapping, return %v, want ValidationError", err)
+	}
+	s.Commit()
+
+	err := cm.Atomically(func(tx *Tx) error {
+		return tx.Put(1, -1)
+	})
+	if !isRejected(err) {
+		t.Errorf("Tx.Put invalid mapping, return %v, want ValidationError", err)
+	}
+
+	locked, _ := cm.LockSegmentOf(1)
+	if _, err := locked.Put(1, -1); !isRejected(err) {
+		t.Errorf("LockedSegments.Put invalid mapping, return %v, want ValidationError", err)
+	}
+	locked.Unlock()
+
+	q := NewTenantQuota(func(key interface{}) interface{} { return 0 }, 10, nil)
+	qm := NewConcurrentMap(WithValidator(nonNegative), WithTenantQuota(q))
+	if _, err := q.Put(1, -1); !isRejected(err) {
+		t.Errorf("TenantQuota.Put invalid mapping, return %v, want ValidationError", err)
+	}
+	if v, _ := qm.Get(1); v != nil || q.Usage(0) != 0 {
+		t.Errorf("Get after invalid TenantQuota.Put, return %v, usage %v, want nil, 0", v, q.Usage(0))
+	}
+
+	if v, _ := cm.Get(1); v != nil {
+		t.Errorf("Get after invalid buffered writes, return %v, want nil", v)
+	}
+}
+
+func TestValidatorComputedValues(t *testing.T) {
+	cm := NewConcurrentMap(WithValidator(nonNegative))
+	cm.Put(1, 1)
+	negate := func(oldVal interface{}) interface{} {
+		return -oldVal.(int)
+	}
+
+	if _, err := cm.Update(1, negate); !isRejected(err) {
+		t.Errorf("Update to invalid value, return %v, want ValidationError", err)
+	}
+	if _, err := cm.UpdateIfPresent(1, negate); !isRejected(err) {
+		t.Errorf("UpdateIfPresent to invalid value, return %v, want ValidationError", err)
+	}
+	if v, err := cm.Compute(1, func(key interface{}, oldVal interface{}) interface{} {
+		return -1
+	}); !isRejected(err) || v != nil {
+		t.Errorf("Compute invalid value, return %v, %v, want nil, ValidationError", v, err)
+	}
+	if _, err := cm.Merge(1, 5, func(oldVal interface{}, value interface{}) interface{} {
+		return oldVal.(int) - value.(int)
+	}); !isRejected(err) {
+		t.Errorf("Merge to invalid value, return %v, want ValidationError", err)
+	}
+	if _, err := cm.Merge(2, -5, func(oldVal interface{}, value interface{}) interface{} {
+		return value
+	}); !isRejected(err) {
+		t.Errorf("Merge invalid value of absent key, return %v, want ValidationError", err)
+	}
+	if err := cm.ComputeAll([]interface{}{1}, func(key interface{}, oldVal interface{}) interface{} {
+		return -1
+	}); !isRejected(err) {
+		t.Errorf("ComputeAll invalid value, return %v, want ValidationError", err)
+	}
+	if v, err := cm.ComputeIfAbsent(3, func(key interface{}) (interface{}, error) {
+		return -3, nil
+	}); !isRejected(err) || v != nil {
+		t.Errorf("ComputeIfAbsent loading invalid value, return %v, %v, want nil, ValidationError", v, err)
+	}
+
+	_, h, _ := cm.PutWithHandle(4, 4)
+	if _, err := h.Set(-4); !isRejected(err) {
+		t.Errorf("EntryHandle.Set invalid value, return %v, want ValidationError", err)
+	}
+	if _, err := h.Update(negate); !isRejected(err) {
+		t.Errorf("EntryHandle.Update to invalid value, return %v, want ValidationError", err)
+	}
+
+	//the rejected values are not stored, and the segments are unlocked
+	for k, want := range map[interface{}]interface{}{1: 1, 2: nil, 3: nil, 4: 4} {
+		if v, _ := cm.Get(k); v != want {
+			t.Errorf("Get %v after rejected computations, return %v, want %v", k, v, want)
+		}
+	}
+	if old, err := cm.Update(1, func(oldVal interface{}) interface{} { return 10 }); old != 1 || err != nil {
+		t.Errorf("Update to valid value, return %v, %v, want 1, nil", old, err)
+	}
+
+	//the other panics of callbacks are not swallowed
+	defer func() {
+		if r := recover(); r != "callback failed" {
+			t.Errorf("Update with panicking action, panic %v, want callback failed", r)
+		}
+	}()
+	cm.Update(1, func(oldVal interface{}) interface{} { panic("callback failed") })
+}
@@ -0,0 +1,216 @@
+package concurrent
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+//the representations of value in entry
+const (
+	valueBoxed      uint8 = iota //value points to an interface{}
+	valueDirect                  //value is the pointer that the interface{} holds
+	valueInline                  //bits holds the memory of value
+	valueCompressed              //value points to a compressedValue, see WithCompression
+)
+
+//emptyInterface is the header of interface{}
+type emptyInterface struct {
+	typ  unsafe.Pointer
+	word unsafe.Pointer
+}
+
+//noDirectType means the first value put into map isn't a pointer,
+//so all values are boxed as *interface{}
+var noDirectType = unsafe.Pointer(new(byte))
+
+//smallBits is used to rebuild the inline values that bits < 256 without allocation,
+//like the runtime does for the small integers
+var smallBits [256]uint64
+
+func init() {
+	for i := range smallBits {
+		smallBits[i] = uint64(i)
+	}
+}
+
+//inlineTypes caches if the struct and array types can be stored inline, map[reflect.Type]bool
+var inlineTypes sync.Map
+
+//entryValue is the representation of a value stored in entry
+type entryValue struct {
+	kind uint8
+	typ  unsafe.Pointer
+	ptr  unsafe.Pointer
+	bits uint64
+}
+
+/**
+ * Returns an Option that stores the small scalar values inline in entries,
+ * i.e. bool, numbers, and the structs and arrays that have no pointers and are at most 8 bytes.
+ *
+ * It saves the allocation of boxing on write and the indirection on read,
+ * e.g. for the counter-like workloads. But Get has to allocate the interface{}
+ * for the numbers >= 256, so enable it only if the writes dominate.
+ */
+func WithInlineValues() Option {
+	return func(m *ConcurrentMap) {
+		m.inlineValues = true
+	}
+}
+
+/**
+ * Returns the representation of value stored in entry.
+ *
+ * A value is usually boxed as *interface{}, it costs an allocation on write
+ * and an indirection on read. If the value is a pointer, the interface{} just holds
+ * the type and the pointer, so the pointer can be stored in entry directly,
+ * and the interface{} is rebuilt with the type on read.
+ *
+ * The direct type is detected once by the first value put into map,
+ * the values of other types are still boxed, so a map that mixes the value types still works.
+ * If WithInlineValues is enabled, the small scalar values are copied into entry,
+ * and if WithCompression is enabled, the large strings and []byte are compressed.
+ */
+func (this *ConcurrentMap) boxValue(v interface{}) (ev entryValue) {
+	if ev, ok := this.compressedEntryValue(v); ok {
+		return ev
+	}
+	ei := *(*emptyInterface)(unsafe.Pointer(&v))
+	if this.inlineValues && canInline(v) {
+		ev.kind, ev.typ = valueInline, ei.typ
+		size := reflect.TypeOf(v).Size()
+		copy((*[8]byte)(unsafe.Pointer(&ev.bits))[:size], (*[8]byte)(ei.word)[:size])
+		return
+	}
+
+	t := atomic.LoadPointer(&this.directType)
+	if t == nil {
+		t = noDirectType
+		if reflect.TypeOf(v).Kind() == reflect.Ptr {
+			t = ei.typ
+		}
+		atomic.CompareAndSwapPointer(&this.directType, nil, t)
+		t = atomic.LoadPointer(&this.directType)
+	}
+
+	if ei.typ == t {
+		ev.kind, ev.typ, ev.ptr = valueDirect, t, ei.word
+		return
+	}
+	boxed := v
+	ev.ptr = unsafe.Pointer(&boxed)
+	return
+}
+
+//canInline returns true if v is a scalar that has no pointers and is at most 8 bytes
+func canInline(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64:
+		return true
+	case reflect.Struct, reflect.Array:
+		if ok, found := inlineTypes.Load(t); found {
+			return ok.(bool)
+		}
+		ok := t.Size() <= 8 && !hasPointers(t)
+		inlineTypes.Store(t, ok)
+		return ok
+	}
+	return false
+}
+
+func hasPointers(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if hasPointers(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	case reflect.Array:
+		return t.Len() > 0 && hasPointers(t.Elem())
+	case reflect.Ptr, reflect.UnsafePointer, reflect.String, reflect.Slice, reflect.Map,
+		reflect.Chan, reflect.Func, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+//unboxValue rebuilds the value from p that is loaded from entry
+func (this *Entry) unboxValue(p unsafe.Pointer) (v interface{}) {
+	if this.vkind == valueBoxed {
+		return *((*interface{})(p))
+	}
+	if this.vkind == valueCompressed {
+		return (*compressedValue)(p).decompress()
+	}
+	if p != nil {
+		ei := (*emptyInterface)(unsafe.Pointer(&v))
+		ei.typ, ei.word = this.vtype, p
+	}
+	return
+}
+
+//unboxInline rebuilds the value from bits that is loaded from entry
+func (this *Entry) unboxInline(bits uint64) (v interface{}) {
+	ei := (*emptyInterface)(unsafe.Pointer(&v))
+	ei.typ = this.vtype
+	if bits < uint64(len(smallBits)) {
+		ei.word = unsafe.Pointer(&smallBits[bits])
+	} else {
+		//the memory of value is copied, so bits can be changed by others after returning
+		copied := new(uint64)
+		*copied = bits
+		ei.word = unsafe.Pointer(copied)
+	}
+	return
+}
+
+/**
+ * Creates an entry with a new version.
+ * Call only while holding lock.
+ */
+func (this *Segment) newEntry(key interface{}, hash uint32, value interface{}, next *Entry) *Entry {
+	this.version++
+	ev := this.m.boxValue(value)
+	if this.slabSize > 0 {
+		if len(this.slab) == 0 {
+			this.slab = make([]Entry, this.slabSize)
+		}
+		e := &this.slab[0]
+		this.slab = this.slab[1:]
+		*e = Entry{version: this.version, bits: ev.bits, key: key, hash: hash,
+			vkind: ev.kind, vtype: ev.typ, value: ev.ptr, next: next}
+		return e
+	}
+	return &Entry{version: this.version, bits: ev.bits, key: key, hash: hash,
+		vkind: ev.kind, vtype: ev.typ, value: ev.ptr, next: next}
+}
+
+/**
+ * Replaces e by a new entry that holds the value in another representation and staleAt,
+ * the version of new entry is the current version of segment.
+ * Call only while holding lock.
+ */
+func (this *Segment) replaceEntryUnderLock(e *Entry, ev entryValue, staleAt int64) *Entry {
+	tab := this.table()
+	index := e.hash & uint32(len(tab)-1)
+	newE := e.clone(e.next)
+	newE.version, newE.bits = this.version, ev.bits
+	newE.vkind, newE.vtype, newE.value = ev.kind, ev.typ, ev.ptr
+	newE.staleAt = staleAt
+
+	//as removeEntryUnderLock, all preceding entries need to be cloned
+	newFirst := newE
+	for q := (*Entry)(tab[index]); q != e; q = q.next {
+		newFirst = q.clone(newFirst)
+	}
+	atomic.StorePointer(&tab[index], unsafe.Pointer(newFirst))
+	atomic.AddInt32(&this.layout, 1)
+	return newE
+}
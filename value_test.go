@@ -0,0 +1,99 @@
+package concurrent
+
+import (
+	"testing"
+)
+
+type valueT struct{ n int }
+type valueU struct{ n int }
+
+func TestDirectValue(t *testing.T) {
+	cm := NewConcurrentMap()
+	t1, t2, u := &valueT{1}, &valueT{2}, &valueU{3}
+	cm.Put(1, t1)
+	if v, _ := cm.Get(1); v != t1 {
+		t.Errorf("Get 1, return %v, want %v", v, t1)
+	}
+
+	//the representation of value is changed
+	for _, want := range []interface{}{u, "x", t2, 10} {
+		cm.Put(1, want)
+		if v, _ := cm.Get(1); v != want {
+			t.Errorf("Get 1 after Put %v, return %v, want %v", want, v, want)
+		}
+	}
+	cm.Put(2, t1)
+	if ok, _ := cm.CompareAndReplace(2, t1, u); !ok {
+		t.Errorf("CompareAndReplace %v with %v, return false, want true", t1, u)
+	}
+	if v, _ := cm.Replace(2, t2); v != u {
+		t.Errorf("Replace 2, return %v, want %v", v, u)
+	}
+	if v, _ := cm.Get(2); v != t2 || cm.Size() != 2 {
+		t.Errorf("Get 2, return %v, want %v", v, t2)
+	}
+
+	//the pointer is stored without boxing
+	direct := testing.AllocsPerRun(100, func() {
+		cm.Replace(2, t1)
+	})
+	boxed := testing.AllocsPerRun(100, func() {
+		cm.Replace(2, u)
+	})
+	if direct >= boxed {
+		t.Errorf("Allocations of Replace with direct value, return %v, want < %v", direct, boxed)
+	}
+}
+
+type smallT struct {
+	a int32
+	b bool
+}
+
+func TestInlineValue(t *testing.T) {
+	cm := NewConcurrentMap(WithInlineValues())
+	values := []interface{}{int64(1 << 40), true, 3.5, uint8(7), smallT{-1, true}, [2]int16{1, 2}, "s", &valueT{1}, -5}
+	for i, want := range values {
+		cm.Put(i, want)
+		cm.Put(-1, want)
+		if v, _ := cm.Get(i); v != want {
+			t.Errorf("Get %v, return %v, want %v", i, v, want)
+		}
+		if v, _ := cm.Get(-1); v != want {
+			t.Errorf("Get -1 after Put %v, return %v, want %v", want, v, want)
+		}
+	}
+
+	if canInline(struct{ p *int }{}) || canInline([2]int64{}) || !canInline(smallT{}) {
+		t.Errorf("canInline returns wrong result")
+	}
+
+	//the value is not boxed on write
+	n := 1 << 20
+	allocs := testing.AllocsPerRun(100, func() {
+		n++
+		cm.Put(n, n)
+	})
+	if v, _ := cm.Get(n); v != n {
+		t.Errorf("Get %v, return %v, want %v", n, v, n)
+	}
+	boxed := NewConcurrentMap()
+	if want := testing.AllocsPerRun(100, func() {
+		n++
+		boxed.Put(n, n)
+	}); allocs >= want {
+		t.Errorf("Allocations of Put with inline value, return %v, want < %v", allocs, want)
+	}
+
+	//the small values are rebuilt without allocation
+	cm.Put("small", 200)
+	boxed.Put("small", 200)
+	allocs = testing.AllocsPerRun(100, func() {
+		cm.Get("small")
+	})
+	if want := testing.AllocsPerRun(100, func() {
+		boxed.Get("small")
+	}); allocs != want {
+		t.Errorf("Allocations of Get small inline value, return %v, want %v", allocs, want)
+	}
+}
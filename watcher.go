@@ -0,0 +1,131 @@
+package concurrent
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	/**
+	 * The size watchers are evaluated at most once in this interval,
+	 * the evaluation is delayed to the end of interval after a mutation,
+	 * so the last mutation in interval is always evaluated.
+	 */
+	SIZE_WATCH_INTERVAL time.Duration = 100 * time.Millisecond
+)
+
+type sizeWatcher struct {
+	n        int32
+	above    bool //true if watch size > n, otherwise watch size < n
+	callback func(size int32)
+	//matched is true if the last evaluated size matches the condition
+	matched bool
+}
+
+func (this *sizeWatcher) match(size int32) bool {
+	if this.above {
+		return size > this.n
+	}
+	return size < this.n
+}
+
+type sizeWatchers struct {
+	lock     sync.Mutex
+	watchers []*sizeWatcher
+	count    int32 //atomic, the number of watchers
+	pending  int32 //atomic, 1 if an evaluation has been scheduled
+}
+
+/**
+ * Registers a callback that is called when the size of map grows above n,
+ * i.e. the size changes from <= n to > n.
+ *
+ * The size is evaluated on mutation, but debounced by SIZE_WATCH_INTERVAL,
+ * so the callback is called in a separated goroutine at most once in every interval,
+ * and a short spike may be missed.
+ *
+ * @return a function that unregisters the callback
+ */
+func (this *ConcurrentMap) OnSizeAbove(n int32, callback func(size int32)) (cancel func()) {
+	return this.watchSize(&sizeWatcher{n: n, above: true, callback: callback})
+}
+
+/**
+ * Registers a callback that is called when the size of map shrinks below n,
+ * i.e. the size changes from >= n to < n.
+ *
+ * The size is evaluated on mutation, but debounced by SIZE_WATCH_INTERVAL,
+ * so the callback is called in a separated goroutine at most once in every interval,
+ * and a short spike may be missed.
+ *
+ * @return a function that unregisters the callback
+ */
+func (this *ConcurrentMap) OnSizeBelow(n int32, callback func(size int32)) (cancel func()) {
+	return this.watchSize(&sizeWatcher{n: n, above: false, callback: callback})
+}
+
+func (this *ConcurrentMap) watchSize(w *sizeWatcher) (cancel func()) {
+	if w.callback == nil {
+		panic(NilActionError)
+	}
+	ws := &this.sizeWatchers
+	w.matched = w.match(this.Size())
+
+	ws.lock.Lock()
+	ws.watchers = append(ws.watchers, w)
+	atomic.AddInt32(&ws.count, 1)
+	ws.lock.Unlock()
+
+	return func() {
+		ws.lock.Lock()
+		defer ws.lock.Unlock()
+		for i, w1 := range ws.watchers {
+			if w1 == w {
+				ws.watchers = append(ws.watchers[:i:i], ws.watchers[i+1:]...)
+				atomic.AddInt32(&ws.count, -1)
+				break
+			}
+		}
+	}
+}
+
+/**
+ * Schedules an evaluation of size watchers if there is no pending evaluation.
+ * It is called after the size of a segment is changed, maybe while holding segment lock.
+ */
+func (this *ConcurrentMap) sizeChanged() {
+	ws := &this.sizeWatchers
+	if atomic.LoadInt32(&ws.count) == 0 {
+		return
+	}
+	if atomic.CompareAndSwapInt32(&ws.pending, 0, 1) {
+		time.AfterFunc(SIZE_WATCH_INTERVAL, this.evaluateSizeWatchers)
+	}
+}
+
+func (this *ConcurrentMap) evaluateSizeWatchers() {
+	ws := &this.sizeWatchers
+	atomic.StoreInt32(&ws.pending, 0)
+	select {
+	case <-this.closed:
+		return
+	default:
+	}
+
+	size := this.Size()
+	fired := make([]*sizeWatcher, 0)
+	ws.lock.Lock()
+	for _, w := range ws.watchers {
+		matched := w.match(size)
+		if matched && !w.matched {
+			fired = append(fired, w)
+		}
+		w.matched = matched
+	}
+	ws.lock.Unlock()
+
+	for _, w := range fired {
+		w.callback(size)
+	}
+}
@@ -0,0 +1,48 @@
+package concurrent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSizeWatchers(t *testing.T) {
+	cm := NewConcurrentMap()
+	aboveC, belowC := make(chan int32, 10), make(chan int32, 10)
+	cancel := cm.OnSizeAbove(10, func(size int32) { aboveC <- size })
+	cm.OnSizeBelow(5, func(size int32) { belowC <- size })
+
+	for i := 0; i < 20; i++ {
+		cm.Put(i, i)
+	}
+	select {
+	case size := <-aboveC:
+		if size != 20 {
+			t.Errorf("OnSizeAbove callback receives %v, want 20", size)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("OnSizeAbove callback is not called after size grows to 20")
+	}
+
+	for i := 0; i < 18; i++ {
+		cm.Remove(i)
+	}
+	select {
+	case size := <-belowC:
+		if size != 2 {
+			t.Errorf("OnSizeBelow callback receives %v, want 2", size)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("OnSizeBelow callback is not called after size shrinks to 2")
+	}
+
+	cancel()
+	for i := 0; i < 20; i++ {
+		cm.Put(i, i)
+	}
+	time.Sleep(2 * SIZE_WATCH_INTERVAL)
+	select {
+	case size := <-aboveC:
+		t.Errorf("OnSizeAbove callback is called with %v after cancel", size)
+	default:
+	}
+}
@@ -0,0 +1,62 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteBuffer(t *testing.T) {
+	cm := NewConcurrentMap()
+	wb := cm.NewWriteBuffer(10, 0)
+	for i := 0; i < 9; i++ {
+		wb.Put(i, i)
+	}
+	if s := cm.Size(); s != 0 || wb.Len() != 9 {
+		t.Errorf("Get size before flushing, return %v, want 0", s)
+	}
+	wb.Put(0, 100)
+	if s := cm.Size(); s != 9 || wb.Len() != 0 {
+		t.Errorf("Get size after buffer is full, return %v, want 9", s)
+	}
+	if v, _ := cm.Get(0); v != 100 {
+		t.Errorf("Get 0, return %v, want 100", v)
+	}
+	if err := wb.Put(nil, 1); err != NilKeyError {
+		t.Errorf("Put nil key, return %v, want NilKeyError", err)
+	}
+
+	wb.Put(20, 20)
+	wb.Flush()
+	if v, _ := cm.Get(20); v != 20 {
+		t.Errorf("Get 20 after Flush, return %v, want 20", v)
+	}
+
+	wb = cm.NewWriteBuffer(100, 10*time.Millisecond)
+	wb.Put(30, 30)
+	time.Sleep(100 * time.Millisecond)
+	if v, _ := cm.Get(30); v != 30 {
+		t.Errorf("Get 30 after interval, return %v, want 30", v)
+	}
+}
+
+func TestWriteBufferConcurrent(t *testing.T) {
+	cm := NewConcurrentMap()
+	n := 1000
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			wb := cm.NewWriteBuffer(64, time.Millisecond)
+			for i := 0; i < n; i++ {
+				wb.Put(g*n+i, i)
+			}
+			wb.Flush()
+		}(g)
+	}
+	wg.Wait()
+	if s := cm.Size(); s != int32(4*n) {
+		t.Errorf("Get size, return %v, want %v", s, 4*n)
+	}
+}
@@ -0,0 +1,77 @@
+package concurrent
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+/**
+ * Returns an Option that sets the equality of values that PutCoalesced uses,
+ * e.g. for the values that are not comparable by ==, like slices.
+ */
+func WithValueEquals(equals func(v1, v2 interface{}) bool) Option {
+	if equals == nil {
+		panic(IllegalArgError)
+	}
+	return func(m *ConcurrentMap) {
+		m.valueEquals = equals
+	}
+}
+
+func (this *ConcurrentMap) valuesEqual(v1, v2 interface{}) bool {
+	if this.valueEquals != nil {
+		return this.valueEquals(v1, v2)
+	}
+	return v1 == v2
+}
+
+/**
+ * Same as Put, but the write is dropped if the key is mapped to an equal value
+ * that was written by PutCoalesced within the window, the values are compared by
+ * WithValueEquals, or == by default. The check doesn't lock or write the segment,
+ * so the telemetry-style writers that put the same value to a hot key repeatedly
+ * don't invalidate the cache lines of readers. The value is written again once the window has passed.
+ *
+ * @return the previous value associated with key, or nil if there was no mapping for key,
+ *         it is the current value if the write is dropped
+ */
+func (this *ConcurrentMap) PutCoalesced(key interface{}, value interface{}, window time.Duration) (oldVal interface{}, err error) {
+	if isNil(key) {
+		return nil, NilKeyError
+	}
+	if isNil(value) {
+		return nil, NilValueError
+	}
+	if e := this.validate(key, value); e != nil {
+		return nil, e
+	}
+	defer this.recoverCallback(&err)
+
+	hash, err := hashKey(key, this, false)
+	if err != nil {
+		return
+	}
+	Printf("PutCoalesced, %v, %v\n", key, hash)
+	return this.segmentFor(hash).putCoalesced(key, hash, value, int64(window)), nil
+}
+
+func (this *Segment) putCoalesced(key interface{}, hash uint32, value interface{}, window int64) (oldVal interface{}) {
+	now := time.Now().UnixNano()
+	e := this.getFirst(hash)
+	for e != nil && (e.hash != hash || !equals(e.key, key)) {
+		e = e.next
+	}
+	if e != nil && !e.isExpired(now) && now-atomic.LoadInt64(&e.written) < window {
+		if v := e.Value(); v != nil && this.m.valuesEqual(v, value) {
+			return v
+		}
+	}
+
+	this.acquire()
+	defer this.lock.Unlock()
+	oldVal = this.putUnderLock(key, hash, value, false, nil, 0)
+	if e = this.findUnderLock(key, hash); e != nil {
+		atomic.StoreInt64(&e.written, now)
+	}
+	return
+}
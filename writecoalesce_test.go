@@ -0,0 +1,61 @@
+package concurrent
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+//mutationCounter counts the mutations that are notified to listeners
+type mutationCounter int
+
+func (this *mutationCounter) onMutation(key interface{}, hash uint32, oldVal interface{}, newVal interface{}) {
+	*this++
+}
+
+func TestPutCoalesced(t *testing.T) {
+	var changes mutationCounter
+	cm := NewConcurrentMap()
+	cm.listeners = append(cm.listeners, &changes)
+
+	for i := 0; i < 100; i++ {
+		if _, err := cm.PutCoalesced("cpu", 42, time.Minute); err != nil {
+			t.Errorf("PutCoalesced, return %v, want nil", err)
+		}
+	}
+	if changes != 1 {
+		t.Errorf("PutCoalesced same value, %v writes, want 1", changes)
+	}
+
+	//the changed value is always written
+	if old, _ := cm.PutCoalesced("cpu", 43, time.Minute); old != 42 || changes != 2 {
+		t.Errorf("PutCoalesced changed value, return %v, %v writes, want 42, 2", old, changes)
+	}
+	if v, _ := cm.Get("cpu"); v != 43 {
+		t.Errorf("Get after PutCoalesced, return %v, want 43", v)
+	}
+
+	//the value is written again after the window
+	cm.PutCoalesced("cpu", 43, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	cm.PutCoalesced("cpu", 43, time.Millisecond)
+	if changes != 3 {
+		t.Errorf("PutCoalesced after window, %v writes, want 3", changes)
+	}
+}
+
+func TestWithValueEquals(t *testing.T) {
+	cm := NewConcurrentMap(WithValueEquals(func(v1, v2 interface{}) bool {
+		return reflect.DeepEqual(v1, v2)
+	}))
+	cm.PutCoalesced(1, []int{1, 2}, time.Minute)
+	if old, _ := cm.PutCoalesced(1, []int{1, 2}, time.Minute); old == nil {
+		t.Errorf("PutCoalesced equal slice, return nil, want the current value")
+	}
+	if old, _ := cm.PutCoalesced(1, []int{3}, time.Minute); !reflect.DeepEqual(old, []int{1, 2}) {
+		t.Errorf("PutCoalesced changed slice, return %v, want [1 2]", old)
+	}
+	if v, _ := cm.Get(1); !reflect.DeepEqual(v, []int{3}) {
+		t.Errorf("Get after PutCoalesced, return %v, want [3]", v)
+	}
+}